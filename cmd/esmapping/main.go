@@ -0,0 +1,134 @@
+// esmapping prints the Elasticsearch mapping generated from a Go struct, or compares it with the mapping of a live index.
+//
+// The struct is loaded by compiling a small program against its package with go run, so it must be run from
+// within a module that requires both that package and aishoppercommon.
+//
+//	esmapping -pkg github.com/acme/catalog/model -type Product > products.mapping.json
+//	ELASTICSEARCH_ADDRESSES=http://localhost:9200 esmapping -pkg github.com/acme/catalog/model -type Product -index products
+//
+// With -index, the differences are printed and the exit status is 1 if there are any. The client is configured
+// from the environment like elasticsearch.NewElasticsearchClientFromEnv, including credentials and TLS.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"text/template"
+
+	"github.com/kdjuwidja/aishoppercommon/elasticsearch"
+)
+
+var generator = template.Must(template.New("generator").Parse(`package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/kdjuwidja/aishoppercommon/elasticsearch"
+	model {{ printf "%q" .Package }}
+)
+
+func main() {
+	mapping, err := elasticsearch.GenerateMapping(model.{{ .Type }}{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(mapping); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`))
+
+func main() {
+	fs := flag.NewFlagSet("esmapping", flag.ExitOnError)
+	pkg := fs.String("pkg", "", "import path of the package declaring the struct")
+	typeName := fs.String("type", "", "name of the struct")
+	dir := fs.String("dir", ".", "directory of the module the package is resolved from")
+	index := fs.String("index", "", "compare with the mapping of this index instead of printing the mapping")
+	fs.Parse(os.Args[1:])
+
+	if *pkg == "" || *typeName == "" {
+		fmt.Fprintln(os.Stderr, "-pkg and -type are required")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	mapping, err := generateMapping(ctx, *dir, *pkg, *typeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *index == "" {
+		out, _ := json.MarshalIndent(mapping, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	differences, err := diffIndex(ctx, *index, mapping)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, difference := range differences {
+		fmt.Println(difference)
+	}
+	if len(differences) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("mapping of %s matches %s.%s\n", *index, path.Base(*pkg), *typeName)
+}
+
+// generateMapping runs GenerateMapping on the struct in a throwaway program built inside the module
+func generateMapping(ctx context.Context, dir string, pkg string, typeName string) (map[string]interface{}, error) {
+	tmp, err := os.MkdirTemp(dir, ".esmapping-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating generator directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	var program bytes.Buffer
+	if err := generator.Execute(&program, struct{ Package, Type string }{pkg, typeName}); err != nil {
+		return nil, fmt.Errorf("error writing generator: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "main.go"), program.Bytes(), 0o644); err != nil {
+		return nil, fmt.Errorf("error writing generator: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "run", "./"+filepath.Base(tmp))
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error generating mapping of %s.%s: %v\n%s", pkg, typeName, err, stderr.String())
+	}
+
+	var mapping map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &mapping); err != nil {
+		return nil, fmt.Errorf("error parsing generated mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+func diffIndex(ctx context.Context, index string, mapping map[string]interface{}) ([]elasticsearch.MappingDifference, error) {
+	client, err := elasticsearch.NewElasticsearchClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return client.DiffIndexMapping(ctx, index, mapping)
+}
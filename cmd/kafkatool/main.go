@@ -0,0 +1,164 @@
+// kafkatool resets consumer group offsets and dumps topic ranges to JSON lines.
+//
+// The brokers are taken from KAFKA_BOOTSTRAP_SERVERS, like every other component built on the kafka package.
+//
+//	kafkatool reset -group search-indexer -topic products -to timestamp -to-timestamp 2025-03-01T00:00:00Z
+//	kafkatool reset -group search-indexer -topic products -to offset -to-offsets 0=1200,1=1180
+//	kafkatool dump -topic products -from timestamp -from-timestamp 2025-03-01T00:00:00Z -out products.jsonl
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kdjuwidja/aishoppercommon/kafka"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "reset":
+		err = runReset(ctx, os.Args[2:])
+	case "dump":
+		err = runDump(ctx, os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: kafkatool reset|dump [flags]")
+	fmt.Fprintln(os.Stderr, "run kafkatool <command> -h for the flags of a command")
+}
+
+// offsetFlags registers the flags describing a position in a topic under the given prefix
+type offsetFlags struct {
+	position  *string
+	timestamp *string
+	offsets   *string
+}
+
+func registerOffsetFlags(fs *flag.FlagSet, prefix string, defaultPosition string) offsetFlags {
+	return offsetFlags{
+		position:  fs.String(prefix, defaultPosition, "position: earliest, latest, timestamp or offset"),
+		timestamp: fs.String(prefix+"-timestamp", "", "RFC 3339 timestamp, used with timestamp"),
+		offsets:   fs.String(prefix+"-offsets", "", "partition=offset pairs separated by commas, used with offset"),
+	}
+}
+
+func (f offsetFlags) spec() (kafka.OffsetSpec, error) {
+	position, err := kafka.ParseOffsetPosition(*f.position)
+	if err != nil {
+		return kafka.OffsetSpec{}, err
+	}
+
+	spec := kafka.OffsetSpec{Position: position}
+	switch position {
+	case kafka.OffsetTimestamp:
+		spec.Timestamp, err = time.Parse(time.RFC3339, *f.timestamp)
+		if err != nil {
+			return kafka.OffsetSpec{}, fmt.Errorf("invalid timestamp: %v", err)
+		}
+	case kafka.OffsetExplicit:
+		spec.Offsets, err = kafka.ParsePartitionOffsets(*f.offsets)
+		if err != nil {
+			return kafka.OffsetSpec{}, err
+		}
+	}
+
+	return spec, nil
+}
+
+func runReset(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reset", flag.ExitOnError)
+	group := fs.String("group", "", "consumer group to reset, must have no active members")
+	topic := fs.String("topic", "", "topic to reset the group's offsets on")
+	to := registerOffsetFlags(fs, "to", "")
+	fs.Parse(args)
+
+	if *group == "" || *topic == "" || *to.position == "" {
+		fs.Usage()
+		return fmt.Errorf("-group, -topic and -to are required")
+	}
+
+	spec, err := to.spec()
+	if err != nil {
+		return err
+	}
+
+	factory, err := kafka.GetKafkaFactory()
+	if err != nil {
+		return err
+	}
+
+	committed, err := factory.ResetConsumerGroupOffsets(ctx, *group, *topic, spec)
+	if err != nil {
+		return err
+	}
+
+	for _, tp := range committed {
+		fmt.Printf("%s[%d] -> %d\n", *topic, tp.Partition, tp.Offset)
+	}
+	return nil
+}
+
+func runDump(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	topic := fs.String("topic", "", "topic to dump")
+	from := registerOffsetFlags(fs, "from", "earliest")
+	to := registerOffsetFlags(fs, "to", "latest")
+	out := fs.String("out", "", "file to write JSON lines to, defaults to stdout")
+	fs.Parse(args)
+
+	if *topic == "" {
+		fs.Usage()
+		return fmt.Errorf("-topic is required")
+	}
+
+	fromSpec, err := from.spec()
+	if err != nil {
+		return fmt.Errorf("invalid -from: %v", err)
+	}
+	toSpec, err := to.spec()
+	if err != nil {
+		return fmt.Errorf("invalid -to: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	factory, err := kafka.GetKafkaFactory()
+	if err != nil {
+		return err
+	}
+
+	count, err := factory.DumpMessages(ctx, *topic, fromSpec, toSpec, w)
+	fmt.Fprintf(os.Stderr, "Dumped %d messages from %s\n", count, *topic)
+	return err
+}
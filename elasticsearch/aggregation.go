@@ -0,0 +1,482 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Aggregation is an aggregation of the Elasticsearch query DSL
+type Aggregation interface {
+	// Source returns the JSON body of the aggregation, or an error if it is invalid
+	Source() (map[string]interface{}, error)
+}
+
+// subAggregations holds the named aggregations nested under a bucket aggregation
+type subAggregations struct {
+	aggs map[string]Aggregation
+}
+
+func (s *subAggregations) add(name string, agg Aggregation) {
+	if s.aggs == nil {
+		s.aggs = map[string]Aggregation{}
+	}
+	s.aggs[name] = agg
+}
+
+// source adds the sub-aggregations to the body of their parent aggregation
+func (s *subAggregations) source(body map[string]interface{}) error {
+	if len(s.aggs) == 0 {
+		return nil
+	}
+	aggs, err := aggregationSources(s.aggs)
+	if err != nil {
+		return err
+	}
+	body["aggs"] = aggs
+	return nil
+}
+
+// aggregationSources returns the bodies of a set of named aggregations
+func aggregationSources(aggs map[string]Aggregation) (map[string]interface{}, error) {
+	sources := make(map[string]interface{}, len(aggs))
+	for name, agg := range aggs {
+		if name == "" {
+			return nil, fmt.Errorf("aggregation name cannot be empty")
+		}
+		if agg == nil {
+			return nil, fmt.Errorf("aggregation %s cannot be nil", name)
+		}
+		source, err := agg.Source()
+		if err != nil {
+			return nil, fmt.Errorf("aggregation %s: %w", name, err)
+		}
+		sources[name] = source
+	}
+	return sources, nil
+}
+
+// TermsAggregation buckets documents by the distinct values of a field, e.g. brands
+type TermsAggregation struct {
+	subAggregations
+	field       string
+	size        *int
+	minDocCount *int
+	order       map[string]interface{}
+	missing     interface{}
+}
+
+func NewTermsAggregation(field string) *TermsAggregation {
+	return &TermsAggregation{field: field}
+}
+
+func (a *TermsAggregation) Size(size int) *TermsAggregation {
+	a.size = &size
+	return a
+}
+
+func (a *TermsAggregation) MinDocCount(count int) *TermsAggregation {
+	a.minDocCount = &count
+	return a
+}
+
+// Order sorts the buckets, by _count, _key or the name of a single value sub-aggregation
+func (a *TermsAggregation) Order(key string, order SortOrder) *TermsAggregation {
+	a.order = map[string]interface{}{key: string(order)}
+	return a
+}
+
+// Missing puts documents without the field into a bucket with the given key
+func (a *TermsAggregation) Missing(key interface{}) *TermsAggregation {
+	a.missing = key
+	return a
+}
+
+func (a *TermsAggregation) SubAggregation(name string, agg Aggregation) *TermsAggregation {
+	a.add(name, agg)
+	return a
+}
+
+func (a *TermsAggregation) Source() (map[string]interface{}, error) {
+	if a.field == "" {
+		return nil, fmt.Errorf("terms aggregation requires a field")
+	}
+	if a.size != nil && *a.size <= 0 {
+		return nil, fmt.Errorf("terms aggregation size must be positive")
+	}
+
+	terms := map[string]interface{}{"field": a.field}
+	if a.size != nil {
+		terms["size"] = *a.size
+	}
+	if a.minDocCount != nil {
+		terms["min_doc_count"] = *a.minDocCount
+	}
+	if a.order != nil {
+		terms["order"] = a.order
+	}
+	if a.missing != nil {
+		terms["missing"] = a.missing
+	}
+
+	body := map[string]interface{}{"terms": terms}
+	if err := a.source(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// RangeAggregation buckets documents by ranges of a numeric field, e.g. price bands
+type RangeAggregation struct {
+	subAggregations
+	field  string
+	ranges []map[string]interface{}
+}
+
+func NewRangeAggregation(field string) *RangeAggregation {
+	return &RangeAggregation{field: field}
+}
+
+// AddRange adds a bucket from (inclusive) to (exclusive), nil leaves a side unbounded
+func (a *RangeAggregation) AddRange(from interface{}, to interface{}) *RangeAggregation {
+	return a.AddKeyedRange("", from, to)
+}
+
+// AddKeyedRange adds a bucket with a key, e.g. "under-50"
+func (a *RangeAggregation) AddKeyedRange(key string, from interface{}, to interface{}) *RangeAggregation {
+	r := map[string]interface{}{}
+	setIfNotEmpty(r, "key", key)
+	if from != nil {
+		r["from"] = from
+	}
+	if to != nil {
+		r["to"] = to
+	}
+	a.ranges = append(a.ranges, r)
+	return a
+}
+
+func (a *RangeAggregation) SubAggregation(name string, agg Aggregation) *RangeAggregation {
+	a.add(name, agg)
+	return a
+}
+
+func (a *RangeAggregation) Source() (map[string]interface{}, error) {
+	if a.field == "" {
+		return nil, fmt.Errorf("range aggregation requires a field")
+	}
+	if len(a.ranges) == 0 {
+		return nil, fmt.Errorf("range aggregation on %s requires at least one range", a.field)
+	}
+	for _, r := range a.ranges {
+		_, hasFrom := r["from"]
+		_, hasTo := r["to"]
+		if !hasFrom && !hasTo {
+			return nil, fmt.Errorf("range aggregation on %s has a range without bounds", a.field)
+		}
+	}
+
+	body := map[string]interface{}{"range": map[string]interface{}{
+		"field":  a.field,
+		"ranges": a.ranges,
+	}}
+	if err := a.source(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// HistogramAggregation buckets documents by fixed size intervals of a numeric field
+type HistogramAggregation struct {
+	subAggregations
+	field       string
+	interval    float64
+	minDocCount *int
+}
+
+func NewHistogramAggregation(field string, interval float64) *HistogramAggregation {
+	return &HistogramAggregation{field: field, interval: interval}
+}
+
+func (a *HistogramAggregation) MinDocCount(count int) *HistogramAggregation {
+	a.minDocCount = &count
+	return a
+}
+
+func (a *HistogramAggregation) SubAggregation(name string, agg Aggregation) *HistogramAggregation {
+	a.add(name, agg)
+	return a
+}
+
+func (a *HistogramAggregation) Source() (map[string]interface{}, error) {
+	if a.field == "" {
+		return nil, fmt.Errorf("histogram aggregation requires a field")
+	}
+	if a.interval <= 0 {
+		return nil, fmt.Errorf("histogram aggregation on %s requires a positive interval", a.field)
+	}
+
+	histogram := map[string]interface{}{
+		"field":    a.field,
+		"interval": a.interval,
+	}
+	if a.minDocCount != nil {
+		histogram["min_doc_count"] = *a.minDocCount
+	}
+
+	body := map[string]interface{}{"histogram": histogram}
+	if err := a.source(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// DateHistogramAggregation buckets documents by calendar or fixed intervals of a date field
+type DateHistogramAggregation struct {
+	subAggregations
+	field            string
+	calendarInterval string
+	fixedInterval    string
+	format           string
+	timeZone         string
+	minDocCount      *int
+}
+
+func NewDateHistogramAggregation(field string) *DateHistogramAggregation {
+	return &DateHistogramAggregation{field: field}
+}
+
+// CalendarInterval sets a calendar aware interval, e.g. day, week, month
+func (a *DateHistogramAggregation) CalendarInterval(interval string) *DateHistogramAggregation {
+	a.calendarInterval = interval
+	return a
+}
+
+// FixedInterval sets a fixed interval, e.g. 12h or 30d
+func (a *DateHistogramAggregation) FixedInterval(interval string) *DateHistogramAggregation {
+	a.fixedInterval = interval
+	return a
+}
+
+func (a *DateHistogramAggregation) Format(format string) *DateHistogramAggregation {
+	a.format = format
+	return a
+}
+
+func (a *DateHistogramAggregation) TimeZone(timeZone string) *DateHistogramAggregation {
+	a.timeZone = timeZone
+	return a
+}
+
+func (a *DateHistogramAggregation) MinDocCount(count int) *DateHistogramAggregation {
+	a.minDocCount = &count
+	return a
+}
+
+func (a *DateHistogramAggregation) SubAggregation(name string, agg Aggregation) *DateHistogramAggregation {
+	a.add(name, agg)
+	return a
+}
+
+func (a *DateHistogramAggregation) Source() (map[string]interface{}, error) {
+	if a.field == "" {
+		return nil, fmt.Errorf("date_histogram aggregation requires a field")
+	}
+	if (a.calendarInterval == "") == (a.fixedInterval == "") {
+		return nil, fmt.Errorf("date_histogram aggregation on %s requires exactly one of calendar or fixed interval", a.field)
+	}
+
+	histogram := map[string]interface{}{"field": a.field}
+	setIfNotEmpty(histogram, "calendar_interval", a.calendarInterval)
+	setIfNotEmpty(histogram, "fixed_interval", a.fixedInterval)
+	setIfNotEmpty(histogram, "format", a.format)
+	setIfNotEmpty(histogram, "time_zone", a.timeZone)
+	if a.minDocCount != nil {
+		histogram["min_doc_count"] = *a.minDocCount
+	}
+
+	body := map[string]interface{}{"date_histogram": histogram}
+	if err := a.source(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// StatsAggregation computes count, min, max, avg and sum of a numeric field
+type StatsAggregation struct {
+	field string
+}
+
+func NewStatsAggregation(field string) *StatsAggregation {
+	return &StatsAggregation{field: field}
+}
+
+func (a *StatsAggregation) Source() (map[string]interface{}, error) {
+	if a.field == "" {
+		return nil, fmt.Errorf("stats aggregation requires a field")
+	}
+	return map[string]interface{}{"stats": map[string]interface{}{"field": a.field}}, nil
+}
+
+// NestedAggregation aggregates over nested objects, its sub-aggregations see the nested documents
+type NestedAggregation struct {
+	subAggregations
+	path string
+}
+
+func NewNestedAggregation(path string) *NestedAggregation {
+	return &NestedAggregation{path: path}
+}
+
+func (a *NestedAggregation) SubAggregation(name string, agg Aggregation) *NestedAggregation {
+	a.add(name, agg)
+	return a
+}
+
+func (a *NestedAggregation) Source() (map[string]interface{}, error) {
+	if a.path == "" {
+		return nil, fmt.Errorf("nested aggregation requires a path")
+	}
+
+	body := map[string]interface{}{"nested": map[string]interface{}{"path": a.path}}
+	if err := a.source(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// FilterAggregation narrows its sub-aggregations to the documents matching a query
+type FilterAggregation struct {
+	subAggregations
+	filter Query
+}
+
+func NewFilterAggregation(filter Query) *FilterAggregation {
+	return &FilterAggregation{filter: filter}
+}
+
+func (a *FilterAggregation) SubAggregation(name string, agg Aggregation) *FilterAggregation {
+	a.add(name, agg)
+	return a
+}
+
+func (a *FilterAggregation) Source() (map[string]interface{}, error) {
+	if a.filter == nil {
+		return nil, fmt.Errorf("filter aggregation requires a query")
+	}
+	filter, err := a.filter.Source()
+	if err != nil {
+		return nil, fmt.Errorf("filter aggregation: %w", err)
+	}
+
+	body := map[string]interface{}{"filter": filter}
+	if err := a.source(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Aggregations holds the raw aggregation results of a search or bucket, by name
+type Aggregations map[string]json.RawMessage
+
+// BucketAggregation is the result of a terms, range, histogram or date_histogram aggregation
+type BucketAggregation struct {
+	Buckets                 []Bucket `json:"buckets"`
+	SumOtherDocCount        int64    `json:"sum_other_doc_count"`
+	DocCountErrorUpperBound int64    `json:"doc_count_error_upper_bound"`
+}
+
+// Bucket is a single bucket of a bucket aggregation. From and To are only set by range aggregations.
+type Bucket struct {
+	Key          interface{}
+	KeyAsString  string
+	DocCount     int64
+	From         *float64
+	To           *float64
+	Aggregations Aggregations
+}
+
+// UnmarshalJSON decodes a bucket, collecting every other field as a sub-aggregation
+func (b *Bucket) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*b = Bucket{Aggregations: Aggregations{}}
+	for name, value := range fields {
+		var err error
+		switch name {
+		case "key":
+			err = json.Unmarshal(value, &b.Key)
+		case "key_as_string":
+			err = json.Unmarshal(value, &b.KeyAsString)
+		case "doc_count":
+			err = json.Unmarshal(value, &b.DocCount)
+		case "from":
+			err = json.Unmarshal(value, &b.From)
+		case "to":
+			err = json.Unmarshal(value, &b.To)
+		case "from_as_string", "to_as_string":
+		default:
+			b.Aggregations[name] = value
+		}
+		if err != nil {
+			return fmt.Errorf("error decoding bucket %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// StatsResult is the result of a stats aggregation, the values are nil when no document had the field
+type StatsResult struct {
+	Count int64    `json:"count"`
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Avg   *float64 `json:"avg"`
+	Sum   float64  `json:"sum"`
+}
+
+// SingleBucketResult is the result of a nested or filter aggregation
+type SingleBucketResult struct {
+	DocCount     int64
+	Aggregations Aggregations
+}
+
+// Buckets decodes the result of a terms, range, histogram or date_histogram aggregation
+func (a Aggregations) Buckets(name string) (*BucketAggregation, error) {
+	var result BucketAggregation
+	if err := a.decode(name, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Stats decodes the result of a stats aggregation
+func (a Aggregations) Stats(name string) (*StatsResult, error) {
+	var result StatsResult
+	if err := a.decode(name, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Single decodes the result of a nested or filter aggregation
+func (a Aggregations) Single(name string) (*SingleBucketResult, error) {
+	var bucket Bucket
+	if err := a.decode(name, &bucket); err != nil {
+		return nil, err
+	}
+	return &SingleBucketResult{DocCount: bucket.DocCount, Aggregations: bucket.Aggregations}, nil
+}
+
+func (a Aggregations) decode(name string, v interface{}) error {
+	raw, ok := a[name]
+	if !ok {
+		return fmt.Errorf("aggregation %s not found in response", name)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("error decoding aggregation %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregationSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		agg      Aggregation
+		wantJSON string
+		wantErr  bool
+	}{
+		{
+			name:     "terms",
+			agg:      NewTermsAggregation("brand").Size(20).MinDocCount(1).Order("_count", SortDesc),
+			wantJSON: `{"terms":{"field":"brand","size":20,"min_doc_count":1,"order":{"_count":"desc"}}}`,
+		},
+		{
+			name:    "terms without field",
+			agg:     NewTermsAggregation(""),
+			wantErr: true,
+		},
+		{
+			name:    "terms with zero size",
+			agg:     NewTermsAggregation("brand").Size(0),
+			wantErr: true,
+		},
+		{
+			name:     "range",
+			agg:      NewRangeAggregation("price").AddKeyedRange("cheap", nil, 50).AddRange(50, 100).AddRange(100, nil),
+			wantJSON: `{"range":{"field":"price","ranges":[{"key":"cheap","to":50},{"from":50,"to":100},{"from":100}]}}`,
+		},
+		{
+			name:    "range without ranges",
+			agg:     NewRangeAggregation("price"),
+			wantErr: true,
+		},
+		{
+			name:    "range without bounds",
+			agg:     NewRangeAggregation("price").AddRange(nil, nil),
+			wantErr: true,
+		},
+		{
+			name:     "histogram",
+			agg:      NewHistogramAggregation("price", 25).MinDocCount(0),
+			wantJSON: `{"histogram":{"field":"price","interval":25,"min_doc_count":0}}`,
+		},
+		{
+			name:    "histogram without interval",
+			agg:     NewHistogramAggregation("price", 0),
+			wantErr: true,
+		},
+		{
+			name:     "date histogram",
+			agg:      NewDateHistogramAggregation("created_at").CalendarInterval("month").Format("yyyy-MM"),
+			wantJSON: `{"date_histogram":{"field":"created_at","calendar_interval":"month","format":"yyyy-MM"}}`,
+		},
+		{
+			name:    "date histogram with both intervals",
+			agg:     NewDateHistogramAggregation("created_at").CalendarInterval("month").FixedInterval("30d"),
+			wantErr: true,
+		},
+		{
+			name:    "date histogram without interval",
+			agg:     NewDateHistogramAggregation("created_at"),
+			wantErr: true,
+		},
+		{
+			name:     "stats",
+			agg:      NewStatsAggregation("price"),
+			wantJSON: `{"stats":{"field":"price"}}`,
+		},
+		{
+			name: "nested with sub-aggregation",
+			agg: NewNestedAggregation("variants").
+				SubAggregation("sizes", NewTermsAggregation("variants.size")),
+			wantJSON: `{"nested":{"path":"variants"},"aggs":{"sizes":{"terms":{"field":"variants.size"}}}}`,
+		},
+		{
+			name: "filter with sub-aggregation",
+			agg: NewFilterAggregation(NewTermQuery("in_stock", true)).
+				SubAggregation("price", NewStatsAggregation("price")),
+			wantJSON: `{"filter":{"term":{"in_stock":{"value":true}}},"aggs":{"price":{"stats":{"field":"price"}}}}`,
+		},
+		{
+			name:    "filter without query",
+			agg:     NewFilterAggregation(nil),
+			wantErr: true,
+		},
+		{
+			name:    "invalid sub-aggregation",
+			agg:     NewTermsAggregation("brand").SubAggregation("price", NewStatsAggregation("")),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := tt.agg.Source()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := json.Marshal(source)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantJSON, string(got))
+		})
+	}
+}
+
+func TestSearchBuilder_Aggregation(t *testing.T) {
+	query, err := NewSearchBuilder("products").
+		Size(0).
+		Aggregation("brands", NewTermsAggregation("brand")).
+		Build()
+	require.NoError(t, err)
+	got, err := json.Marshal(query.query)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"size":0,"aggs":{"brands":{"terms":{"field":"brand"}}}}`, string(got))
+
+	_, err = NewSearchBuilder("products").Aggregation("", NewTermsAggregation("brand")).Build()
+	assert.ErrorContains(t, err, "aggregation name cannot be empty")
+}
+
+func TestESQuery_AddAggregation(t *testing.T) {
+	query := CreateESQueryStr("products", `{"query":{"match_all":{}}}`)
+	require.NoError(t, query.AddAggregation("brands", NewTermsAggregation("brand")))
+	require.NoError(t, query.AddAggregation("price", NewStatsAggregation("price")))
+	got, err := json.Marshal(query.query)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"query":{"match_all":{}},"aggs":{"brands":{"terms":{"field":"brand"}},"price":{"stats":{"field":"price"}}}}`, string(got))
+
+	assert.Error(t, query.AddAggregation("broken", NewTermsAggregation("")))
+
+	// the long form of the key is kept
+	query = CreateESQueryStr("products", `{"aggregations":{"brands":{"terms":{"field":"brand"}}}}`)
+	require.NoError(t, query.AddAggregation("price", NewStatsAggregation("price")))
+	got, err = json.Marshal(query.query)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"aggregations":{"brands":{"terms":{"field":"brand"}},"price":{"stats":{"field":"price"}}}}`, string(got))
+
+	query = CreateESQueryStr("products", `{"aggs":{},"aggregations":{}}`)
+	assert.EqualError(t, query.AddAggregation("price", NewStatsAggregation("price")), "query cannot contain both aggs and aggregations")
+
+	query = CreateESQuery("products", map[string]interface{}{"aggs": []string{"brands"}})
+	assert.EqualError(t, query.AddAggregation("price", NewStatsAggregation("price")), "aggs of the query must be an object, got []string")
+}
+
+const testAggregationResponse = `{
+	"hits": {"hits": []},
+	"aggregations": {
+		"brands": {
+			"doc_count_error_upper_bound": 0,
+			"sum_other_doc_count": 7,
+			"buckets": [
+				{"key": "acme", "doc_count": 12, "price": {"count": 12, "min": 10, "max": 90, "avg": 45, "sum": 540}},
+				{"key": "globex", "doc_count": 3, "price": {"count": 0, "min": null, "max": null, "avg": null, "sum": 0}}
+			]
+		},
+		"prices": {
+			"buckets": [
+				{"key": "cheap", "to": 50, "doc_count": 8},
+				{"key": "50.0-*", "from": 50, "from_as_string": "50.0", "doc_count": 7}
+			]
+		},
+		"monthly": {
+			"buckets": [{"key_as_string": "2024-01", "key": 1704067200000, "doc_count": 4}]
+		},
+		"variants": {
+			"doc_count": 40,
+			"sizes": {"buckets": [{"key": 42, "doc_count": 9}]}
+		}
+	}
+}`
+
+func TestAggregations_Decode(t *testing.T) {
+	var result SearchResult
+	require.NoError(t, json.Unmarshal([]byte(testAggregationResponse), &result))
+
+	brands, err := result.Aggregations.Buckets("brands")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), brands.SumOtherDocCount)
+	require.Len(t, brands.Buckets, 2)
+	assert.Equal(t, "acme", brands.Buckets[0].Key)
+	assert.Equal(t, int64(12), brands.Buckets[0].DocCount)
+
+	price, err := brands.Buckets[0].Aggregations.Stats("price")
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), price.Count)
+	require.NotNil(t, price.Avg)
+	assert.Equal(t, 45.0, *price.Avg)
+	empty, err := brands.Buckets[1].Aggregations.Stats("price")
+	require.NoError(t, err)
+	assert.Nil(t, empty.Min)
+
+	prices, err := result.Aggregations.Buckets("prices")
+	require.NoError(t, err)
+	require.Len(t, prices.Buckets, 2)
+	assert.Nil(t, prices.Buckets[0].From)
+	require.NotNil(t, prices.Buckets[0].To)
+	assert.Equal(t, 50.0, *prices.Buckets[0].To)
+	assert.Equal(t, 50.0, *prices.Buckets[1].From)
+	assert.Empty(t, prices.Buckets[1].Aggregations)
+
+	monthly, err := result.Aggregations.Buckets("monthly")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01", monthly.Buckets[0].KeyAsString)
+	assert.Equal(t, 1704067200000.0, monthly.Buckets[0].Key)
+
+	variants, err := result.Aggregations.Single("variants")
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), variants.DocCount)
+	sizes, err := variants.Aggregations.Buckets("sizes")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), sizes.Buckets[0].DocCount)
+
+	_, err = result.Aggregations.Buckets("colors")
+	assert.ErrorContains(t, err, "aggregation colors not found")
+}
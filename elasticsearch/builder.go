@@ -0,0 +1,324 @@
+package elasticsearch
+
+import (
+	"fmt"
+)
+
+// SortOrder is the direction of a sort
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+// SearchBuilder builds an ESQuery from typed clauses and validates it on Build
+type SearchBuilder struct {
+	index          string
+	query          Query
+	sorts          []interface{}
+	from           *int
+	size           *int
+	sourceIncludes []string
+	sourceExcludes []string
+	sourceDisabled bool
+	highlight      *Highlight
+	aggregations   map[string]Aggregation
+	suggesters     map[string]Suggester
+	explain        bool
+	profile        bool
+	knn            []*KnnSearch
+	retriever      Retriever
+	errs           []error
+}
+
+// NewSearchBuilder creates a builder for a search on an index
+func NewSearchBuilder(index string) *SearchBuilder {
+	return &SearchBuilder{index: index}
+}
+
+func (b *SearchBuilder) Query(query Query) *SearchBuilder {
+	b.query = query
+	return b
+}
+
+// Sort appends a sort on a field, sorts are applied in the order they were added
+func (b *SearchBuilder) Sort(field string, order SortOrder) *SearchBuilder {
+	if field == "" {
+		b.errs = append(b.errs, fmt.Errorf("sort field cannot be empty"))
+		return b
+	}
+	if order != SortAsc && order != SortDesc {
+		b.errs = append(b.errs, fmt.Errorf("unknown sort order %q on %s", order, field))
+		return b
+	}
+	b.sorts = append(b.sorts, map[string]interface{}{field: map[string]interface{}{"order": string(order)}})
+	return b
+}
+
+// SortByScore appends a sort on relevance
+func (b *SearchBuilder) SortByScore() *SearchBuilder {
+	b.sorts = append(b.sorts, "_score")
+	return b
+}
+
+func (b *SearchBuilder) From(from int) *SearchBuilder {
+	b.from = &from
+	return b
+}
+
+func (b *SearchBuilder) Size(size int) *SearchBuilder {
+	b.size = &size
+	return b
+}
+
+// SourceIncludes restricts the returned _source to the given fields
+func (b *SearchBuilder) SourceIncludes(fields ...string) *SearchBuilder {
+	b.sourceIncludes = append(b.sourceIncludes, fields...)
+	return b
+}
+
+// SourceExcludes removes the given fields from the returned _source
+func (b *SearchBuilder) SourceExcludes(fields ...string) *SearchBuilder {
+	b.sourceExcludes = append(b.sourceExcludes, fields...)
+	return b
+}
+
+// DisableSource omits _source from the hits
+func (b *SearchBuilder) DisableSource() *SearchBuilder {
+	b.sourceDisabled = true
+	return b
+}
+
+func (b *SearchBuilder) Highlight(highlight *Highlight) *SearchBuilder {
+	b.highlight = highlight
+	return b
+}
+
+// Knn adds a kNN search on a dense_vector field. Combined with Query, the scores of both are summed.
+func (b *SearchBuilder) Knn(knn *KnnSearch) *SearchBuilder {
+	b.knn = append(b.knn, knn)
+	return b
+}
+
+// Retriever sets the retriever tree of the search, e.g. NewHybridRetriever. It cannot be combined with Query or Knn.
+func (b *SearchBuilder) Retriever(retriever Retriever) *SearchBuilder {
+	b.retriever = retriever
+	return b
+}
+
+// Aggregation adds a named aggregation, e.g. brand facets alongside the hits
+func (b *SearchBuilder) Aggregation(name string, agg Aggregation) *SearchBuilder {
+	if b.aggregations == nil {
+		b.aggregations = map[string]Aggregation{}
+	}
+	b.aggregations[name] = agg
+	return b
+}
+
+func (b *SearchBuilder) Suggester(name string, suggester Suggester) *SearchBuilder {
+	if b.suggesters == nil {
+		b.suggesters = map[string]Suggester{}
+	}
+	b.suggesters[name] = suggester
+	return b
+}
+
+// Explain returns the score explanation of every hit in Hit.Explanation
+func (b *SearchBuilder) Explain() *SearchBuilder {
+	b.explain = true
+	return b
+}
+
+// Profile returns the time spent in each part of the query in SearchResult.Profile
+func (b *SearchBuilder) Profile() *SearchBuilder {
+	b.profile = true
+	return b
+}
+
+// Build validates the search and returns it as an ESQuery
+func (b *SearchBuilder) Build() (*ESQuery, error) {
+	body, err := b.Source()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ESQuery{
+		index: b.index,
+		query: body,
+	}, nil
+}
+
+// Source validates the search and returns its JSON body
+func (b *SearchBuilder) Source() (map[string]interface{}, error) {
+	if b.index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
+	if len(b.errs) > 0 {
+		return nil, b.errs[0]
+	}
+
+	body := map[string]interface{}{}
+	if b.query != nil {
+		query, err := b.query.Source()
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		body["query"] = query
+	}
+
+	if len(b.knn) > 0 {
+		knn := make([]interface{}, 0, len(b.knn))
+		for _, k := range b.knn {
+			if k == nil {
+				return nil, fmt.Errorf("knn cannot be nil")
+			}
+			source, err := k.Source()
+			if err != nil {
+				return nil, fmt.Errorf("invalid knn: %w", err)
+			}
+			knn = append(knn, source)
+		}
+		body["knn"] = knn
+	}
+
+	if b.retriever != nil {
+		if b.query != nil || len(b.knn) > 0 {
+			return nil, fmt.Errorf("a retriever cannot be combined with a query or knn")
+		}
+		retriever, err := b.retriever.RetrieverSource()
+		if err != nil {
+			return nil, fmt.Errorf("invalid retriever: %w", err)
+		}
+		body["retriever"] = retriever
+	}
+
+	if len(b.sorts) > 0 {
+		body["sort"] = b.sorts
+	}
+	if b.from != nil {
+		if *b.from < 0 {
+			return nil, fmt.Errorf("from cannot be negative")
+		}
+		body["from"] = *b.from
+	}
+	if b.size != nil {
+		if *b.size < 0 {
+			return nil, fmt.Errorf("size cannot be negative")
+		}
+		body["size"] = *b.size
+	}
+
+	if b.sourceDisabled {
+		if len(b.sourceIncludes) > 0 || len(b.sourceExcludes) > 0 {
+			return nil, fmt.Errorf("source filtering cannot be combined with a disabled source")
+		}
+		body["_source"] = false
+	} else if len(b.sourceIncludes) > 0 || len(b.sourceExcludes) > 0 {
+		source := map[string]interface{}{}
+		if len(b.sourceIncludes) > 0 {
+			source["includes"] = b.sourceIncludes
+		}
+		if len(b.sourceExcludes) > 0 {
+			source["excludes"] = b.sourceExcludes
+		}
+		body["_source"] = source
+	}
+
+	if b.highlight != nil {
+		highlight, err := b.highlight.Source()
+		if err != nil {
+			return nil, fmt.Errorf("invalid highlight: %w", err)
+		}
+		body["highlight"] = highlight
+	}
+
+	if len(b.aggregations) > 0 {
+		aggs, err := aggregationSources(b.aggregations)
+		if err != nil {
+			return nil, fmt.Errorf("invalid aggregation: %w", err)
+		}
+		body["aggs"] = aggs
+	}
+
+	if len(b.suggesters) > 0 {
+		suggest, err := suggesterSources(b.suggesters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid suggester: %w", err)
+		}
+		body["suggest"] = suggest
+	}
+
+	if b.explain {
+		body["explain"] = true
+	}
+	if b.profile {
+		body["profile"] = true
+	}
+
+	return body, nil
+}
+
+// Highlight configures highlighting of matched terms in hits
+type Highlight struct {
+	fields            []string
+	preTags           []string
+	postTags          []string
+	fragmentSize      *int
+	numberOfFragments *int
+}
+
+// NewHighlight highlights the given fields
+func NewHighlight(fields ...string) *Highlight {
+	return &Highlight{fields: fields}
+}
+
+func (h *Highlight) Field(field string) *Highlight {
+	h.fields = append(h.fields, field)
+	return h
+}
+
+// Tags sets the markup placed around highlighted terms, <em></em> by default
+func (h *Highlight) Tags(preTag string, postTag string) *Highlight {
+	h.preTags = []string{preTag}
+	h.postTags = []string{postTag}
+	return h
+}
+
+func (h *Highlight) FragmentSize(size int) *Highlight {
+	h.fragmentSize = &size
+	return h
+}
+
+func (h *Highlight) NumberOfFragments(number int) *Highlight {
+	h.numberOfFragments = &number
+	return h
+}
+
+func (h *Highlight) Source() (map[string]interface{}, error) {
+	if len(h.fields) == 0 {
+		return nil, fmt.Errorf("highlight requires at least one field")
+	}
+
+	fields := make(map[string]interface{}, len(h.fields))
+	for _, field := range h.fields {
+		if field == "" {
+			return nil, fmt.Errorf("highlight field cannot be empty")
+		}
+		fields[field] = map[string]interface{}{}
+	}
+
+	body := map[string]interface{}{"fields": fields}
+	if len(h.preTags) > 0 {
+		body["pre_tags"] = h.preTags
+		body["post_tags"] = h.postTags
+	}
+	if h.fragmentSize != nil {
+		body["fragment_size"] = *h.fragmentSize
+	}
+	if h.numberOfFragments != nil {
+		body["number_of_fragments"] = *h.numberOfFragments
+	}
+
+	return body, nil
+}
@@ -0,0 +1,277 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryClauses(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    Query
+		wantJSON string
+		wantErr  bool
+	}{
+		{
+			name:     "match all",
+			query:    NewMatchAllQuery(),
+			wantJSON: `{"match_all":{}}`,
+		},
+		{
+			name:     "match with options",
+			query:    NewMatchQuery("name", "trail shoe").Operator("and").Fuzziness("AUTO").Boost(2),
+			wantJSON: `{"match":{"name":{"query":"trail shoe","operator":"and","fuzziness":"AUTO","boost":2}}}`,
+		},
+		{
+			name:    "match without field",
+			query:   NewMatchQuery("", "shoe"),
+			wantErr: true,
+		},
+		{
+			name:    "match with unknown operator",
+			query:   NewMatchQuery("name", "shoe").Operator("xor"),
+			wantErr: true,
+		},
+		{
+			name:     "multi match",
+			query:    NewMultiMatchQuery("trail", "name^3", "description").Type("best_fields"),
+			wantJSON: `{"multi_match":{"query":"trail","fields":["name^3","description"],"type":"best_fields"}}`,
+		},
+		{
+			name:    "multi match without fields",
+			query:   NewMultiMatchQuery("trail"),
+			wantErr: true,
+		},
+		{
+			name:    "multi match with unknown type",
+			query:   NewMultiMatchQuery("trail", "name").Type("best_field"),
+			wantErr: true,
+		},
+		{
+			name:     "term",
+			query:    NewTermQuery("brand", "acme"),
+			wantJSON: `{"term":{"brand":{"value":"acme"}}}`,
+		},
+		{
+			name:    "term without value",
+			query:   NewTermQuery("brand", nil),
+			wantErr: true,
+		},
+		{
+			name:     "terms",
+			query:    NewTermsQuery("brand", "acme", "globex"),
+			wantJSON: `{"terms":{"brand":["acme","globex"]}}`,
+		},
+		{
+			name:    "terms without values",
+			query:   NewTermsQuery("brand"),
+			wantErr: true,
+		},
+		{
+			name:     "range",
+			query:    NewRangeQuery("price").Gte(10).Lt(100),
+			wantJSON: `{"range":{"price":{"gte":10,"lt":100}}}`,
+		},
+		{
+			name:    "range without bounds",
+			query:   NewRangeQuery("price"),
+			wantErr: true,
+		},
+		{
+			name:     "exists",
+			query:    NewExistsQuery("discount"),
+			wantJSON: `{"exists":{"field":"discount"}}`,
+		},
+		{
+			name:     "nested",
+			query:    NewNestedQuery("variants", NewTermQuery("variants.size", "42")).ScoreMode("max"),
+			wantJSON: `{"nested":{"path":"variants","query":{"term":{"variants.size":{"value":"42"}}},"score_mode":"max"}}`,
+		},
+		{
+			name:    "nested without query",
+			query:   NewNestedQuery("variants", nil),
+			wantErr: true,
+		},
+		{
+			name:    "nested with invalid inner query",
+			query:   NewNestedQuery("variants", NewTermsQuery("variants.size")),
+			wantErr: true,
+		},
+		{
+			name: "bool",
+			query: NewBoolQuery().
+				Must(NewMatchQuery("name", "shoe")).
+				Filter(NewTermQuery("in_stock", true), NewRangeQuery("price").Lte(150)).
+				MustNot(NewTermQuery("brand", "acme")).
+				Should(NewTermQuery("color", "red")).
+				MinimumShouldMatch("1"),
+			wantJSON: `{"bool":{
+				"must":[{"match":{"name":{"query":"shoe"}}}],
+				"filter":[{"term":{"in_stock":{"value":true}}},{"range":{"price":{"lte":150}}}],
+				"must_not":[{"term":{"brand":{"value":"acme"}}}],
+				"should":[{"term":{"color":{"value":"red"}}}],
+				"minimum_should_match":"1"}}`,
+		},
+		{
+			name:    "bool with invalid clause",
+			query:   NewBoolQuery().Filter(NewRangeQuery("price")),
+			wantErr: true,
+		},
+		{
+			name:    "bool with nil clause",
+			query:   NewBoolQuery().Must(nil),
+			wantErr: true,
+		},
+		{
+			name:    "bool minimum should match without should",
+			query:   NewBoolQuery().Must(NewMatchAllQuery()).MinimumShouldMatch("1"),
+			wantErr: true,
+		},
+		{
+			name: "function score",
+			query: NewFunctionScoreQuery(NewMatchQuery("name", "shoe")).
+				Add(NewFieldValueFactorFunction("popularity").Modifier("log1p").Factor(1.2)).
+				AddWithFilter(NewTermQuery("brand", "acme"), NewWeightFunction(2), nil).
+				Add(NewDecayFunction("gauss", "price", 50, 20)).
+				ScoreMode("sum").
+				BoostMode("multiply"),
+			wantJSON: `{"function_score":{
+				"query":{"match":{"name":{"query":"shoe"}}},
+				"functions":[
+					{"field_value_factor":{"field":"popularity","modifier":"log1p","factor":1.2}},
+					{"weight":2,"filter":{"term":{"brand":{"value":"acme"}}}},
+					{"gauss":{"price":{"origin":50,"scale":20}}}
+				],
+				"score_mode":"sum",
+				"boost_mode":"multiply"}}`,
+		},
+		{
+			name:    "function score without functions",
+			query:   NewFunctionScoreQuery(NewMatchAllQuery()),
+			wantErr: true,
+		},
+		{
+			name:    "function score with unknown decay",
+			query:   NewFunctionScoreQuery(nil).Add(NewDecayFunction("cubic", "price", 50, 20)),
+			wantErr: true,
+		},
+		{
+			name:     "raw",
+			query:    RawQuery{"geo_distance": map[string]interface{}{"distance": "10km"}},
+			wantJSON: `{"geo_distance":{"distance":"10km"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := tt.query.Source()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := json.Marshal(source)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantJSON, string(got))
+		})
+	}
+}
+
+func TestSearchBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name     string
+		builder  *SearchBuilder
+		wantJSON string
+		wantErr  bool
+	}{
+		{
+			name: "full search",
+			builder: NewSearchBuilder("products").
+				Query(NewMatchQuery("name", "shoe")).
+				Sort("price", SortAsc).
+				SortByScore().
+				From(20).
+				Size(10).
+				SourceIncludes("name", "price").
+				Highlight(NewHighlight("name").Tags("<b>", "</b>").FragmentSize(100)),
+			wantJSON: `{
+				"query":{"match":{"name":{"query":"shoe"}}},
+				"sort":[{"price":{"order":"asc"}},"_score"],
+				"from":20,
+				"size":10,
+				"_source":{"includes":["name","price"]},
+				"highlight":{"fields":{"name":{}},"pre_tags":["<b>"],"post_tags":["</b>"],"fragment_size":100}}`,
+		},
+		{
+			name:     "disabled source",
+			builder:  NewSearchBuilder("products").DisableSource().Size(0),
+			wantJSON: `{"_source":false,"size":0}`,
+		},
+		{
+			name:    "empty index",
+			builder: NewSearchBuilder("").Query(NewMatchAllQuery()),
+			wantErr: true,
+		},
+		{
+			name:    "invalid query",
+			builder: NewSearchBuilder("products").Query(NewTermQuery("", "x")),
+			wantErr: true,
+		},
+		{
+			name:    "unknown sort order",
+			builder: NewSearchBuilder("products").Sort("price", "up"),
+			wantErr: true,
+		},
+		{
+			name:    "negative size",
+			builder: NewSearchBuilder("products").Size(-1),
+			wantErr: true,
+		},
+		{
+			name:    "negative from",
+			builder: NewSearchBuilder("products").From(-10),
+			wantErr: true,
+		},
+		{
+			name:    "source filtering with disabled source",
+			builder: NewSearchBuilder("products").DisableSource().SourceIncludes("name"),
+			wantErr: true,
+		},
+		{
+			name:    "highlight without fields",
+			builder: NewSearchBuilder("products").Highlight(NewHighlight()),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := tt.builder.Build()
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, query)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "products", query.index)
+			got, err := json.Marshal(query.query)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantJSON, string(got))
+		})
+	}
+}
+
+func TestParseESQuery(t *testing.T) {
+	query, err := ParseESQuery("products", `{"query":{"match_all":{}}}`)
+	require.NoError(t, err)
+	assert.Equal(t, CreateESQueryStr("products", `{"query":{"match_all":{}}}`), query)
+
+	_, err = ParseESQuery("products", `{"query":`)
+	assert.ErrorContains(t, err, "invalid query JSON")
+
+	_, err = ParseESQuery("", `{}`)
+	assert.ErrorContains(t, err, "index name cannot be empty")
+}
@@ -0,0 +1,440 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+const (
+	defaultBulkFlushCount    = 500
+	defaultBulkFlushBytes    = 5 * 1024 * 1024
+	defaultBulkFlushInterval = 30 * time.Second
+	defaultBulkMaxRetries    = 3
+	defaultBulkRetryBackoff  = time.Second
+)
+
+// BulkAction is the operation applied to a document by a bulk request
+type BulkAction string
+
+const (
+	BulkIndex  BulkAction = "index"
+	BulkCreate BulkAction = "create"
+	BulkUpdate BulkAction = "update"
+	BulkDelete BulkAction = "delete"
+)
+
+// BulkItem is a single operation queued on a BulkIndexer
+type BulkItem struct {
+	Action BulkAction
+	// Index defaults to the index of the BulkIndexerConfig
+	Index string
+	// ID is required for update and delete, Elasticsearch generates one for index and create when empty
+	ID string
+	// Document is the full document for index and create, and the partial document for update
+	Document interface{}
+	// OnFailure is called instead of BulkIndexerConfig.OnFailure when the item fails
+	OnFailure func(ctx context.Context, item BulkItem, res BulkItemResponse, err error)
+}
+
+// BulkItemResponse is the outcome of one item of a bulk request
+type BulkItemResponse struct {
+	Index       string      `json:"_index"`
+	ID          string      `json:"_id"`
+	Version     int64       `json:"_version"`
+	Result      string      `json:"result"`
+	Status      int         `json:"status"`
+	SeqNo       int64       `json:"_seq_no"`
+	PrimaryTerm int64       `json:"_primary_term"`
+	Error       *ErrorCause `json:"error"`
+}
+
+// BulkIndexerConfig configures a BulkIndexer, zero values use the defaults
+type BulkIndexerConfig struct {
+	// Index is used for items that do not set their own
+	Index string
+	// NumWorkers is the number of concurrent bulk requests, 1 by default
+	NumWorkers int
+	// FlushCount flushes a worker's batch once it holds this many items, 500 by default
+	FlushCount int
+	// FlushBytes flushes a worker's batch once its body reaches this size, 5MB by default
+	FlushBytes int
+	// FlushInterval flushes a worker's batch periodically, 30 seconds by default
+	FlushInterval time.Duration
+	// MaxRetries is how many times items rejected with 429 Too Many Requests are resent, 3 by default
+	MaxRetries int
+	// DisableRetry fails items rejected with 429 Too Many Requests right away, ignoring MaxRetries
+	DisableRetry bool
+	// RetryBackoff is the delay before the first retry, doubled on every retry, 1 second by default
+	RetryBackoff time.Duration
+	// Refresh is the refresh policy of every bulk request
+	Refresh RefreshPolicy
+	// OnFailure is called for every item that failed, err is set when the whole request failed
+	OnFailure func(ctx context.Context, item BulkItem, res BulkItemResponse, err error)
+}
+
+// BulkIndexerStats counts the items processed by a BulkIndexer
+type BulkIndexerStats struct {
+	NumAdded    uint64
+	NumFlushed  uint64
+	NumFailed   uint64
+	NumIndexed  uint64
+	NumCreated  uint64
+	NumUpdated  uint64
+	NumDeleted  uint64
+	NumRequests uint64
+	NumRetries  uint64
+}
+
+// BulkIndexer batches document operations into bulk requests sent by a pool of workers
+type BulkIndexer struct {
+	es     *ElasticsearchClient
+	ctx    context.Context
+	config BulkIndexerConfig
+	queue  chan *bulkEntry
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+
+	numAdded    atomic.Uint64
+	numFlushed  atomic.Uint64
+	numFailed   atomic.Uint64
+	numIndexed  atomic.Uint64
+	numCreated  atomic.Uint64
+	numUpdated  atomic.Uint64
+	numDeleted  atomic.Uint64
+	numRequests atomic.Uint64
+	numRetries  atomic.Uint64
+}
+
+// bulkEntry is an item encoded as its action line and optional body line
+type bulkEntry struct {
+	item BulkItem
+	meta []byte
+	body []byte
+}
+
+func (e *bulkEntry) size() int {
+	size := len(e.meta) + 1
+	if e.body != nil {
+		size += len(e.body) + 1
+	}
+	return size
+}
+
+// NewBulkIndexer starts a bulk indexer, ctx bounds every bulk request it sends. Close must be called to flush the last items.
+func (es *ElasticsearchClient) NewBulkIndexer(ctx context.Context, config BulkIndexerConfig) (*BulkIndexer, error) {
+	if config.NumWorkers < 0 || config.FlushCount < 0 || config.FlushBytes < 0 || config.MaxRetries < 0 {
+		return nil, fmt.Errorf("bulk indexer config values cannot be negative")
+	}
+	if _, err := newRequestOptions([]RequestOption{WithRefresh(config.Refresh)}); err != nil {
+		return nil, err
+	}
+	if config.NumWorkers == 0 {
+		config.NumWorkers = 1
+	}
+	if config.FlushCount == 0 {
+		config.FlushCount = defaultBulkFlushCount
+	}
+	if config.FlushBytes == 0 {
+		config.FlushBytes = defaultBulkFlushBytes
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultBulkFlushInterval
+	}
+	if config.DisableRetry {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultBulkMaxRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultBulkRetryBackoff
+	}
+
+	b := &BulkIndexer{
+		es:     es,
+		ctx:    ctx,
+		config: config,
+		queue:  make(chan *bulkEntry, config.NumWorkers),
+	}
+	for i := 0; i < config.NumWorkers; i++ {
+		b.wg.Add(1)
+		go b.worker()
+	}
+
+	return b, nil
+}
+
+// Add queues an item, blocking while all workers are busy
+func (b *BulkIndexer) Add(ctx context.Context, item BulkItem) error {
+	entry, err := b.encode(item)
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return fmt.Errorf("bulk indexer is closed")
+	}
+
+	select {
+	case b.queue <- entry:
+		b.numAdded.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes the queued items and waits for the workers to finish, or for ctx to be done
+func (b *BulkIndexer) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the counters of the indexer, they are final once Close returned
+func (b *BulkIndexer) Stats() BulkIndexerStats {
+	return BulkIndexerStats{
+		NumAdded:    b.numAdded.Load(),
+		NumFlushed:  b.numFlushed.Load(),
+		NumFailed:   b.numFailed.Load(),
+		NumIndexed:  b.numIndexed.Load(),
+		NumCreated:  b.numCreated.Load(),
+		NumUpdated:  b.numUpdated.Load(),
+		NumDeleted:  b.numDeleted.Load(),
+		NumRequests: b.numRequests.Load(),
+		NumRetries:  b.numRetries.Load(),
+	}
+}
+
+func (b *BulkIndexer) encode(item BulkItem) (*bulkEntry, error) {
+	if item.Index == "" {
+		item.Index = b.config.Index
+	}
+	if item.Index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
+
+	var body interface{}
+	switch item.Action {
+	case BulkIndex, BulkCreate:
+		if item.Document == nil {
+			return nil, fmt.Errorf("%s of document %q requires a document", item.Action, item.ID)
+		}
+		body = item.Document
+	case BulkUpdate:
+		if item.ID == "" || item.Document == nil {
+			return nil, fmt.Errorf("update requires a document id and a partial document")
+		}
+		body = map[string]interface{}{"doc": item.Document}
+	case BulkDelete:
+		if item.ID == "" {
+			return nil, fmt.Errorf("delete requires a document id")
+		}
+	default:
+		return nil, fmt.Errorf("unknown bulk action %q", item.Action)
+	}
+
+	meta := map[string]string{"_index": item.Index}
+	if item.ID != "" {
+		meta["_id"] = item.ID
+	}
+	metaBytes, err := json.Marshal(map[string]interface{}{string(item.Action): meta})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling bulk action: %w", err)
+	}
+
+	entry := &bulkEntry{item: item, meta: metaBytes}
+	if body != nil {
+		entry.body, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling document: %w", err)
+		}
+	}
+
+	return entry, nil
+}
+
+func (b *BulkIndexer) worker() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*bulkEntry
+	size := 0
+	for {
+		select {
+		case entry, ok := <-b.queue:
+			if !ok {
+				b.flush(batch)
+				return
+			}
+			batch = append(batch, entry)
+			size += entry.size()
+			if len(batch) >= b.config.FlushCount || size >= b.config.FlushBytes {
+				b.flush(batch)
+				batch, size = nil, 0
+			}
+		case <-ticker.C:
+			b.flush(batch)
+			batch, size = nil, 0
+		}
+	}
+}
+
+// flush sends a batch, resending the items rejected with 429 until they succeed or the retries are exhausted
+func (b *BulkIndexer) flush(batch []*bulkEntry) {
+	pending := batch
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			b.numRetries.Add(1)
+			select {
+			case <-time.After(b.config.RetryBackoff << (attempt - 1)):
+			case <-b.ctx.Done():
+				b.failAll(pending, b.ctx.Err())
+				return
+			}
+		}
+
+		responses, err := b.send(pending)
+		if err != nil {
+			b.failAll(pending, err)
+			return
+		}
+
+		var retry []*bulkEntry
+		for i, res := range responses {
+			entry := pending[i]
+			switch {
+			case res.Status == http.StatusTooManyRequests && attempt < b.config.MaxRetries:
+				retry = append(retry, entry)
+			case res.Error != nil || res.Status >= 300:
+				b.fail(entry, res, nil)
+			default:
+				b.succeed(entry)
+			}
+		}
+		pending = retry
+	}
+}
+
+// send performs one bulk request and returns the response of every entry, in order
+func (b *BulkIndexer) send(entries []*bulkEntry) ([]BulkItemResponse, error) {
+	var buffer bytes.Buffer
+	for _, entry := range entries {
+		buffer.Write(entry.meta)
+		buffer.WriteByte('\n')
+		if entry.body != nil {
+			buffer.Write(entry.body)
+			buffer.WriteByte('\n')
+		}
+	}
+
+	b.numRequests.Add(1)
+	req := esapi.BulkRequest{
+		Body:    bytes.NewReader(buffer.Bytes()),
+		Refresh: string(b.config.Refresh),
+	}
+	res, err := req.Do(b.ctx, b.es.client)
+	if err != nil {
+		return nil, fmt.Errorf("error performing bulk request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		responses := make([]BulkItemResponse, len(entries))
+		for i := range responses {
+			responses[i] = BulkItemResponse{Status: http.StatusTooManyRequests}
+		}
+		return responses, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("error performing bulk request: %s", res.String())
+	}
+
+	return decodeBulkResponse(res.Body, len(entries))
+}
+
+func (b *BulkIndexer) succeed(entry *bulkEntry) {
+	b.numFlushed.Add(1)
+	switch entry.item.Action {
+	case BulkIndex:
+		b.numIndexed.Add(1)
+	case BulkCreate:
+		b.numCreated.Add(1)
+	case BulkUpdate:
+		b.numUpdated.Add(1)
+	case BulkDelete:
+		b.numDeleted.Add(1)
+	}
+}
+
+func (b *BulkIndexer) fail(entry *bulkEntry, res BulkItemResponse, err error) {
+	b.numFailed.Add(1)
+	onFailure := entry.item.OnFailure
+	if onFailure == nil {
+		onFailure = b.config.OnFailure
+	}
+	if onFailure != nil {
+		onFailure(b.ctx, entry.item, res, err)
+	}
+}
+
+func (b *BulkIndexer) failAll(entries []*bulkEntry, err error) {
+	for _, entry := range entries {
+		b.fail(entry, BulkItemResponse{Index: entry.item.Index, ID: entry.item.ID}, err)
+	}
+}
+
+// decodeBulkResponse returns the item responses of a bulk response, which are keyed by their action
+func decodeBulkResponse(body io.Reader, items int) ([]BulkItemResponse, error) {
+	var raw struct {
+		Errors bool                          `json:"errors"`
+		Items  []map[string]BulkItemResponse `json:"items"`
+	}
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error parsing bulk response: %w", err)
+	}
+	if len(raw.Items) != items {
+		return nil, fmt.Errorf("bulk response has %d items for %d operations", len(raw.Items), items)
+	}
+
+	responses := make([]BulkItemResponse, len(raw.Items))
+	for i, item := range raw.Items {
+		if len(item) != 1 {
+			return nil, fmt.Errorf("bulk response item %d has %d actions", i, len(item))
+		}
+		for _, res := range item {
+			responses[i] = res
+		}
+	}
+
+	return responses, nil
+}
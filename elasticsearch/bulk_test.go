@@ -0,0 +1,286 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newTestClient returns a client whose requests are answered by handler instead of a cluster
+func newTestClient(t *testing.T, handler func(req *http.Request) (int, string)) *ElasticsearchClient {
+	client, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{"http://elasticsearch.test:9200"},
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			status, body := handler(req)
+			// like a real transport, a request whose context is done fails instead of returning a response
+			if err := req.Context().Err(); err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}, "Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		}),
+	})
+	require.NoError(t, err)
+	return &ElasticsearchClient{client: client}
+}
+
+// bulkActions returns the action of every operation of a bulk request body
+func bulkActions(t *testing.T, req *http.Request) []string {
+	var actions []string
+	scanner := bufio.NewScanner(req.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, action := range []BulkAction{BulkIndex, BulkCreate, BulkUpdate, BulkDelete} {
+			if strings.HasPrefix(line, fmt.Sprintf(`{"%s":`, action)) {
+				actions = append(actions, string(action))
+			}
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return actions
+}
+
+func bulkResponse(actions []string, status func(i int) int) string {
+	items := make([]string, len(actions))
+	for i, action := range actions {
+		s := status(i)
+		if s >= 300 {
+			items[i] = fmt.Sprintf(`{"%s":{"_index":"products","_id":"%d","status":%d,"error":{"type":"rejected","reason":"nope"}}}`, action, i, s)
+		} else {
+			items[i] = fmt.Sprintf(`{"%s":{"_index":"products","_id":"%d","status":%d,"result":"ok"}}`, action, i, s)
+		}
+	}
+	return `{"errors":true,"items":[` + strings.Join(items, ",") + `]}`
+}
+
+func TestBulkIndexer_FlushByCount(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		actions := bulkActions(t, req)
+		mu.Lock()
+		batches = append(batches, actions)
+		mu.Unlock()
+		return http.StatusOK, bulkResponse(actions, func(int) int { return http.StatusOK })
+	})
+
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{Index: "products", FlushCount: 2})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, indexer.Add(ctx, BulkItem{Action: BulkIndex, ID: "1", Document: testProduct{Name: "a"}}))
+	require.NoError(t, indexer.Add(ctx, BulkItem{Action: BulkCreate, ID: "2", Document: testProduct{Name: "b"}}))
+	require.NoError(t, indexer.Add(ctx, BulkItem{Action: BulkUpdate, ID: "3", Document: map[string]interface{}{"price": 10}}))
+	require.NoError(t, indexer.Add(ctx, BulkItem{Action: BulkDelete, ID: "4"}))
+	require.NoError(t, indexer.Add(ctx, BulkItem{Action: BulkDelete, ID: "5"}))
+	require.NoError(t, indexer.Close(ctx))
+
+	assert.Equal(t, [][]string{{"index", "create"}, {"update", "delete"}, {"delete"}}, batches)
+	assert.Equal(t, BulkIndexerStats{
+		NumAdded: 5, NumFlushed: 5, NumIndexed: 1, NumCreated: 1, NumUpdated: 1, NumDeleted: 2, NumRequests: 3,
+	}, indexer.Stats())
+
+	assert.Error(t, indexer.Add(ctx, BulkItem{Action: BulkDelete, ID: "6"}))
+}
+
+func TestBulkIndexer_FlushByInterval(t *testing.T) {
+	flushed := make(chan []string, 1)
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		actions := bulkActions(t, req)
+		flushed <- actions
+		return http.StatusOK, bulkResponse(actions, func(int) int { return http.StatusOK })
+	})
+
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{Index: "products", FlushInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Add(context.Background(), BulkItem{Action: BulkIndex, Document: testProduct{Name: "a"}}))
+
+	select {
+	case actions := <-flushed:
+		assert.Equal(t, []string{"index"}, actions)
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed on interval")
+	}
+	require.NoError(t, indexer.Close(context.Background()))
+}
+
+func TestBulkIndexer_FlushByBytes(t *testing.T) {
+	var requests int
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		requests++
+		actions := bulkActions(t, req)
+		return http.StatusOK, bulkResponse(actions, func(int) int { return http.StatusOK })
+	})
+
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{Index: "products", FlushBytes: 1})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, indexer.Add(context.Background(), BulkItem{Action: BulkIndex, Document: testProduct{Name: "a"}}))
+	}
+	require.NoError(t, indexer.Close(context.Background()))
+	assert.Equal(t, 3, requests)
+}
+
+func TestBulkIndexer_RetryAndFailures(t *testing.T) {
+	var attempts int
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		attempts++
+		actions := bulkActions(t, req)
+		switch attempts {
+		case 1:
+			// the second item is throttled, the third is rejected for good
+			return http.StatusOK, bulkResponse(actions, func(i int) int {
+				return []int{http.StatusCreated, http.StatusTooManyRequests, http.StatusConflict}[i]
+			})
+		case 2:
+			return http.StatusTooManyRequests, `{"error":"too many requests"}`
+		default:
+			return http.StatusOK, bulkResponse(actions, func(int) int { return http.StatusOK })
+		}
+	})
+
+	var mu sync.Mutex
+	var failures []string
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{
+		Index:        "products",
+		RetryBackoff: time.Millisecond,
+		OnFailure: func(ctx context.Context, item BulkItem, res BulkItemResponse, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			require.NotNil(t, res.Error)
+			failures = append(failures, item.ID+":"+res.Error.Type)
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, indexer.Add(ctx, BulkItem{Action: BulkCreate, ID: id, Document: testProduct{Name: id}}))
+	}
+	require.NoError(t, indexer.Close(ctx))
+
+	assert.Equal(t, []string{"3:rejected"}, failures)
+	stats := indexer.Stats()
+	assert.Equal(t, uint64(2), stats.NumCreated)
+	assert.Equal(t, uint64(1), stats.NumFailed)
+	assert.Equal(t, uint64(3), stats.NumRequests)
+	assert.Equal(t, uint64(2), stats.NumRetries)
+}
+
+func TestBulkIndexer_RetriesExhausted(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, bulkResponse(bulkActions(t, req), func(int) int { return http.StatusTooManyRequests })
+	})
+
+	var itemFailures int
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{Index: "products", MaxRetries: 2, RetryBackoff: time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Add(context.Background(), BulkItem{
+		Action: BulkDelete,
+		ID:     "1",
+		OnFailure: func(ctx context.Context, item BulkItem, res BulkItemResponse, err error) {
+			itemFailures++
+			assert.Equal(t, http.StatusTooManyRequests, res.Status)
+		},
+	}))
+	require.NoError(t, indexer.Close(context.Background()))
+
+	assert.Equal(t, 1, itemFailures)
+	assert.Equal(t, uint64(3), indexer.Stats().NumRequests)
+	assert.Equal(t, uint64(1), indexer.Stats().NumFailed)
+}
+
+func TestBulkIndexer_DisableRetry(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, bulkResponse(bulkActions(t, req), func(int) int { return http.StatusTooManyRequests })
+	})
+
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{Index: "products", MaxRetries: 2, DisableRetry: true})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Add(context.Background(), BulkItem{Action: BulkDelete, ID: "1"}))
+	require.NoError(t, indexer.Close(context.Background()))
+
+	stats := indexer.Stats()
+	assert.Equal(t, uint64(1), stats.NumRequests)
+	assert.Equal(t, uint64(0), stats.NumRetries)
+	assert.Equal(t, uint64(1), stats.NumFailed)
+}
+
+func TestBulkIndexer_RequestError(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusBadRequest, `{"error":{"type":"illegal_argument_exception","reason":"bad"}}`
+	})
+
+	var errs []error
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{
+		Index: "products",
+		OnFailure: func(ctx context.Context, item BulkItem, res BulkItemResponse, err error) {
+			errs = append(errs, err)
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Add(context.Background(), BulkItem{Action: BulkDelete, ID: "1"}))
+	require.NoError(t, indexer.Close(context.Background()))
+
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "illegal_argument_exception")
+}
+
+func TestBulkIndexer_AddValidation(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, `{"items":[]}`
+	})
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{})
+	require.NoError(t, err)
+	defer indexer.Close(context.Background())
+
+	tests := []struct {
+		name string
+		item BulkItem
+	}{
+		{name: "no index", item: BulkItem{Action: BulkIndex, Document: testProduct{}}},
+		{name: "unknown action", item: BulkItem{Action: "upsert", Index: "products"}},
+		{name: "index without document", item: BulkItem{Action: BulkIndex, Index: "products"}},
+		{name: "update without id", item: BulkItem{Action: BulkUpdate, Index: "products", Document: testProduct{}}},
+		{name: "delete without id", item: BulkItem{Action: BulkDelete, Index: "products"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, indexer.Add(context.Background(), tt.item))
+		})
+	}
+
+	_, err = client.NewBulkIndexer(context.Background(), BulkIndexerConfig{NumWorkers: -1})
+	assert.Error(t, err)
+}
+
+func TestDecodeBulkResponse(t *testing.T) {
+	responses, err := decodeBulkResponse(strings.NewReader(`{"errors":false,"items":[{"index":{"_index":"products","_id":"1","_version":2,"result":"updated","status":200,"_seq_no":5,"_primary_term":1}}]}`), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []BulkItemResponse{{Index: "products", ID: "1", Version: 2, Result: "updated", Status: 200, SeqNo: 5, PrimaryTerm: 1}}, responses)
+
+	_, err = decodeBulkResponse(strings.NewReader(`{"items":[]}`), 1)
+	assert.ErrorContains(t, err, "0 items for 1 operations")
+
+	_, err = decodeBulkResponse(strings.NewReader(`not json`), 1)
+	assert.Error(t, err)
+}
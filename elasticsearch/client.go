@@ -0,0 +1,69 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+)
+
+// DocumentClient writes, reads and deletes documents
+type DocumentClient interface {
+	IndexDocument(ctx context.Context, index string, document interface{}, opts ...RequestOption) error
+	IndexDocumentWithID(ctx context.Context, index string, id string, document interface{}, opts ...RequestOption) (*WriteResult, error)
+	GetDocument(ctx context.Context, index string, id string, opts ...RequestOption) (*Document, error)
+	Exists(ctx context.Context, index string, id string, opts ...RequestOption) (bool, error)
+	UpdateDocument(ctx context.Context, index string, id string, partial interface{}, opts ...RequestOption) (*WriteResult, error)
+	UpdateDocumentWithScript(ctx context.Context, index string, id string, script *Script, opts ...RequestOption) (*WriteResult, error)
+	Upsert(ctx context.Context, index string, id string, document interface{}, opts ...RequestOption) (*WriteResult, error)
+	DeleteDocument(ctx context.Context, index string, id string, opts ...RequestOption) (*WriteResult, error)
+	DeleteByQuery(ctx context.Context, query *ESQuery, opts ...RequestOption) (*ByQueryResult, error)
+	UpdateByQuery(ctx context.Context, query *ESQuery, script *Script, opts ...RequestOption) (*ByQueryResult, error)
+}
+
+// SearchClient runs searches and the tools to debug them
+type SearchClient interface {
+	Search(ctx context.Context, query *ESQuery, opts ...RequestOption) (*SearchResult, error)
+	SearchDocuments(ctx context.Context, query *ESQuery, opts ...RequestOption) ([]json.RawMessage, error)
+	SearchDocumentsWithMQuery(ctx context.Context, index string, query *MultiESQuery, opts ...RequestOption) ([][]json.RawMessage, error)
+	MultiSearch(ctx context.Context, index string, query *MultiESQuery, msOpts MultiSearchOptions, opts ...RequestOption) (*MultiSearchResult, error)
+	SearchFused(ctx context.Context, index string, query *MultiESQuery, fusion FusionOptions, opts ...RequestOption) ([]FusedHit, error)
+	Scan(ctx context.Context, query *ESQuery, opts ScanOptions) iter.Seq2[Hit, error]
+	Suggest(ctx context.Context, index string, suggesters map[string]Suggester, opts ...RequestOption) (Suggestions, error)
+	SearchTemplate(ctx context.Context, query *TemplateQuery, opts ...RequestOption) (*SearchResult, error)
+	MultiSearchTemplate(ctx context.Context, index string, query *MultiTemplateQuery, opts ...RequestOption) (*MultiSearchResult, error)
+	RenderSearchTemplate(ctx context.Context, query *TemplateQuery, opts ...RequestOption) (map[string]interface{}, error)
+	Explain(ctx context.Context, index string, id string, query Query, opts ...RequestOption) (*DocumentExplanation, error)
+	ValidateQuery(ctx context.Context, query *ESQuery, opts ...RequestOption) (*QueryValidation, error)
+}
+
+// IndexClient manages indices, their mappings, aliases and templates
+type IndexClient interface {
+	CreateIndex(ctx context.Context, index string, definition *IndexDefinition, opts ...RequestOption) error
+	IndexExists(ctx context.Context, index string, opts ...RequestOption) (bool, error)
+	DeleteIndex(ctx context.Context, index string, opts ...RequestOption) error
+	GetMapping(ctx context.Context, index string, opts ...RequestOption) (map[string]interface{}, error)
+	DiffIndexMapping(ctx context.Context, index string, expected map[string]interface{}, opts ...RequestOption) ([]MappingDifference, error)
+	PutIndexTemplate(ctx context.Context, name string, template *IndexTemplate, opts ...RequestOption) error
+	IndexTemplateExists(ctx context.Context, name string, opts ...RequestOption) (bool, error)
+	DeleteIndexTemplate(ctx context.Context, name string, opts ...RequestOption) error
+	GetAliasIndices(ctx context.Context, alias string, opts ...RequestOption) ([]string, error)
+	UpdateAliases(ctx context.Context, actions []AliasAction, opts ...RequestOption) error
+	Reindex(ctx context.Context, source string, dest string, opts ...RequestOption) (*ByQueryResult, error)
+	BlueGreenReindex(ctx context.Context, spec BlueGreenReindex) error
+	PutSearchTemplate(ctx context.Context, id string, source interface{}, opts ...RequestOption) error
+	DeleteSearchTemplate(ctx context.Context, id string, opts ...RequestOption) error
+}
+
+// Client is implemented by ElasticsearchClient. Depend on it, or on one of the smaller interfaces it is made of,
+// to replace the client with a mock in tests. NewBulkIndexer is left out as the indexer needs a real client,
+// use the estest package to fake the cluster instead.
+type Client interface {
+	DocumentClient
+	SearchClient
+	IndexClient
+	Ping(ctx context.Context, opts ...RequestOption) error
+	ClusterHealth(ctx context.Context, indices []string, opts ...RequestOption) (*ClusterHealth, error)
+	WaitUntilReady(ctx context.Context, minStatus HealthStatus) error
+}
+
+var _ Client = (*ElasticsearchClient)(nil)
@@ -0,0 +1,207 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/kdjuwidja/aishoppercommon/osutil"
+)
+
+// ClientConfig configures NewElasticsearchClientWithConfig. The zero value connects to the node in the
+// ELASTICSEARCH_URL environment variable, or http://localhost:9200, without authentication.
+type ClientConfig struct {
+	// Addresses are the URLs of the nodes, e.g. https://es1:9200. Requests are balanced between them.
+	Addresses []string
+	// CloudID connects to an Elastic Cloud deployment instead of Addresses
+	CloudID string
+
+	// At most one of basic auth, APIKey and ServiceToken can be set
+	Username string
+	Password string
+	// APIKey is the base64 encoded id:api_key returned when creating the key
+	APIKey string
+	// ServiceToken is sent as a bearer token
+	ServiceToken string
+
+	// CACert is the PEM encoded certificate of the authority that signed the certificate of the nodes
+	CACert []byte
+	// CertificateFingerprint is the SHA256 hex fingerprint of the certificate of the nodes,
+	// printed by Elasticsearch on first start. It can be used instead of CACert for self-signed certificates.
+	CertificateFingerprint string
+
+	// RetryOnStatus are the response statuses retried on another node, 502, 503 and 504 by default
+	RetryOnStatus []int
+	// MaxRetries is 3 by default
+	MaxRetries   int
+	DisableRetry bool
+	// RetryBackoff is the wait before the first retry, doubled on every attempt. No wait by default.
+	RetryBackoff time.Duration
+
+	// CompressRequestBody gzips the request bodies, e.g. for large bulk requests
+	CompressRequestBody bool
+
+	// Transport replaces the HTTP transport, e.g. to go through a proxy. CACert cannot be used with it.
+	Transport http.RoundTripper
+
+	// Instrumentation records metrics, logs slow queries and propagates trace headers when set
+	Instrumentation *InstrumentationConfig
+}
+
+// ClientConfigFromEnv reads the client configuration from the environment:
+//
+//	ELASTICSEARCH_ADDRESSES          comma separated node URLs
+//	ELASTICSEARCH_CLOUD_ID
+//	ELASTICSEARCH_USERNAME, ELASTICSEARCH_PASSWORD
+//	ELASTICSEARCH_API_KEY
+//	ELASTICSEARCH_SERVICE_TOKEN
+//	ELASTICSEARCH_CA_CERT            path to a PEM file
+//	ELASTICSEARCH_CERT_FINGERPRINT
+//	ELASTICSEARCH_RETRY_ON_STATUS    comma separated statuses
+//	ELASTICSEARCH_MAX_RETRIES
+//	ELASTICSEARCH_DISABLE_RETRY      true or false
+//	ELASTICSEARCH_RETRY_BACKOFF      a duration, e.g. 100ms
+//	ELASTICSEARCH_COMPRESS           true or false
+func ClientConfigFromEnv() (ClientConfig, error) {
+	cfg := ClientConfig{
+		Addresses:              osutil.GetEnvStringSlice("ELASTICSEARCH_ADDRESSES", nil),
+		CloudID:                osutil.GetEnvString("ELASTICSEARCH_CLOUD_ID", ""),
+		Username:               osutil.GetEnvString("ELASTICSEARCH_USERNAME", ""),
+		Password:               osutil.GetEnvString("ELASTICSEARCH_PASSWORD", ""),
+		APIKey:                 osutil.GetEnvString("ELASTICSEARCH_API_KEY", ""),
+		ServiceToken:           osutil.GetEnvString("ELASTICSEARCH_SERVICE_TOKEN", ""),
+		CertificateFingerprint: osutil.GetEnvString("ELASTICSEARCH_CERT_FINGERPRINT", ""),
+		MaxRetries:             osutil.GetEnvInt("ELASTICSEARCH_MAX_RETRIES", 0),
+		DisableRetry:           osutil.GetEnvBool("ELASTICSEARCH_DISABLE_RETRY", false),
+		CompressRequestBody:    osutil.GetEnvBool("ELASTICSEARCH_COMPRESS", false),
+	}
+
+	if path := osutil.GetEnvString("ELASTICSEARCH_CA_CERT", ""); path != "" {
+		caCert, err := os.ReadFile(path)
+		if err != nil {
+			return ClientConfig{}, fmt.Errorf("error reading ELASTICSEARCH_CA_CERT: %w", err)
+		}
+		cfg.CACert = caCert
+	}
+
+	for _, status := range osutil.GetEnvStringSlice("ELASTICSEARCH_RETRY_ON_STATUS", nil) {
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return ClientConfig{}, fmt.Errorf("invalid status %q in ELASTICSEARCH_RETRY_ON_STATUS", status)
+		}
+		cfg.RetryOnStatus = append(cfg.RetryOnStatus, code)
+	}
+
+	if backoff := osutil.GetEnvString("ELASTICSEARCH_RETRY_BACKOFF", ""); backoff != "" {
+		d, err := time.ParseDuration(backoff)
+		if err != nil {
+			return ClientConfig{}, fmt.Errorf("invalid ELASTICSEARCH_RETRY_BACKOFF: %w", err)
+		}
+		cfg.RetryBackoff = d
+	}
+
+	return cfg, nil
+}
+
+func (c ClientConfig) validate() error {
+	if c.CloudID != "" && len(c.Addresses) > 0 {
+		return fmt.Errorf("addresses and cloud id cannot both be set")
+	}
+	for _, address := range c.Addresses {
+		u, err := url.Parse(address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return fmt.Errorf("invalid address %q, expected http(s)://host:port", address)
+		}
+	}
+
+	credentials := 0
+	if c.Username != "" || c.Password != "" {
+		if c.Username == "" || c.Password == "" {
+			return fmt.Errorf("basic auth requires both a username and a password")
+		}
+		credentials++
+	}
+	if c.APIKey != "" {
+		credentials++
+	}
+	if c.ServiceToken != "" {
+		credentials++
+	}
+	if credentials > 1 {
+		return fmt.Errorf("only one of basic auth, api key and service token can be set")
+	}
+
+	if c.CACert != nil && c.Transport != nil {
+		return fmt.Errorf("a ca certificate cannot be used with a custom transport")
+	}
+	for _, status := range c.RetryOnStatus {
+		if status < 400 || status > 599 {
+			return fmt.Errorf("invalid retry status %d", status)
+		}
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("max retries cannot be negative")
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("retry backoff cannot be negative")
+	}
+	if c.Instrumentation != nil {
+		if err := c.Instrumentation.validate(); err != nil {
+			return fmt.Errorf("invalid instrumentation: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewElasticsearchClientWithConfig creates a client for a cluster with TLS, authentication or several nodes
+func NewElasticsearchClientWithConfig(cfg ClientConfig) (*ElasticsearchClient, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch config: %w", err)
+	}
+
+	esConfig := elasticsearch.Config{
+		Addresses:              cfg.Addresses,
+		CloudID:                cfg.CloudID,
+		Username:               cfg.Username,
+		Password:               cfg.Password,
+		APIKey:                 cfg.APIKey,
+		ServiceToken:           cfg.ServiceToken,
+		CACert:                 cfg.CACert,
+		CertificateFingerprint: cfg.CertificateFingerprint,
+		RetryOnStatus:          cfg.RetryOnStatus,
+		MaxRetries:             cfg.MaxRetries,
+		DisableRetry:           cfg.DisableRetry,
+		CompressRequestBody:    cfg.CompressRequestBody,
+		Transport:              cfg.Transport,
+	}
+	if cfg.RetryBackoff > 0 {
+		esConfig.RetryBackoff = func(attempt int) time.Duration {
+			return cfg.RetryBackoff << (attempt - 1)
+		}
+	}
+
+	client, err := elasticsearch.NewClient(esConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating elasticsearch client: %w", err)
+	}
+	if cfg.Instrumentation != nil {
+		client.Transport = newInstrumentedTransport(client.Transport, *cfg.Instrumentation)
+	}
+
+	return &ElasticsearchClient{
+		client: client,
+	}, nil
+}
+
+// NewElasticsearchClientFromEnv creates a client configured with ClientConfigFromEnv
+func NewElasticsearchClientFromEnv() (*ElasticsearchClient, error) {
+	cfg, err := ClientConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewElasticsearchClientWithConfig(cfg)
+}
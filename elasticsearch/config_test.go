@@ -0,0 +1,197 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTransport answers every request with the next status, or 200 once they are used up, and records the requests
+type recordingTransport struct {
+	statuses []int
+	requests []*http.Request
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.requests = append(r.requests, req)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	body := `{"acknowledged": true}`
+	return &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{"X-Elastic-Product": []string{"Elasticsearch"}, "Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+func TestNewElasticsearchClientWithConfig_Auth(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        ClientConfig
+		wantHeader string
+	}{
+		{name: "none", cfg: ClientConfig{}, wantHeader: ""},
+		{name: "basic auth", cfg: ClientConfig{Username: "elastic", Password: "changeme"}, wantHeader: "Basic " + base64.StdEncoding.EncodeToString([]byte("elastic:changeme"))},
+		{name: "api key", cfg: ClientConfig{APIKey: "a2V5OnNlY3JldA=="}, wantHeader: "APIKey a2V5OnNlY3JldA=="},
+		{name: "service token", cfg: ClientConfig{ServiceToken: "AAEAAWVs"}, wantHeader: "Bearer AAEAAWVs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &recordingTransport{}
+			tt.cfg.Addresses = []string{"https://es1.test:9200"}
+			tt.cfg.Transport = transport
+
+			es, err := NewElasticsearchClientWithConfig(tt.cfg)
+			require.NoError(t, err)
+			require.NoError(t, es.DeleteIndex(context.Background(), "products"))
+
+			require.Len(t, transport.requests, 1)
+			assert.Equal(t, "https", transport.requests[0].URL.Scheme)
+			assert.Equal(t, tt.wantHeader, transport.requests[0].Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestNewElasticsearchClientWithConfig_Nodes(t *testing.T) {
+	transport := &recordingTransport{}
+	es, err := NewElasticsearchClientWithConfig(ClientConfig{
+		Addresses: []string{"http://es1.test:9200", "http://es2.test:9200"},
+		Transport: transport,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, es.DeleteIndex(context.Background(), "products"))
+	require.NoError(t, es.DeleteIndex(context.Background(), "products"))
+
+	require.Len(t, transport.requests, 2)
+	assert.ElementsMatch(t, []string{"es1.test:9200", "es2.test:9200"}, []string{transport.requests[0].URL.Host, transport.requests[1].URL.Host})
+}
+
+func TestNewElasticsearchClientWithConfig_CloudID(t *testing.T) {
+	transport := &recordingTransport{}
+	cloudID := "shop:" + base64.StdEncoding.EncodeToString([]byte("eu-west-1.aws.found.io$abc123$def456"))
+	es, err := NewElasticsearchClientWithConfig(ClientConfig{CloudID: cloudID, APIKey: "a2V5OnNlY3JldA==", Transport: transport})
+	require.NoError(t, err)
+
+	require.NoError(t, es.DeleteIndex(context.Background(), "products"))
+	require.Len(t, transport.requests, 1)
+	assert.Equal(t, "https://abc123.eu-west-1.aws.found.io/products", transport.requests[0].URL.String())
+}
+
+func TestNewElasticsearchClientWithConfig_Retry(t *testing.T) {
+	transport := &recordingTransport{statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests}}
+	es, err := NewElasticsearchClientWithConfig(ClientConfig{
+		Addresses:     []string{"http://es1.test:9200"},
+		RetryOnStatus: []int{http.StatusTooManyRequests},
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		Transport:     transport,
+	})
+	require.NoError(t, err)
+	require.NoError(t, es.DeleteIndex(context.Background(), "products"))
+	assert.Len(t, transport.requests, 3)
+
+	transport = &recordingTransport{statuses: []int{http.StatusServiceUnavailable}}
+	es, err = NewElasticsearchClientWithConfig(ClientConfig{
+		Addresses:    []string{"http://es1.test:9200"},
+		DisableRetry: true,
+		Transport:    transport,
+	})
+	require.NoError(t, err)
+	assert.Error(t, es.DeleteIndex(context.Background(), "products"))
+	assert.Len(t, transport.requests, 1)
+}
+
+func TestNewElasticsearchClientWithConfig_Compression(t *testing.T) {
+	transport := &recordingTransport{}
+	es, err := NewElasticsearchClientWithConfig(ClientConfig{
+		Addresses:           []string{"http://es1.test:9200"},
+		CompressRequestBody: true,
+		Transport:           transport,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, es.IndexDocument(context.Background(), "products", map[string]interface{}{"name": "milk"}))
+	require.Len(t, transport.requests, 1)
+	assert.Equal(t, "gzip", transport.requests[0].Header.Get("Content-Encoding"))
+}
+
+func TestNewElasticsearchClientWithConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ClientConfig
+		wantErr string
+	}{
+		{name: "address and cloud id", cfg: ClientConfig{Addresses: []string{"http://es1:9200"}, CloudID: "shop:abc"}, wantErr: "addresses and cloud id cannot both be set"},
+		{name: "address without scheme", cfg: ClientConfig{Addresses: []string{"es1:9200"}}, wantErr: `invalid address "es1:9200"`},
+		{name: "address without host", cfg: ClientConfig{Addresses: []string{"http://:9200"}}, wantErr: `invalid address "http://:9200"`},
+		{name: "username only", cfg: ClientConfig{Username: "elastic"}, wantErr: "basic auth requires both a username and a password"},
+		{name: "two credentials", cfg: ClientConfig{Username: "elastic", Password: "changeme", APIKey: "key"}, wantErr: "only one of basic auth, api key and service token can be set"},
+		{name: "ca cert with transport", cfg: ClientConfig{CACert: []byte("pem"), Transport: &recordingTransport{}}, wantErr: "a ca certificate cannot be used with a custom transport"},
+		{name: "retry status", cfg: ClientConfig{RetryOnStatus: []int{200}}, wantErr: "invalid retry status 200"},
+		{name: "max retries", cfg: ClientConfig{MaxRetries: -1}, wantErr: "max retries cannot be negative"},
+		{name: "retry backoff", cfg: ClientConfig{RetryBackoff: -time.Second}, wantErr: "retry backoff cannot be negative"},
+		{name: "invalid ca cert", cfg: ClientConfig{CACert: []byte("not a certificate")}, wantErr: "error creating elasticsearch client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewElasticsearchClientWithConfig(tt.cfg)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestClientConfigFromEnv(t *testing.T) {
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caPath, []byte("-----BEGIN CERTIFICATE-----"), 0o600))
+
+	t.Setenv("ELASTICSEARCH_ADDRESSES", "https://es1:9200, https://es2:9200")
+	t.Setenv("ELASTICSEARCH_CLOUD_ID", "")
+	t.Setenv("ELASTICSEARCH_USERNAME", "elastic")
+	t.Setenv("ELASTICSEARCH_PASSWORD", "changeme")
+	t.Setenv("ELASTICSEARCH_API_KEY", "")
+	t.Setenv("ELASTICSEARCH_SERVICE_TOKEN", "")
+	t.Setenv("ELASTICSEARCH_CA_CERT", caPath)
+	t.Setenv("ELASTICSEARCH_CERT_FINGERPRINT", "")
+	t.Setenv("ELASTICSEARCH_RETRY_ON_STATUS", "429,503")
+	t.Setenv("ELASTICSEARCH_MAX_RETRIES", "5")
+	t.Setenv("ELASTICSEARCH_DISABLE_RETRY", "")
+	t.Setenv("ELASTICSEARCH_RETRY_BACKOFF", "100ms")
+	t.Setenv("ELASTICSEARCH_COMPRESS", "true")
+
+	cfg, err := ClientConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, ClientConfig{
+		Addresses:           []string{"https://es1:9200", "https://es2:9200"},
+		Username:            "elastic",
+		Password:            "changeme",
+		CACert:              []byte("-----BEGIN CERTIFICATE-----"),
+		RetryOnStatus:       []int{429, 503},
+		MaxRetries:          5,
+		RetryBackoff:        100 * time.Millisecond,
+		CompressRequestBody: true,
+	}, cfg)
+
+	t.Setenv("ELASTICSEARCH_RETRY_ON_STATUS", "429,busy")
+	_, err = ClientConfigFromEnv()
+	assert.EqualError(t, err, `invalid status "busy" in ELASTICSEARCH_RETRY_ON_STATUS`)
+
+	t.Setenv("ELASTICSEARCH_RETRY_ON_STATUS", "")
+	t.Setenv("ELASTICSEARCH_CA_CERT", filepath.Join(t.TempDir(), "missing.pem"))
+	_, err = ClientConfigFromEnv()
+	assert.ErrorContains(t, err, "error reading ELASTICSEARCH_CA_CERT")
+}
@@ -0,0 +1,240 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Explanation is the breakdown of the score of a document, e.g. the BM25 weight of each matched term
+type Explanation struct {
+	Value       float64       `json:"value"`
+	Description string        `json:"description"`
+	Details     []Explanation `json:"details,omitempty"`
+}
+
+// String renders the explanation as an indented tree, one computation per line
+func (e Explanation) String() string {
+	var b strings.Builder
+	e.write(&b, 0)
+	return b.String()
+}
+
+func (e Explanation) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%s%g %s\n", strings.Repeat("  ", depth), e.Value, e.Description)
+	for _, detail := range e.Details {
+		detail.write(b, depth+1)
+	}
+}
+
+// DocumentExplanation tells whether a document matches a query and how it is scored
+type DocumentExplanation struct {
+	Index       string      `json:"_index"`
+	ID          string      `json:"_id"`
+	Matched     bool        `json:"matched"`
+	Explanation Explanation `json:"explanation"`
+}
+
+// Explain computes the score of a single document for a query, e.g. to find out why a product ranks below another.
+// Use SetExplain or SearchBuilder.Explain to get the explanation of every hit of a search instead.
+func (es *ElasticsearchClient) Explain(ctx context.Context, index string, id string, query Query, opts ...RequestOption) (*DocumentExplanation, error) {
+	if index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
+	if id == "" {
+		return nil, fmt.Errorf("document id cannot be empty")
+	}
+	if query == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	source, err := query.Source()
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	body, err := json.Marshal(map[string]interface{}{"query": source})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling query: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	var explanation DocumentExplanation
+	req := esapi.ExplainRequest{Index: index, DocumentID: id, Body: bytes.NewReader(body)}
+	if err := es.perform(ctx, req, "explaining document", &explanation); err != nil {
+		return nil, err
+	}
+	return &explanation, nil
+}
+
+// QueryValidation is the outcome of validating a query. Explanations hold, per index, the Lucene query
+// the query is rewritten to, or why it is invalid.
+type QueryValidation struct {
+	Valid        bool               `json:"valid"`
+	Error        string             `json:"error,omitempty"`
+	Explanations []QueryExplanation `json:"explanations,omitempty"`
+}
+
+type QueryExplanation struct {
+	Index       string `json:"index"`
+	Shard       int    `json:"shard"`
+	Valid       bool   `json:"valid"`
+	Explanation string `json:"explanation,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ValidateQuery checks the query of a search without running it and explains how it is rewritten,
+// e.g. which terms a fuzzy or synonym query expands to
+func (es *ElasticsearchClient) ValidateQuery(ctx context.Context, query *ESQuery, opts ...RequestOption) (*QueryValidation, error) {
+	if query == nil || query.query == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	if query.index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
+
+	// the validate API only accepts the query clause of a search
+	body := map[string]interface{}{}
+	if q, ok := query.query["query"]; ok {
+		body["query"] = q
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling query: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	explain, rewrite := true, true
+	req := esapi.IndicesValidateQueryRequest{
+		Index:   []string{query.index},
+		Body:    bytes.NewReader(bodyBytes),
+		Explain: &explain,
+		Rewrite: &rewrite,
+	}
+	var validation QueryValidation
+	if err := es.perform(ctx, req, "validating query", &validation); err != nil {
+		return nil, err
+	}
+	return &validation, nil
+}
+
+// SetExplain makes the search return the score explanation of every hit in Hit.Explanation
+func (q *ESQuery) SetExplain(explain bool) {
+	if q.query == nil {
+		q.query = map[string]interface{}{}
+	}
+	q.query["explain"] = explain
+}
+
+// SetProfile makes the search return how long each part of the query took on every shard in SearchResult.Profile
+func (q *ESQuery) SetProfile(profile bool) {
+	if q.query == nil {
+		q.query = map[string]interface{}{}
+	}
+	q.query["profile"] = profile
+}
+
+// Profile is the timing of a search on every shard
+type Profile struct {
+	Shards []ShardProfile `json:"shards"`
+}
+
+type ShardProfile struct {
+	// ID identifies the shard as [node][index][shard]
+	ID           string          `json:"id"`
+	Searches     []SearchProfile `json:"searches"`
+	Aggregations []ProfileNode   `json:"aggregations,omitempty"`
+}
+
+type SearchProfile struct {
+	Query           []ProfileNode      `json:"query"`
+	RewriteTimeNano int64              `json:"rewrite_time"`
+	Collector       []CollectorProfile `json:"collector"`
+}
+
+// ProfileNode is the timing of a Lucene query or an aggregation and its children
+type ProfileNode struct {
+	Type        string           `json:"type"`
+	Description string           `json:"description"`
+	TimeInNanos int64            `json:"time_in_nanos"`
+	Breakdown   map[string]int64 `json:"breakdown,omitempty"`
+	Children    []ProfileNode    `json:"children,omitempty"`
+}
+
+type CollectorProfile struct {
+	Name        string             `json:"name"`
+	Reason      string             `json:"reason"`
+	TimeInNanos int64              `json:"time_in_nanos"`
+	Children    []CollectorProfile `json:"children,omitempty"`
+}
+
+// String renders the profile as a tree per shard, with the time spent in each query and aggregation
+func (p *Profile) String() string {
+	var b strings.Builder
+	for _, shard := range p.Shards {
+		fmt.Fprintf(&b, "shard %s\n", shard.ID)
+		for _, search := range shard.Searches {
+			fmt.Fprintf(&b, "  query (rewrite %s)\n", time.Duration(search.RewriteTimeNano))
+			for _, node := range search.Query {
+				node.write(&b, 2)
+			}
+			for _, collector := range search.Collector {
+				collector.write(&b, 2)
+			}
+		}
+		if len(shard.Aggregations) > 0 {
+			b.WriteString("  aggregations\n")
+			for _, node := range shard.Aggregations {
+				node.write(&b, 2)
+			}
+		}
+	}
+	return b.String()
+}
+
+func (n ProfileNode) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%s%s %s: %s\n", strings.Repeat("  ", depth), time.Duration(n.TimeInNanos), n.Type, n.Description)
+	for _, child := range n.Children {
+		child.write(b, depth+1)
+	}
+}
+
+func (c CollectorProfile) write(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%s%s collector %s (%s)\n", strings.Repeat("  ", depth), time.Duration(c.TimeInNanos), c.Name, c.Reason)
+	for _, child := range c.Children {
+		child.write(b, depth+1)
+	}
+}
+
+// DebugString returns the search as a request that can be pasted in the Kibana console
+func (q *ESQuery) DebugString() string {
+	body, err := json.MarshalIndent(q.query, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("invalid query: %v", err)
+	}
+	return fmt.Sprintf("GET %s/_search\n%s", q.index, body)
+}
+
+// DebugString returns the multi-search as a request that can be pasted in the Kibana console
+func (m *MultiESQuery) DebugString(index string) string {
+	buffer, err := m.createMQueryBuffer(index)
+	if err != nil {
+		return fmt.Sprintf("invalid multi-search: %v", err)
+	}
+	return fmt.Sprintf("GET %s/_msearch\n%s", index, buffer.String())
+}
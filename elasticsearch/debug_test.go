@@ -0,0 +1,140 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const milkExplanation = `{"value": 1.5, "description": "sum of:", "details": [
+	{"value": 1.0, "description": "weight(name:oat in 3) [PerFieldSimilarity], result of:", "details": []},
+	{"value": 0.5, "description": "weight(name:milk in 3) [PerFieldSimilarity], result of:"}]}`
+
+func TestExplanation_String(t *testing.T) {
+	var explanation Explanation
+	require.NoError(t, json.Unmarshal([]byte(milkExplanation), &explanation))
+
+	assert.Equal(t, "1.5 sum of:\n"+
+		"  1 weight(name:oat in 3) [PerFieldSimilarity], result of:\n"+
+		"  0.5 weight(name:milk in 3) [PerFieldSimilarity], result of:\n", explanation.String())
+}
+
+func TestElasticsearchClient_Explain(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/products/_explain/3", req.URL.Path)
+		data, _ := io.ReadAll(req.Body)
+		assert.JSONEq(t, `{"query": {"match": {"name": {"query": "oat milk"}}}}`, string(data))
+		return http.StatusOK, `{"_index": "products", "_id": "3", "matched": true, "explanation": ` + milkExplanation + `}`
+	})
+
+	explanation, err := es.Explain(context.Background(), "products", "3", NewMatchQuery("name", "oat milk"))
+	require.NoError(t, err)
+	assert.True(t, explanation.Matched)
+	assert.Equal(t, "3", explanation.ID)
+	assert.Equal(t, 1.5, explanation.Explanation.Value)
+	assert.Len(t, explanation.Explanation.Details, 2)
+
+	_, err = es.Explain(context.Background(), "products", "", NewMatchQuery("name", "oat milk"))
+	assert.EqualError(t, err, "document id cannot be empty")
+	_, err = es.Explain(context.Background(), "products", "3", nil)
+	assert.EqualError(t, err, "query is nil")
+}
+
+func TestElasticsearchClient_ValidateQuery(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/products/_validate/query", req.URL.Path)
+		assert.Equal(t, "true", req.URL.Query().Get("explain"))
+		assert.Equal(t, "true", req.URL.Query().Get("rewrite"))
+		data, _ := io.ReadAll(req.Body)
+		assert.JSONEq(t, `{"query": {"match": {"name": {"query": "oat milk"}}}}`, string(data))
+		return http.StatusOK, `{"valid": true, "_shards": {"total": 1, "successful": 1, "failed": 0},
+			"explanations": [{"index": "products", "shard": 0, "valid": true, "explanation": "name:oat name:milk"}]}`
+	})
+
+	query, err := NewSearchBuilder("products").Query(NewMatchQuery("name", "oat milk")).Size(10).Build()
+	require.NoError(t, err)
+	validation, err := es.ValidateQuery(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, &QueryValidation{
+		Valid:        true,
+		Explanations: []QueryExplanation{{Index: "products", Valid: true, Explanation: "name:oat name:milk"}},
+	}, validation)
+}
+
+func TestElasticsearchClient_ValidateQuery_Invalid(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, `{"valid": false, "error": "[match] unknown token [START_ARRAY]"}`
+	})
+
+	validation, err := es.ValidateQuery(context.Background(), CreateESQueryStr("products", `{"query": {"match": []}}`))
+	require.NoError(t, err)
+	assert.False(t, validation.Valid)
+	assert.Equal(t, "[match] unknown token [START_ARRAY]", validation.Error)
+
+	_, err = es.ValidateQuery(context.Background(), nil)
+	assert.EqualError(t, err, "query is nil")
+}
+
+func TestSearchResult_ExplainAndProfile(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		assert.Equal(t, true, body["explain"])
+		assert.Equal(t, true, body["profile"])
+		return http.StatusOK, `{"hits": {"hits": [{"_index": "products", "_id": "3", "_score": 1.5, "_source": {}, "_explanation": ` + milkExplanation + `}]},
+			"profile": {"shards": [{"id": "[n1][products][0]", "searches": [{
+				"query": [{"type": "BooleanQuery", "description": "name:oat name:milk", "time_in_nanos": 2500000,
+					"breakdown": {"score": 1000, "create_weight": 2000},
+					"children": [{"type": "TermQuery", "description": "name:oat", "time_in_nanos": 1000000}]}],
+				"rewrite_time": 3000,
+				"collector": [{"name": "QueryPhaseCollector", "reason": "search_query_phase", "time_in_nanos": 40000}]}],
+			"aggregations": [{"type": "StringTermsAggregatorFromFilters", "description": "brands", "time_in_nanos": 5000}]}]}}`
+	})
+
+	query, err := NewSearchBuilder("products").Query(NewMatchQuery("name", "oat milk")).Explain().Profile().Build()
+	require.NoError(t, err)
+	result, err := es.Search(context.Background(), query)
+	require.NoError(t, err)
+
+	require.NotNil(t, result.Hits[0].Explanation)
+	assert.Equal(t, 1.5, result.Hits[0].Explanation.Value)
+
+	require.NotNil(t, result.Profile)
+	assert.Equal(t, map[string]int64{"score": 1000, "create_weight": 2000}, result.Profile.Shards[0].Searches[0].Query[0].Breakdown)
+	assert.Equal(t, "shard [n1][products][0]\n"+
+		"  query (rewrite 3µs)\n"+
+		"    2.5ms BooleanQuery: name:oat name:milk\n"+
+		"      1ms TermQuery: name:oat\n"+
+		"    40µs collector QueryPhaseCollector (search_query_phase)\n"+
+		"  aggregations\n"+
+		"    5µs StringTermsAggregatorFromFilters: brands\n", result.Profile.String())
+}
+
+func TestESQuery_SetExplainAndProfile(t *testing.T) {
+	query := CreateESQuery("products", map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}})
+	query.SetExplain(true)
+	query.SetProfile(true)
+	assert.Equal(t, true, query.query["explain"])
+	assert.Equal(t, true, query.query["profile"])
+}
+
+func TestDebugString(t *testing.T) {
+	query := CreateESQuery("products", map[string]interface{}{"size": 5})
+	assert.Equal(t, "GET products/_search\n{\n  \"size\": 5\n}", query.DebugString())
+
+	mquery := CreateMQuery()
+	mquery.AddQuery(query)
+	mquery.AddQuery(CreateESQuery("promotions", map[string]interface{}{"size": 1}))
+	assert.Equal(t, "GET products/_msearch\n{ }\n{\"size\":5}\n{\"index\":\"promotions\"}\n{\"size\":1}\n", mquery.DebugString("products"))
+
+	assert.Equal(t, "invalid multi-search: no queries to create multi-search buffer", CreateMQuery().DebugString("products"))
+}
+
+func TestMultiESQuery_PrintQueryWithoutQueries(t *testing.T) {
+	assert.NotPanics(t, func() { CreateMQuery().PrintQuery("products") })
+}
@@ -0,0 +1,340 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+var (
+	// ErrNotFound is returned when the document or its index does not exist
+	ErrNotFound = errors.New("document not found")
+	// ErrVersionConflict is returned when a write conflicts with a concurrent change of the document,
+	// e.g. when the sequence number passed with IfSeqNo is no longer current
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// Document is a document fetched by id
+type Document struct {
+	Index       string          `json:"_index"`
+	ID          string          `json:"_id"`
+	Version     int64           `json:"_version"`
+	SeqNo       int64           `json:"_seq_no"`
+	PrimaryTerm int64           `json:"_primary_term"`
+	Source      json.RawMessage `json:"_source"`
+}
+
+// WriteResult is the outcome of a write to a single document
+type WriteResult struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Version     int64  `json:"_version"`
+	SeqNo       int64  `json:"_seq_no"`
+	PrimaryTerm int64  `json:"_primary_term"`
+	// Result is created, updated, deleted or noop
+	Result string `json:"result"`
+}
+
+// ByQueryResult is the outcome of a delete by query, update by query or reindex
+type ByQueryResult struct {
+	Took             int64             `json:"took"`
+	TimedOut         bool              `json:"timed_out"`
+	Total            int64             `json:"total"`
+	Deleted          int64             `json:"deleted"`
+	Created          int64             `json:"created"`
+	Updated          int64             `json:"updated"`
+	Noops            int64             `json:"noops"`
+	VersionConflicts int64             `json:"version_conflicts"`
+	Failures         []json.RawMessage `json:"failures"`
+}
+
+// Script is a painless script run against a document, e.g. ctx._source.stock -= params.count
+type Script struct {
+	Source string
+	Params map[string]interface{}
+}
+
+func (s *Script) source() map[string]interface{} {
+	script := map[string]interface{}{
+		"source": s.Source,
+		"lang":   "painless",
+	}
+	if len(s.Params) > 0 {
+		script["params"] = s.Params
+	}
+	return script
+}
+
+// IndexDocumentWithID creates or replaces the document with the given id
+func (es *ElasticsearchClient) IndexDocumentWithID(ctx context.Context, index string, id string, document interface{}, opts ...RequestOption) (*WriteResult, error) {
+	if index == "" || id == "" {
+		return nil, fmt.Errorf("index name and document id cannot be empty")
+	}
+	body, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling document: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	req := esapi.IndexRequest{
+		Index:         index,
+		DocumentID:    id,
+		Body:          bytes.NewReader(body),
+		IfSeqNo:       o.ifSeqNo,
+		IfPrimaryTerm: o.ifPrimaryTerm,
+		Refresh:       string(o.refresh),
+	}
+	return es.write(ctx, req, "indexing document")
+}
+
+// GetDocument fetches a document by id, returning ErrNotFound if it does not exist
+func (es *ElasticsearchClient) GetDocument(ctx context.Context, index string, id string, opts ...RequestOption) (*Document, error) {
+	if index == "" || id == "" {
+		return nil, fmt.Errorf("index name and document id cannot be empty")
+	}
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	res, err := esapi.GetRequest{Index: index, DocumentID: id}.Do(ctx, es.client)
+	if err != nil {
+		return nil, fmt.Errorf("error getting document: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, responseError(res, "getting document")
+	}
+
+	var doc Document
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing document: %w", err)
+	}
+	return &doc, nil
+}
+
+// Exists reports whether a document exists
+func (es *ElasticsearchClient) Exists(ctx context.Context, index string, id string, opts ...RequestOption) (bool, error) {
+	if index == "" || id == "" {
+		return false, fmt.Errorf("index name and document id cannot be empty")
+	}
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	return es.exists(ctx, esapi.ExistsRequest{Index: index, DocumentID: id}, "checking document")
+}
+
+// UpdateDocument merges a partial document into an existing one, returning ErrNotFound if it does not exist
+func (es *ElasticsearchClient) UpdateDocument(ctx context.Context, index string, id string, partial interface{}, opts ...RequestOption) (*WriteResult, error) {
+	return es.update(ctx, index, id, map[string]interface{}{"doc": partial}, opts)
+}
+
+// UpdateDocumentWithScript changes an existing document with a script, returning ErrNotFound if it does not exist
+func (es *ElasticsearchClient) UpdateDocumentWithScript(ctx context.Context, index string, id string, script *Script, opts ...RequestOption) (*WriteResult, error) {
+	if script == nil || script.Source == "" {
+		return nil, fmt.Errorf("script cannot be empty")
+	}
+	return es.update(ctx, index, id, map[string]interface{}{"script": script.source()}, opts)
+}
+
+// Upsert merges a partial document into an existing one, or creates the document from it if it does not exist.
+// IfSeqNo is rejected, as a document that does not exist yet has no sequence number to match.
+func (es *ElasticsearchClient) Upsert(ctx context.Context, index string, id string, document interface{}, opts ...RequestOption) (*WriteResult, error) {
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.ifSeqNo != nil {
+		return nil, fmt.Errorf("upsert does not support IfSeqNo, use UpdateDocument to update an existing document conditionally")
+	}
+	return es.update(ctx, index, id, map[string]interface{}{"doc": document, "doc_as_upsert": true}, opts)
+}
+
+func (es *ElasticsearchClient) update(ctx context.Context, index string, id string, body map[string]interface{}, opts []RequestOption) (*WriteResult, error) {
+	if index == "" || id == "" {
+		return nil, fmt.Errorf("index name and document id cannot be empty")
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling update: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	req := esapi.UpdateRequest{
+		Index:         index,
+		DocumentID:    id,
+		Body:          bytes.NewReader(bodyBytes),
+		IfSeqNo:       o.ifSeqNo,
+		IfPrimaryTerm: o.ifPrimaryTerm,
+		Refresh:       string(o.refresh),
+	}
+	return es.write(ctx, req, "updating document")
+}
+
+// DeleteDocument deletes a document by id, returning ErrNotFound if it does not exist
+func (es *ElasticsearchClient) DeleteDocument(ctx context.Context, index string, id string, opts ...RequestOption) (*WriteResult, error) {
+	if index == "" || id == "" {
+		return nil, fmt.Errorf("index name and document id cannot be empty")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	req := esapi.DeleteRequest{
+		Index:         index,
+		DocumentID:    id,
+		IfSeqNo:       o.ifSeqNo,
+		IfPrimaryTerm: o.ifPrimaryTerm,
+		Refresh:       string(o.refresh),
+	}
+	return es.write(ctx, req, "deleting document")
+}
+
+// DeleteByQuery deletes every document matching the query
+func (es *ElasticsearchClient) DeleteByQuery(ctx context.Context, query *ESQuery, opts ...RequestOption) (*ByQueryResult, error) {
+	if query == nil || query.query == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	body, err := json.Marshal(query.query)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling query: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+	refresh, err := o.refreshBool()
+	if err != nil {
+		return nil, err
+	}
+
+	req := esapi.DeleteByQueryRequest{
+		Index:     []string{query.index},
+		Body:      bytes.NewReader(body),
+		Conflicts: o.conflicts(),
+		Refresh:   refresh,
+	}
+	return es.byQuery(ctx, req, "deleting by query")
+}
+
+// UpdateByQuery runs a script against every document matching the query
+func (es *ElasticsearchClient) UpdateByQuery(ctx context.Context, query *ESQuery, script *Script, opts ...RequestOption) (*ByQueryResult, error) {
+	if query == nil || query.query == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	if script == nil || script.Source == "" {
+		return nil, fmt.Errorf("script cannot be empty")
+	}
+
+	body := make(map[string]interface{}, len(query.query)+1)
+	for key, value := range query.query {
+		body[key] = value
+	}
+	body["script"] = script.source()
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling query: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+	refresh, err := o.refreshBool()
+	if err != nil {
+		return nil, err
+	}
+
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{query.index},
+		Body:      bytes.NewReader(bodyBytes),
+		Conflicts: o.conflicts(),
+		Refresh:   refresh,
+	}
+	return es.byQuery(ctx, req, "updating by query")
+}
+
+func (es *ElasticsearchClient) write(ctx context.Context, req esapi.Request, action string) (*WriteResult, error) {
+	var result WriteResult
+	if err := es.perform(ctx, req, action, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (es *ElasticsearchClient) byQuery(ctx context.Context, req esapi.Request, action string) (*ByQueryResult, error) {
+	var result ByQueryResult
+	if err := es.perform(ctx, req, action, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Failures) > 0 {
+		return &result, fmt.Errorf("error %s: %d failures, first: %s", action, len(result.Failures), truncateBody(result.Failures[0]))
+	}
+	return &result, nil
+}
+
+// perform sends a request and decodes its response into out, unless out is nil
+func (es *ElasticsearchClient) perform(ctx context.Context, req esapi.Request, action string, out interface{}) error {
+	res, err := req.Do(ctx, es.client)
+	if err != nil {
+		return fmt.Errorf("error %s: %w", action, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return responseError(res, action)
+	}
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("error parsing response: %w", err)
+	}
+	return nil
+}
+
+// responseError maps an error response to ErrNotFound or ErrVersionConflict where it applies
+func responseError(res *esapi.Response, action string) error {
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("error %s: %w: %s", action, ErrNotFound, res.String())
+	case http.StatusConflict:
+		return fmt.Errorf("error %s: %w: %s", action, ErrVersionConflict, res.String())
+	default:
+		return fmt.Errorf("error %s: %s", action, res.String())
+	}
+}
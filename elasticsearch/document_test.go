@@ -0,0 +1,198 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticsearchClient_DocumentRequests(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		query  string
+		body   string
+	}
+
+	var got request
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		got = request{method: req.Method, path: req.URL.Path, query: req.URL.RawQuery}
+		if req.Body != nil {
+			body, _ := io.ReadAll(req.Body)
+			got.body = string(body)
+		}
+		return http.StatusOK, `{"_index":"products","_id":"sku-1","_version":3,"_seq_no":7,"_primary_term":1,"result":"updated"}`
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() (*WriteResult, error)
+		want request
+	}{
+		{
+			name: "index with id and sequence number",
+			call: func() (*WriteResult, error) {
+				return client.IndexDocumentWithID(ctx, "products", "sku-1", testProduct{Name: "Trail Shoe"}, IfSeqNo(6, 1))
+			},
+			want: request{method: http.MethodPut, path: "/products/_doc/sku-1", query: "if_primary_term=1&if_seq_no=6", body: `{"name":"Trail Shoe","price":0}`},
+		},
+		{
+			name: "partial update",
+			call: func() (*WriteResult, error) {
+				return client.UpdateDocument(ctx, "products", "sku-1", map[string]interface{}{"price": 99})
+			},
+			want: request{method: http.MethodPost, path: "/products/_update/sku-1", body: `{"doc":{"price":99}}`},
+		},
+		{
+			name: "scripted update",
+			call: func() (*WriteResult, error) {
+				return client.UpdateDocumentWithScript(ctx, "products", "sku-1", &Script{Source: "ctx._source.stock -= params.n", Params: map[string]interface{}{"n": 1}})
+			},
+			want: request{method: http.MethodPost, path: "/products/_update/sku-1", body: `{"script":{"lang":"painless","params":{"n":1},"source":"ctx._source.stock -= params.n"}}`},
+		},
+		{
+			name: "upsert",
+			call: func() (*WriteResult, error) {
+				return client.Upsert(ctx, "products", "sku-1", testProduct{Name: "Trail Shoe", Price: 120})
+			},
+			want: request{method: http.MethodPost, path: "/products/_update/sku-1", body: `{"doc":{"name":"Trail Shoe","price":120},"doc_as_upsert":true}`},
+		},
+		{
+			name: "delete",
+			call: func() (*WriteResult, error) {
+				return client.DeleteDocument(ctx, "products", "sku-1", IfSeqNo(7, 1))
+			},
+			want: request{method: http.MethodDelete, path: "/products/_doc/sku-1", query: "if_primary_term=1&if_seq_no=7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.call()
+			require.NoError(t, err)
+			assert.Equal(t, &WriteResult{Index: "products", ID: "sku-1", Version: 3, SeqNo: 7, PrimaryTerm: 1, Result: "updated"}, result)
+			assert.Equal(t, tt.want.method, got.method)
+			assert.Equal(t, tt.want.path, got.path)
+			assert.Equal(t, tt.want.query, got.query)
+			if tt.want.body != "" {
+				assert.JSONEq(t, tt.want.body, got.body)
+			}
+		})
+	}
+}
+
+func TestElasticsearchClient_DocumentErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+	}{
+		{name: "not found", status: http.StatusNotFound, body: `{"_index":"products","_id":"sku-1","found":false}`, wantErr: ErrNotFound},
+		{name: "version conflict", status: http.StatusConflict, body: `{"error":{"type":"version_conflict_engine_exception"}}`, wantErr: ErrVersionConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(req *http.Request) (int, string) {
+				return tt.status, tt.body
+			})
+
+			_, err := client.GetDocument(context.Background(), "products", "sku-1")
+			assert.True(t, errors.Is(err, tt.wantErr))
+			_, err = client.UpdateDocument(context.Background(), "products", "sku-1", map[string]interface{}{"price": 1})
+			assert.True(t, errors.Is(err, tt.wantErr))
+		})
+	}
+
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusInternalServerError, `{"error":"boom"}`
+	})
+	_, err := client.DeleteDocument(context.Background(), "products", "sku-1")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrNotFound))
+
+	_, err = client.IndexDocumentWithID(context.Background(), "products", "", testProduct{})
+	assert.Error(t, err)
+	_, err = client.UpdateDocumentWithScript(context.Background(), "products", "sku-1", &Script{})
+	assert.Error(t, err)
+	_, err = client.Upsert(context.Background(), "products", "sku-1", testProduct{}, IfSeqNo(7, 1))
+	assert.EqualError(t, err, "upsert does not support IfSeqNo, use UpdateDocument to update an existing document conditionally")
+}
+
+func TestElasticsearchClient_GetDocument(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/products/_doc/sku-1", req.URL.Path)
+		return http.StatusOK, `{"_index":"products","_id":"sku-1","_version":2,"_seq_no":4,"_primary_term":1,"found":true,"_source":{"name":"Trail Shoe","price":120}}`
+	})
+
+	doc, err := client.GetDocument(context.Background(), "products", "sku-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), doc.SeqNo)
+	assert.Equal(t, int64(1), doc.PrimaryTerm)
+
+	var product testProduct
+	require.NoError(t, json.Unmarshal(doc.Source, &product))
+	assert.Equal(t, testProduct{Name: "Trail Shoe", Price: 120}, product)
+}
+
+func TestElasticsearchClient_Exists(t *testing.T) {
+	for status, want := range map[int]bool{http.StatusOK: true, http.StatusNotFound: false} {
+		client := newTestClient(t, func(req *http.Request) (int, string) {
+			assert.Equal(t, http.MethodHead, req.Method)
+			return status, ""
+		})
+		exists, err := client.Exists(context.Background(), "products", "sku-1")
+		require.NoError(t, err)
+		assert.Equal(t, want, exists)
+	}
+
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusForbidden, ""
+	})
+	_, err := client.Exists(context.Background(), "products", "sku-1")
+	assert.Error(t, err)
+}
+
+func TestElasticsearchClient_ByQuery(t *testing.T) {
+	var path, query, body string
+	response := `{"took":5,"total":3,"deleted":2,"updated":0,"version_conflicts":1,"failures":[]}`
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		path, query = req.URL.Path, req.URL.RawQuery
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+		return http.StatusOK, response
+	})
+	ctx := context.Background()
+	discontinued := CreateESQuery("products", map[string]interface{}{"query": map[string]interface{}{"term": map[string]interface{}{"discontinued": true}}})
+
+	result, err := client.DeleteByQuery(ctx, discontinued, ProceedOnConflicts())
+	require.NoError(t, err)
+	assert.Equal(t, "/products/_delete_by_query", path)
+	assert.Equal(t, "conflicts=proceed", query)
+	assert.Equal(t, int64(2), result.Deleted)
+	assert.Equal(t, int64(1), result.VersionConflicts)
+
+	_, err = client.UpdateByQuery(ctx, discontinued, &Script{Source: "ctx._source.stock = 0"})
+	require.NoError(t, err)
+	assert.Equal(t, "/products/_update_by_query", path)
+	assert.JSONEq(t, `{"query":{"term":{"discontinued":true}},"script":{"lang":"painless","source":"ctx._source.stock = 0"}}`, body)
+
+	response = `{"took":5,"total":3,"deleted":1,"failures":[{"index":"products","cause":{"type":"mapper_exception"}}]}`
+	result, err = client.DeleteByQuery(ctx, discontinued)
+	assert.ErrorContains(t, err, "mapper_exception")
+	require.NotNil(t, result)
+	assert.Equal(t, int64(1), result.Deleted)
+
+	_, err = client.DeleteByQuery(ctx, nil)
+	assert.Error(t, err)
+	_, err = client.UpdateByQuery(ctx, discontinued, nil)
+	assert.Error(t, err)
+}
@@ -7,37 +7,46 @@ import (
 	"fmt"
 
 	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
 type ElasticsearchClient struct {
 	client *elasticsearch.Client
 }
 
+// NewElasticsearchClient creates a client for a single node over plain HTTP without authentication,
+// use NewElasticsearchClientWithConfig for secured clusters
 func NewElasticsearchClient(host string, port string) (*ElasticsearchClient, error) {
-	cfg := elasticsearch.Config{
+	return NewElasticsearchClientWithConfig(ClientConfig{
 		Addresses: []string{
 			fmt.Sprintf("http://%s:%s", host, port),
 		},
-	}
-
-	client, err := elasticsearch.NewClient(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("error creating elasticsearch client: %w", err)
-	}
-
-	return &ElasticsearchClient{
-		client: client,
-	}, nil
+	})
 }
 
-// IndexDocument indexes a document in Elasticsearch
-func (es *ElasticsearchClient) IndexDocument(ctx context.Context, index string, document interface{}) error {
+// IndexDocument indexes a document in Elasticsearch under a generated id
+func (es *ElasticsearchClient) IndexDocument(ctx context.Context, index string, document interface{}, opts ...RequestOption) error {
+	if index == "" {
+		return fmt.Errorf("index name cannot be empty")
+	}
 	docBytes, err := json.Marshal(document)
 	if err != nil {
 		return fmt.Errorf("error marshaling document: %w", err)
 	}
 
-	res, err := es.client.Index(index, bytes.NewReader(docBytes))
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	req := esapi.IndexRequest{
+		Index:   index,
+		Body:    bytes.NewReader(docBytes),
+		Refresh: string(o.refresh),
+	}
+	res, err := req.Do(ctx, es.client)
 	if err != nil {
 		return fmt.Errorf("error indexing document: %w", err)
 	}
@@ -51,15 +60,28 @@ func (es *ElasticsearchClient) IndexDocument(ctx context.Context, index string,
 }
 
 // SearchDocuments performs a search query in Elasticsearch
-func (es *ElasticsearchClient) SearchDocuments(ctx context.Context, query *ESQuery) ([]json.RawMessage, error) {
-	if query == nil {
-		return nil, fmt.Errorf("query is nil")
+func (es *ElasticsearchClient) SearchDocuments(ctx context.Context, query *ESQuery, opts ...RequestOption) ([]json.RawMessage, error) {
+	result, err := es.Search(ctx, query, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	if query.query == nil {
+	return result.Sources(), nil
+}
+
+// Search performs a search query in Elasticsearch and returns the hits with their metadata
+func (es *ElasticsearchClient) Search(ctx context.Context, query *ESQuery, opts ...RequestOption) (*SearchResult, error) {
+	if query == nil || query.query == nil {
 		return nil, fmt.Errorf("query is nil")
 	}
 
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
 	queryBytes, err := json.Marshal(query.query)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling query: %w", err)
@@ -79,80 +101,59 @@ func (es *ElasticsearchClient) SearchDocuments(ctx context.Context, query *ESQue
 		return nil, fmt.Errorf("error searching documents: %s", res.String())
 	}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("error parsing response: %w", err)
-	}
+	return decodeSearchResult(res.Body)
+}
 
-	hits := result["hits"].(map[string]interface{})["hits"].([]interface{})
-	documents := make([]json.RawMessage, len(hits))
-	for i, hit := range hits {
-		source := hit.(map[string]interface{})["_source"]
-		docBytes, err := json.Marshal(source)
-		if err != nil {
-			return nil, fmt.Errorf("error marshaling document: %w", err)
-		}
-		documents[i] = docBytes
+// Search performs a search query in Elasticsearch and decodes the source of every hit into T
+func Search[T any](ctx context.Context, es SearchClient, query *ESQuery, opts ...RequestOption) (*TypedSearchResult[T], error) {
+	result, err := es.Search(ctx, query, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	return documents, nil
+	return decodeSources[T](result)
 }
 
-// SearchDocumentsWithQuery performs a multi-search query in Elasticsearch
-func (es *ElasticsearchClient) SearchDocumentsWithMQuery(ctx context.Context, index string, query *MultiESQuery) ([][]json.RawMessage, error) {
-	buffer, err := query.createMQueryBuffer(index)
+// SearchDocumentsWithQuery performs a multi-search query in Elasticsearch. It fails if any of the queries failed,
+// use MultiSearch to get the results of the others.
+func (es *ElasticsearchClient) SearchDocumentsWithMQuery(ctx context.Context, index string, query *MultiESQuery, opts ...RequestOption) ([][]json.RawMessage, error) {
+	searchResults, err := es.multiSearch(ctx, index, query, opts)
 	if err != nil {
-		return nil, fmt.Errorf("error preparing multi-search request: %w", err)
+		return nil, err
 	}
 
-	// Execute the multi-search request
-	res, err := es.client.Msearch(
-		bytes.NewReader(buffer.Bytes()),
-		es.client.Msearch.WithIndex(index),
-		es.client.Msearch.WithContext(ctx),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error performing multi-search: %w", err)
+	results := make([][]json.RawMessage, len(searchResults))
+	for i, result := range searchResults {
+		results[i] = result.Sources()
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		return nil, fmt.Errorf("error in multi-search response: %s", res.String())
-	}
+	return results, nil
+}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("error parsing multi-search response: %w", err)
+// multiSearch runs the queries of a multi-query and returns their results in the same order,
+// failing if any of them failed
+func (es *ElasticsearchClient) multiSearch(ctx context.Context, index string, query *MultiESQuery, opts []RequestOption) ([]*SearchResult, error) {
+	result, err := es.MultiSearch(ctx, index, query, MultiSearchOptions{}, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return result.results()
+}
 
-	responses := result["responses"].([]interface{})
-	results := make([][]json.RawMessage, len(responses))
-
-	for i, response := range responses {
-		resp := response.(map[string]interface{})
-		if resp["error"] != nil {
-			return nil, fmt.Errorf("error in search response %d: %v", i, resp["error"])
-		}
-
-		hits := resp["hits"].(map[string]interface{})["hits"].([]interface{})
-		documents := make([]json.RawMessage, len(hits))
-		for j, hit := range hits {
-			source := hit.(map[string]interface{})["_source"]
-			docBytes, err := json.Marshal(source)
-			if err != nil {
-				return nil, fmt.Errorf("error marshaling document: %w", err)
-			}
-			documents[j] = docBytes
-		}
-		results[i] = documents
+// DeleteIndex deletes an index from Elasticsearch
+func (es *ElasticsearchClient) DeleteIndex(ctx context.Context, index string, opts ...RequestOption) error {
+	if index == "" {
+		return fmt.Errorf("index name cannot be empty")
 	}
 
-	return results, nil
-}
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
 
-// DeleteIndex deletes an index from Elasticsearch
-func (es *ElasticsearchClient) DeleteIndex(ctx context.Context, index string) error {
-	res, err := es.client.Indices.Delete([]string{index})
+	res, err := esapi.IndicesDeleteRequest{Index: []string{index}}.Do(ctx, es.client)
 	if err != nil {
 		return fmt.Errorf("error deleting index: %w", err)
 	}
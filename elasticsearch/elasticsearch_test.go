@@ -3,32 +3,60 @@ package elasticsearch
 import (
 	"context"
 	"encoding/json"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func cleanupTestIndices(t *testing.T, client *ElasticsearchClient) {
-	res, err := client.client.Indices.Delete([]string{"_all"})
+// integrationAddressEnv is the address of the cluster the integration tests run against, e.g. http://localhost:10200.
+// They are skipped when it is not set, the other tests use the estest fake cluster.
+const integrationAddressEnv = "ELASTICSEARCH_TEST_ADDRESS"
+
+// newIntegrationClient connects to the test cluster and deletes the given indices before and after the test
+func newIntegrationClient(t *testing.T, indices ...string) *ElasticsearchClient {
+	address := os.Getenv(integrationAddressEnv)
+	if address == "" {
+		t.Skipf("%s is not set", integrationAddressEnv)
+	}
+	client, err := NewElasticsearchClientWithConfig(ClientConfig{Addresses: []string{address}})
+	require.NoError(t, err)
+
+	cleanupTestIndices(t, client, indices...)
+	t.Cleanup(func() {
+		cleanupTestIndices(t, client, indices...)
+	})
+	return client
+}
+
+// cleanupTestIndices deletes the indices created by a test, never a wildcard or _all
+func cleanupTestIndices(t *testing.T, client *ElasticsearchClient, indices ...string) {
+	for _, index := range indices {
+		if index == "" || index == "_all" || strings.ContainsAny(index, "*,") {
+			t.Fatalf("refusing to delete index %q", index)
+		}
+	}
+	if len(indices) == 0 {
+		return
+	}
+
+	ignoreUnavailable := true
+	req := esapi.IndicesDeleteRequest{Index: indices, IgnoreUnavailable: &ignoreUnavailable}
+	res, err := req.Do(context.Background(), client.client)
 	if err != nil {
-		t.Logf("Warning: Failed to delete all indices: %v", err)
+		t.Logf("Warning: Failed to delete test indices %v: %v", indices, err)
 		return
 	}
 	defer res.Body.Close()
 }
 
 func TestElasticsearchClient_IndexDocument(t *testing.T) {
-	client, err := NewElasticsearchClient("localhost", "10200")
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	// Cleanup before test
-	cleanupTestIndices(t, client)
-
-	// Ensure cleanup after test
-	defer cleanupTestIndices(t, client)
+	client := newIntegrationClient(t, "test-index")
 
 	tests := []struct {
 		name    string
@@ -66,22 +94,14 @@ func TestElasticsearchClient_IndexDocument(t *testing.T) {
 }
 
 func TestElasticsearchClient_SearchDocuments(t *testing.T) {
-	client, err := NewElasticsearchClient("localhost", "10200")
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	// Cleanup before test
-	cleanupTestIndices(t, client)
-
-	// Ensure cleanup after test
-	defer cleanupTestIndices(t, client)
+	client := newIntegrationClient(t, "test-search-index")
 
 	// First index a test document
 	testDoc := map[string]interface{}{
 		"title":   "Test Search Document",
 		"content": "This is a test document for search",
 	}
-	err = client.IndexDocument(context.Background(), "test-search-index", testDoc)
+	err := client.IndexDocument(context.Background(), "test-search-index", testDoc)
 	require.NoError(t, err)
 
 	// Wait for the document to be indexed
@@ -143,6 +163,79 @@ func TestElasticsearchClient_SearchDocuments(t *testing.T) {
 	}
 }
 
+func TestElasticsearchClient_Search(t *testing.T) {
+	client := newIntegrationClient(t, "test-typed-search-index")
+
+	err := client.IndexDocument(context.Background(), "test-typed-search-index", testProduct{Name: "Trail Shoe", Price: 120})
+	require.NoError(t, err)
+
+	// Wait for the document to be indexed
+	time.Sleep(1 * time.Second)
+
+	query := CreateESQuery("test-typed-search-index", map[string]interface{}{
+		"query": map[string]interface{}{
+			"match": map[string]interface{}{
+				"name": "trail",
+			},
+		},
+	})
+
+	result, err := client.Search(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total.Value)
+	require.Len(t, result.Hits, 1)
+	assert.NotEmpty(t, result.Hits[0].ID)
+	assert.NotNil(t, result.Hits[0].Score)
+
+	typed, err := Search[testProduct](context.Background(), client, query)
+	require.NoError(t, err)
+	assert.Equal(t, []testProduct{{Name: "Trail Shoe", Price: 120}}, typed.Documents)
+
+	_, err = client.Search(context.Background(), nil)
+	assert.Error(t, err)
+}
+
+func TestElasticsearchClient_Scan(t *testing.T) {
+	client := newIntegrationClient(t, "test-scan-index")
+
+	for i := 0; i < 25; i++ {
+		err := client.IndexDocument(context.Background(), "test-scan-index", testProduct{Name: "Shoe", Price: float64(i)})
+		require.NoError(t, err)
+	}
+
+	// Wait for the documents to be indexed
+	time.Sleep(1 * time.Second)
+
+	query := CreateESQuery("test-scan-index", map[string]interface{}{
+		"query": map[string]interface{}{"match_all": map[string]interface{}{}},
+		"sort":  []interface{}{map[string]interface{}{"price": "asc"}},
+	})
+
+	var prices []float64
+	for product, err := range Scan[testProduct](context.Background(), client, query, ScanOptions{PageSize: 10}) {
+		require.NoError(t, err)
+		prices = append(prices, product.Price)
+	}
+	require.Len(t, prices, 25)
+	assert.IsIncreasing(t, prices)
+
+	// Stopping early and cancelling both end the iteration
+	count := 0
+	for range client.Scan(context.Background(), query, ScanOptions{PageSize: 10}) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, err := range client.Scan(ctx, query, ScanOptions{}) {
+		assert.Error(t, err)
+	}
+}
+
 func TestNewElasticsearchClient(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -169,22 +262,14 @@ func TestNewElasticsearchClient(t *testing.T) {
 }
 
 func TestElasticsearchClient_DeleteIndex(t *testing.T) {
-	client, err := NewElasticsearchClient("localhost", "10200")
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	// Cleanup before test
-	cleanupTestIndices(t, client)
-
-	// Ensure cleanup after test
-	defer cleanupTestIndices(t, client)
+	client := newIntegrationClient(t, "test-delete-index")
 
 	// First create a test index by indexing a document
 	testDoc := map[string]interface{}{
 		"title":   "Test Document",
 		"content": "This is a test document",
 	}
-	err = client.IndexDocument(context.Background(), "test-delete-index", testDoc)
+	err := client.IndexDocument(context.Background(), "test-delete-index", testDoc)
 	require.NoError(t, err)
 
 	// Wait for the document to be indexed
@@ -225,12 +310,7 @@ func TestElasticsearchClient_DeleteIndex(t *testing.T) {
 }
 
 func TestElasticsearchClient_Msearch(t *testing.T) {
-	client, err := NewElasticsearchClient("localhost", "10200")
-	require.NoError(t, err)
-	require.NotNil(t, client)
-
-	// Cleanup before test
-	cleanupTestIndices(t, client)
+	client := newIntegrationClient(t, "test-msearch-index-1", "test-msearch-index-2")
 
 	// First index test documents in different indices
 	testDocs := []struct {
@@ -0,0 +1,60 @@
+package estest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UpdateGoldenEnv is the environment variable that makes AssertGolden write the golden files instead
+// of comparing against them, e.g. UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// AssertGolden compares a JSON or NDJSON request body with the golden file testdata/<name>.golden.json.
+// Bodies are normalized first, so the key order and whitespace of the generated JSON do not matter.
+func AssertGolden(t testing.TB, name string, body []byte) {
+	t.Helper()
+
+	actual, err := normalizeJSON(body)
+	require.NoError(t, err, "body of %s is not JSON", name)
+
+	file := filepath.Join("testdata", name+".golden.json")
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		require.NoError(t, os.WriteFile(file, actual, 0o644))
+		return
+	}
+
+	expected, err := os.ReadFile(file)
+	require.NoError(t, err, "missing golden file, run the test with %s=1 to create it", UpdateGoldenEnv)
+	assert.Equal(t, string(expected), string(actual), "body differs from %s", file)
+}
+
+// normalizeJSON indents a JSON document, or each document of an NDJSON body separated by a blank line
+func normalizeJSON(body []byte) ([]byte, error) {
+	var documents []string
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	for decoder.More() {
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			return nil, err
+		}
+		indented, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, string(indented))
+	}
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("empty body")
+	}
+	return []byte(strings.Join(documents, "\n\n") + "\n"), nil
+}
@@ -0,0 +1,145 @@
+// Package estest fakes an Elasticsearch cluster in tests: it replays canned responses over HTTP
+// and records the requests it receives, so that the queries a client sends can be asserted on.
+package estest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sync"
+	"testing"
+)
+
+// Request is a request received by the server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// HandlerFunc computes the status and the JSON body of the response to a request
+type HandlerFunc func(req Request) (int, string)
+
+type route struct {
+	method  string
+	pattern string
+	handler HandlerFunc
+}
+
+// Server is an in-process Elasticsearch cluster. Requests without a matching route get a 404,
+// like a missing index or document.
+type Server struct {
+	URL string
+
+	server   *httptest.Server
+	mu       sync.Mutex
+	routes   []route
+	requests []Request
+}
+
+// NewServer starts a server that is closed at the end of the test. Connect a client to it with
+// elasticsearch.ClientConfig{Addresses: []string{server.URL}}.
+func NewServer(t testing.TB) *Server {
+	s := &Server{}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// Handle replies to the requests with the method and a path matching the pattern. The pattern uses the
+// syntax of path.Match, e.g. "/products/_doc/*". Routes are matched in the order they were added.
+func (s *Server) Handle(method string, pattern string, status int, body string) {
+	s.HandleFunc(method, pattern, func(Request) (int, string) {
+		return status, body
+	})
+}
+
+// HandleSequence replies to successive matching requests with the bodies in order, repeating the last one,
+// e.g. to page through a scroll or to fail before succeeding on retry
+func (s *Server) HandleSequence(method string, pattern string, status int, bodies ...string) {
+	var mu sync.Mutex
+	next := 0
+	s.HandleFunc(method, pattern, func(Request) (int, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(bodies) == 0 {
+			return status, "{}"
+		}
+		body := bodies[min(next, len(bodies)-1)]
+		next++
+		return status, body
+	})
+}
+
+// HandleFunc computes the response to the requests with the method and a path matching the pattern
+func (s *Server) HandleFunc(method string, pattern string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, route{method: method, pattern: pattern, handler: handler})
+}
+
+// Requests returns the requests received so far, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the last request received, or false if there was none
+func (s *Server) LastRequest() (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return Request{}, false
+	}
+	return s.requests[len(s.requests)-1], true
+}
+
+// Reset forgets the requests received so far, keeping the routes
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header.Clone(), Body: body}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	handler := s.match(req)
+	s.mu.Unlock()
+
+	status, response := http.StatusNotFound, `{"error": {"type": "resource_not_found_exception", "reason": "no route for `+req.Method+` `+req.Path+`"}, "status": 404}`
+	if handler != nil {
+		status, response = handler(req)
+	}
+
+	// the client refuses to talk to a server that does not identify as Elasticsearch
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, response)
+}
+
+func (s *Server) match(req Request) HandlerFunc {
+	for _, route := range s.routes {
+		if route.method != req.Method {
+			continue
+		}
+		if ok, _ := path.Match(route.pattern, req.Path); ok {
+			return route.handler
+		}
+	}
+	return nil
+}
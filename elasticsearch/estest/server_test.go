@@ -0,0 +1,108 @@
+package estest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func send(t *testing.T, s *Server, method string, path string, body string) (int, string) {
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "Elasticsearch", res.Header.Get("X-Elastic-Product"))
+	data, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, string(data)
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(t)
+	s.Handle(http.MethodPost, "/products/_search", http.StatusOK, `{"hits": {"hits": []}}`)
+	s.Handle(http.MethodGet, "/products/_doc/*", http.StatusOK, `{"found": true}`)
+	s.HandleSequence(http.MethodGet, "/_cluster/health", http.StatusOK, `{"status": "red"}`, `{"status": "green"}`)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "exact path", method: http.MethodPost, path: "/products/_search", wantStatus: http.StatusOK, wantBody: `{"hits": {"hits": []}}`},
+		{name: "pattern", method: http.MethodGet, path: "/products/_doc/7", wantStatus: http.StatusOK, wantBody: `{"found": true}`},
+		{name: "other method", method: http.MethodGet, path: "/products/_search", wantStatus: http.StatusNotFound},
+		{name: "pattern does not cross segments", method: http.MethodGet, path: "/products/_doc/7/_source", wantStatus: http.StatusNotFound},
+		{name: "sequence", method: http.MethodGet, path: "/_cluster/health", wantStatus: http.StatusOK, wantBody: `{"status": "red"}`},
+		{name: "sequence repeats its last response", method: http.MethodGet, path: "/_cluster/health", wantStatus: http.StatusOK, wantBody: `{"status": "green"}`},
+		{name: "last of sequence", method: http.MethodGet, path: "/_cluster/health", wantStatus: http.StatusOK, wantBody: `{"status": "green"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := send(t, s, tt.method, tt.path, "")
+			assert.Equal(t, tt.wantStatus, status)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestServer_Requests(t *testing.T) {
+	s := NewServer(t)
+	s.HandleFunc(http.MethodPost, "/products/_search", func(req Request) (int, string) {
+		return http.StatusOK, `{"size": "` + req.Query.Get("size") + `"}`
+	})
+
+	_, ok := s.LastRequest()
+	assert.False(t, ok)
+
+	_, body := send(t, s, http.MethodPost, "/products/_search?size=3", `{"query": {"match_all": {}}}`)
+	assert.JSONEq(t, `{"size": "3"}`, body)
+	send(t, s, http.MethodDelete, "/products", "")
+
+	requests := s.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/products/_search", requests[0].Path)
+	assert.Equal(t, `{"query": {"match_all": {}}}`, string(requests[0].Body))
+	last, ok := s.LastRequest()
+	require.True(t, ok)
+	assert.Equal(t, http.MethodDelete, last.Method)
+
+	s.Reset()
+	assert.Empty(t, s.Requests())
+}
+
+func TestNormalizeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "document", body: `{"size":10,"from":0}`, want: "{\n  \"from\": 0,\n  \"size\": 10\n}\n"},
+		{name: "ndjson", body: "{\"index\":\"a\"}\n{\"size\":1}\n", want: "{\n  \"index\": \"a\"\n}\n\n{\n  \"size\": 1\n}\n"},
+		{name: "large numbers are kept", body: `{"id":12345678901234567890}`, want: "{\n  \"id\": 12345678901234567890\n}\n"},
+		{name: "empty", body: ``, wantErr: true},
+		{name: "invalid", body: `{"size":`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeJSON([]byte(tt.body))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
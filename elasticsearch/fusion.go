@@ -0,0 +1,175 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/kdjuwidja/aishoppercommon/logger"
+)
+
+const defaultRRFRankConstant = 60
+
+// FusionMethod decides how the ranked results of several queries are combined into one list
+type FusionMethod string
+
+const (
+	// FusionRRF scores a document with the sum of weight / (rank constant + rank) over the queries that returned it.
+	// It only looks at ranks, so it works for queries whose scores are not comparable, e.g. BM25 and kNN.
+	FusionRRF FusionMethod = "rrf"
+	// FusionWeightedScore scales the scores of each query to [0, 1] and sums them multiplied by the query weight
+	FusionWeightedScore FusionMethod = "weighted_score"
+)
+
+// FusionOptions configures SearchFused and FuseResults
+type FusionOptions struct {
+	// Method defaults to FusionRRF
+	Method FusionMethod
+	// RankConstant is the k of FusionRRF, 60 by default. Higher values flatten the difference between ranks.
+	RankConstant int
+	// Size limits the number of fused hits, 0 returns all of them
+	Size int
+}
+
+func (o FusionOptions) withDefaults() FusionOptions {
+	if o.Method == "" {
+		o.Method = FusionRRF
+	}
+	if o.RankConstant == 0 {
+		o.RankConstant = defaultRRFRankConstant
+	}
+	return o
+}
+
+// FusedHit is a document of the fused list. Hit is the hit of the first query that returned it,
+// Ranks holds its 1-based rank in every query, 0 when the query did not return it.
+type FusedHit struct {
+	Hit
+	FusedScore float64
+	Ranks      []int
+}
+
+// SearchFused runs the queries of a multi-query and fuses their results into a single list ranked by FusedScore,
+// with every _id appearing once. The weight of each query is set with AddWeightedQuery.
+//
+// Queries that fail are logged as warnings and left out of the fusion, their Ranks stay 0. The call only
+// fails when the request as a whole or every one of the queries failed.
+func (es *ElasticsearchClient) SearchFused(ctx context.Context, index string, query *MultiESQuery, fusion FusionOptions, opts ...RequestOption) ([]FusedHit, error) {
+	result, err := es.MultiSearch(ctx, index, query, MultiSearchOptions{}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*SearchResult, len(result.Responses))
+	succeeded := 0
+	for i, response := range result.Responses {
+		if response.Err != nil {
+			logger.Warnf("fused search: leaving out failed query: %v", response.Err)
+			continue
+		}
+		results[i] = response.Result
+		succeeded++
+	}
+	if succeeded == 0 {
+		return nil, result.Err()
+	}
+
+	return FuseResults(results, query.weights, fusion)
+}
+
+// FuseResults fuses search results into a single list ranked by FusedScore, see SearchFused.
+// weights holds one weight per result, nil weighs them all 1.
+func FuseResults(results []*SearchResult, weights []float64, opts FusionOptions) ([]FusedHit, error) {
+	opts = opts.withDefaults()
+	if weights == nil {
+		weights = make([]float64, len(results))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	if len(weights) != len(results) {
+		return nil, fmt.Errorf("fusion got %d weights for %d results", len(weights), len(results))
+	}
+	for i, weight := range weights {
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("invalid weight %v for query %d", weight, i)
+		}
+	}
+	if opts.RankConstant < 1 {
+		return nil, fmt.Errorf("rrf rank constant must be at least 1")
+	}
+	if opts.Size < 0 {
+		return nil, fmt.Errorf("fusion size cannot be negative")
+	}
+
+	var score func(result *SearchResult, rank int) float64
+	switch opts.Method {
+	case FusionRRF:
+		score = func(_ *SearchResult, rank int) float64 {
+			return 1 / float64(opts.RankConstant+rank)
+		}
+	case FusionWeightedScore:
+		score = normalizedScore
+	default:
+		return nil, fmt.Errorf("unknown fusion method %q", opts.Method)
+	}
+
+	var fused []*FusedHit
+	byID := map[string]*FusedHit{}
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		for j, hit := range result.Hits {
+			rank := j + 1
+			doc, ok := byID[hit.ID]
+			if !ok {
+				doc = &FusedHit{Hit: hit, Ranks: make([]int, len(results))}
+				byID[hit.ID] = doc
+				fused = append(fused, doc)
+			}
+			// a query can return the same _id from several indices, only its best rank counts
+			if doc.Ranks[i] != 0 {
+				continue
+			}
+			doc.Ranks[i] = rank
+			doc.FusedScore += weights[i] * score(result, rank)
+		}
+	}
+
+	// stable so that ties keep the order in which the queries returned them
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].FusedScore > fused[j].FusedScore
+	})
+	if opts.Size > 0 && len(fused) > opts.Size {
+		fused = fused[:opts.Size]
+	}
+
+	hits := make([]FusedHit, len(fused))
+	for i, doc := range fused {
+		hits[i] = *doc
+	}
+	return hits, nil
+}
+
+// normalizedScore min-max scales the score of a hit within its result. Hits without a score, e.g. of a query
+// sorted by a field, are scaled by rank instead.
+func normalizedScore(result *SearchResult, rank int) float64 {
+	hit := result.Hits[rank-1]
+	if hit.Score == nil {
+		return 1 - float64(rank-1)/float64(len(result.Hits))
+	}
+
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, other := range result.Hits {
+		if other.Score != nil {
+			lowest = min(lowest, *other.Score)
+			highest = max(highest, *other.Score)
+		}
+	}
+	if highest == lowest {
+		return 1
+	}
+	return (*hit.Score - lowest) / (highest - lowest)
+}
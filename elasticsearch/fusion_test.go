@@ -0,0 +1,188 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scoredResult returns a search result with hits of the given ids, scored from scores when given
+func scoredResult(ids []string, scores ...float64) *SearchResult {
+	result := &SearchResult{}
+	for i, id := range ids {
+		hit := Hit{Index: "products", ID: id}
+		if i < len(scores) {
+			score := scores[i]
+			hit.Score = &score
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result
+}
+
+func fusedIDs(hits []FusedHit) []string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+func TestFuseResults_RRF(t *testing.T) {
+	keyword := scoredResult([]string{"a", "b", "c"}, 12, 8, 3)
+	category := scoredResult([]string{"c", "d", "a"}, 2.1, 2, 1.5)
+
+	hits, err := FuseResults([]*SearchResult{keyword, category}, nil, FusionOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "c", "b", "d"}, fusedIDs(hits))
+	assert.InDelta(t, 1.0/61+1.0/63, hits[0].FusedScore, 1e-12)
+	assert.InDelta(t, 1.0/63+1.0/61, hits[1].FusedScore, 1e-12)
+	assert.Equal(t, []int{1, 3}, hits[0].Ranks)
+	assert.Equal(t, []int{2, 0}, hits[2].Ranks)
+	assert.Equal(t, []int{0, 2}, hits[3].Ranks)
+	assert.Equal(t, "products", hits[0].Index)
+}
+
+func TestFuseResults_Weights(t *testing.T) {
+	keyword := scoredResult([]string{"a", "b"})
+	personalised := scoredResult([]string{"b", "c"})
+
+	hits, err := FuseResults([]*SearchResult{keyword, personalised}, []float64{1, 3}, FusionOptions{RankConstant: 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"b", "c", "a"}, fusedIDs(hits))
+	assert.InDelta(t, 1.0/3+3.0/2, hits[0].FusedScore, 1e-12)
+	assert.InDelta(t, 1.0, hits[1].FusedScore, 1e-12)
+	assert.InDelta(t, 0.5, hits[2].FusedScore, 1e-12)
+}
+
+func TestFuseResults_WeightedScore(t *testing.T) {
+	keyword := scoredResult([]string{"a", "b", "c"}, 20, 10, 0)
+	category := scoredResult([]string{"c", "b"}, 0.9, 0.3)
+
+	hits, err := FuseResults([]*SearchResult{keyword, category}, []float64{1, 2}, FusionOptions{Method: FusionWeightedScore})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"c", "a", "b"}, fusedIDs(hits))
+	assert.InDelta(t, 2.0, hits[0].FusedScore, 1e-12)
+	assert.InDelta(t, 1.0, hits[1].FusedScore, 1e-12)
+	assert.InDelta(t, 0.5, hits[2].FusedScore, 1e-12)
+}
+
+func TestFuseResults_WeightedScoreWithoutScores(t *testing.T) {
+	sorted := scoredResult([]string{"a", "b", "c", "d"})
+	single := scoredResult([]string{"d"}, 4)
+
+	hits, err := FuseResults([]*SearchResult{sorted, single}, nil, FusionOptions{Method: FusionWeightedScore})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"d", "a", "b", "c"}, fusedIDs(hits))
+	assert.InDelta(t, 1.25, hits[0].FusedScore, 1e-12)
+	assert.InDelta(t, 1.0, hits[1].FusedScore, 1e-12)
+	assert.InDelta(t, 0.75, hits[2].FusedScore, 1e-12)
+}
+
+func TestFuseResults_SizeAndDuplicates(t *testing.T) {
+	// the same _id from two indices of a query only counts once, at its best rank
+	keyword := scoredResult([]string{"a", "b", "a", "c"})
+	keyword.Hits[2].Index = "products-old"
+
+	hits, err := FuseResults([]*SearchResult{keyword, nil}, nil, FusionOptions{Size: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "b"}, fusedIDs(hits))
+	assert.Equal(t, "products", hits[0].Index)
+	assert.Equal(t, []int{1, 0}, hits[0].Ranks)
+	assert.InDelta(t, 1.0/61, hits[0].FusedScore, 1e-12)
+}
+
+func TestFuseResults_Invalid(t *testing.T) {
+	results := []*SearchResult{scoredResult([]string{"a"}), scoredResult([]string{"b"})}
+
+	tests := []struct {
+		name    string
+		weights []float64
+		opts    FusionOptions
+		wantErr string
+	}{
+		{name: "weight count", weights: []float64{1}, wantErr: "fusion got 1 weights for 2 results"},
+		{name: "negative weight", weights: []float64{1, -1}, wantErr: "invalid weight -1 for query 1"},
+		{name: "rank constant", opts: FusionOptions{RankConstant: -5}, wantErr: "rrf rank constant must be at least 1"},
+		{name: "size", opts: FusionOptions{Size: -1}, wantErr: "fusion size cannot be negative"},
+		{name: "method", opts: FusionOptions{Method: "linear"}, wantErr: `unknown fusion method "linear"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FuseResults(results, tt.weights, tt.opts)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSearchFused(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/products/_msearch", req.URL.Path)
+		return http.StatusOK, `{"responses": [
+			{"hits": {"total": {"value": 2}, "hits": [
+				{"_index": "products", "_id": "1", "_score": 5, "_source": {"name": "milk"}},
+				{"_index": "products", "_id": "2", "_score": 4, "_source": {"name": "oat milk"}}]}},
+			{"hits": {"total": {"value": 1}, "hits": [
+				{"_index": "products", "_id": "2", "_score": 1, "_source": {"name": "oat milk"}}]}}
+		]}`
+	})
+
+	query := CreateMQuery()
+	query.AddQuery(CreateESQuery("products", map[string]interface{}{"query": map[string]interface{}{"match": map[string]interface{}{"name": "milk"}}}))
+	query.AddWeightedQuery(CreateESQuery("products", map[string]interface{}{"query": map[string]interface{}{"term": map[string]interface{}{"category": "dairy"}}}), 2)
+
+	hits, err := es.SearchFused(context.Background(), "products", query, FusionOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"2", "1"}, fusedIDs(hits))
+	assert.InDelta(t, 1.0/62+2.0/61, hits[0].FusedScore, 1e-12)
+	assert.JSONEq(t, `{"name": "oat milk"}`, string(hits[0].Source))
+}
+
+func TestSearchFused_PartialFailure(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, `{"responses": [
+			{"error": {"type": "index_not_found_exception", "reason": "no such index [promotions]"}, "status": 404},
+			{"hits": {"total": {"value": 2}, "hits": [
+				{"_index": "products", "_id": "1", "_score": 5, "_source": {"name": "milk"}},
+				{"_index": "products", "_id": "2", "_score": 4, "_source": {"name": "oat milk"}}]}}
+		]}`
+	})
+
+	query := CreateMQuery()
+	query.AddWeightedQuery(matchAllQuery("promotions"), 2)
+	query.AddQuery(matchAllQuery("products"))
+
+	hits, err := es.SearchFused(context.Background(), "products", query, FusionOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"1", "2"}, fusedIDs(hits))
+	assert.Equal(t, []int{0, 1}, hits[0].Ranks)
+	assert.InDelta(t, 1.0/61, hits[0].FusedScore, 1e-12)
+}
+
+func TestSearchFused_Error(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, `{"responses": [
+			{"error": {"type": "index_not_found_exception", "reason": "no such index [products]"}},
+			{"error": {"type": "search_phase_execution_exception", "reason": "all shards failed"}}
+		]}`
+	})
+
+	query := CreateMQuery()
+	query.AddQuery(CreateESQuery("products", map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}}))
+	query.AddQuery(matchAllQuery("products"))
+
+	// only fails when no query succeeded, with the errors of all of them
+	_, err := es.SearchFused(context.Background(), "products", query, FusionOptions{})
+	assert.ErrorContains(t, err, "index_not_found_exception")
+	assert.ErrorContains(t, err, "search_phase_execution_exception")
+}
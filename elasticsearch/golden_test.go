@@ -0,0 +1,164 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/kdjuwidja/aishoppercommon/elasticsearch"
+	"github.com/kdjuwidja/aishoppercommon/elasticsearch/estest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const emptySearchResponse = `{"took": 1, "hits": {"total": {"value": 0, "relation": "eq"}, "hits": []}}`
+
+func newFakeCluster(t *testing.T) (*estest.Server, elasticsearch.Client) {
+	server := estest.NewServer(t)
+	client, err := elasticsearch.NewElasticsearchClientWithConfig(elasticsearch.ClientConfig{
+		Addresses:    []string{server.URL},
+		DisableRetry: true,
+	})
+	require.NoError(t, err)
+	return server, client
+}
+
+func buildQuery(t *testing.T, builder *elasticsearch.SearchBuilder) *elasticsearch.ESQuery {
+	query, err := builder.Build()
+	require.NoError(t, err)
+	return query
+}
+
+func TestGolden_Search(t *testing.T) {
+	tests := []struct {
+		name  string
+		query func(t *testing.T) *elasticsearch.ESQuery
+	}{
+		{
+			name: "search_builder",
+			query: func(t *testing.T) *elasticsearch.ESQuery {
+				return buildQuery(t, elasticsearch.NewSearchBuilder("products").
+					Query(elasticsearch.NewBoolQuery().
+						Must(elasticsearch.NewMatchQuery("name", "oat milk").Operator("and")).
+						Filter(elasticsearch.NewTermQuery("brand", "oatly"), elasticsearch.NewRangeQuery("price").Lte(5))).
+					Sort("price", elasticsearch.SortAsc).
+					Size(20).
+					SourceIncludes("name", "price").
+					Highlight(elasticsearch.NewHighlight("name")).
+					Aggregation("brands", elasticsearch.NewTermsAggregation("brand")))
+			},
+		},
+		{
+			name: "hybrid_search",
+			query: func(t *testing.T) *elasticsearch.ESQuery {
+				return buildQuery(t, elasticsearch.NewSearchBuilder("products").
+					Retriever(elasticsearch.NewHybridRetriever(
+						elasticsearch.NewMatchQuery("name", "oat milk"),
+						elasticsearch.NewKnnSearch("embedding", []float32{0.1, 0.2, 0.3}))).
+					Size(10))
+			},
+		},
+		{
+			name: "search_as_you_type",
+			query: func(t *testing.T) *elasticsearch.ESQuery {
+				return buildQuery(t, elasticsearch.NewSearchBuilder("products").
+					Query(elasticsearch.NewSearchAsYouTypeQuery("name", "oat mi")).
+					Suggester("did_you_mean", elasticsearch.NewPhraseSuggester("name.trigram", "oat mi").Size(1)))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newFakeCluster(t)
+			server.Handle(http.MethodPost, "/products/_search", http.StatusOK, emptySearchResponse)
+
+			_, err := client.Search(context.Background(), tt.query(t))
+			require.NoError(t, err)
+
+			req, ok := server.LastRequest()
+			require.True(t, ok)
+			estest.AssertGolden(t, tt.name, req.Body)
+		})
+	}
+}
+
+func TestGolden_MultiSearch(t *testing.T) {
+	server, client := newFakeCluster(t)
+	server.Handle(http.MethodPost, "/products/_msearch", http.StatusOK,
+		`{"responses": [`+emptySearchResponse+`, `+emptySearchResponse+`]}`)
+
+	query := elasticsearch.CreateMQuery()
+	require.NoError(t, query.AddNamedQuery("keyword", buildQuery(t, elasticsearch.NewSearchBuilder("products").
+		Query(elasticsearch.NewMatchQuery("name", "oat milk")))))
+	require.NoError(t, query.AddNamedQuery("promotions", buildQuery(t, elasticsearch.NewSearchBuilder("promotions").
+		Query(elasticsearch.NewTermQuery("active", true)).Size(3))))
+
+	result, err := client.MultiSearch(context.Background(), "products", query, elasticsearch.MultiSearchOptions{})
+	require.NoError(t, err)
+	assert.NoError(t, result.Err())
+
+	req, ok := server.LastRequest()
+	require.True(t, ok)
+	estest.AssertGolden(t, "multi_search", req.Body)
+}
+
+// countProducts depends on the smallest interface it needs, so that it can be given a mock
+func countProducts(ctx context.Context, client elasticsearch.SearchClient, brand string) (int64, error) {
+	query, err := elasticsearch.NewSearchBuilder("products").Query(elasticsearch.NewTermQuery("brand", brand)).Size(0).Build()
+	if err != nil {
+		return 0, err
+	}
+	result, err := client.Search(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.Total.Value, nil
+}
+
+func TestFakeCluster_Errors(t *testing.T) {
+	server, client := newFakeCluster(t)
+	server.HandleSequence(http.MethodPost, "/products/_search", http.StatusOK,
+		`{"hits": {"total": {"value": 42, "relation": "eq"}, "hits": []}}`,
+		`{"hits": {"total": {"value": 43, "relation": "eq"}, "hits": []}}`)
+	server.Handle(http.MethodGet, "/products/_doc/*", http.StatusNotFound, `{"_index": "products", "_id": "7", "found": false}`)
+
+	count, err := countProducts(context.Background(), client, "oatly")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), count)
+	count, err = countProducts(context.Background(), client, "oatly")
+	require.NoError(t, err)
+	assert.Equal(t, int64(43), count)
+
+	_, err = client.GetDocument(context.Background(), "products", "7")
+	assert.ErrorIs(t, err, elasticsearch.ErrNotFound)
+
+	// requests without a route are answered like a missing index
+	_, err = client.Search(context.Background(), elasticsearch.CreateESQuery("missing", map[string]interface{}{"size": 0}))
+	assert.ErrorContains(t, err, "404")
+
+	requests := server.Requests()
+	require.Len(t, requests, 4)
+	assert.Equal(t, "/products/_doc/7", requests[2].Path)
+}
+
+func TestFakeCluster_ScanHitsWithoutSource(t *testing.T) {
+	type product struct {
+		Name string `json:"name"`
+	}
+
+	server, client := newFakeCluster(t)
+	server.Handle(http.MethodPost, "/products/_pit", http.StatusOK, `{"id": "pit-1"}`)
+	server.Handle(http.MethodDelete, "/_pit", http.StatusOK, `{"succeeded": true, "num_freed": 1}`)
+	server.Handle(http.MethodPost, "/_search", http.StatusOK, `{"pit_id": "pit-1", "hits": {"hits": [
+		{"_index": "products", "_id": "1", "_source": {"name": "Oat milk"}, "sort": [1]},
+		{"_index": "products", "_id": "2", "fields": {"name": ["Rye bread"]}, "sort": [2]}
+	]}}`)
+
+	var products []product
+	for doc, err := range elasticsearch.Scan[product](context.Background(), client, elasticsearch.CreateESQuery("products", map[string]interface{}{"_source": false}), elasticsearch.ScanOptions{}) {
+		require.NoError(t, err)
+		products = append(products, doc)
+	}
+	assert.Equal(t, []product{{Name: "Oat milk"}, {}}, products)
+}
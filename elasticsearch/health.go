@@ -0,0 +1,136 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/kdjuwidja/aishoppercommon/health"
+	"github.com/kdjuwidja/aishoppercommon/logger"
+)
+
+var (
+	readyInitialBackoff = 100 * time.Millisecond
+	readyMaxBackoff     = 5 * time.Second
+)
+
+// HealthStatus is the health of a cluster: red when a primary shard is unassigned,
+// yellow when only replicas are, green otherwise
+type HealthStatus string
+
+const (
+	HealthRed    HealthStatus = "red"
+	HealthYellow HealthStatus = "yellow"
+	HealthGreen  HealthStatus = "green"
+)
+
+func (s HealthStatus) rank() int {
+	switch s {
+	case HealthGreen:
+		return 3
+	case HealthYellow:
+		return 2
+	case HealthRed:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AtLeast reports whether the status is as healthy as min, e.g. green and yellow are at least yellow
+func (s HealthStatus) AtLeast(min HealthStatus) bool {
+	return s.rank() >= min.rank()
+}
+
+// ClusterHealth is the status of a cluster and the counts of its nodes and shards
+type ClusterHealth struct {
+	ClusterName                 string       `json:"cluster_name"`
+	Status                      HealthStatus `json:"status"`
+	TimedOut                    bool         `json:"timed_out"`
+	NumberOfNodes               int          `json:"number_of_nodes"`
+	NumberOfDataNodes           int          `json:"number_of_data_nodes"`
+	ActivePrimaryShards         int          `json:"active_primary_shards"`
+	ActiveShards                int          `json:"active_shards"`
+	RelocatingShards            int          `json:"relocating_shards"`
+	InitializingShards          int          `json:"initializing_shards"`
+	UnassignedShards            int          `json:"unassigned_shards"`
+	DelayedUnassignedShards     int          `json:"delayed_unassigned_shards"`
+	NumberOfPendingTasks        int          `json:"number_of_pending_tasks"`
+	ActiveShardsPercentAsNumber float64      `json:"active_shards_percent_as_number"`
+}
+
+// Ping checks that the cluster is reachable
+func (es *ElasticsearchClient) Ping(ctx context.Context, opts ...RequestOption) error {
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	return es.perform(ctx, esapi.PingRequest{}, "pinging cluster", nil)
+}
+
+// ClusterHealth returns the health of the cluster, or of the given indices only
+func (es *ElasticsearchClient) ClusterHealth(ctx context.Context, indices []string, opts ...RequestOption) (*ClusterHealth, error) {
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	var clusterHealth ClusterHealth
+	if err := es.perform(ctx, esapi.ClusterHealthRequest{Index: indices}, "getting cluster health", &clusterHealth); err != nil {
+		return nil, err
+	}
+	return &clusterHealth, nil
+}
+
+// checkHealth returns an error if the cluster is unreachable or less healthy than minStatus
+func (es *ElasticsearchClient) checkHealth(ctx context.Context, minStatus HealthStatus) error {
+	clusterHealth, err := es.ClusterHealth(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if !clusterHealth.Status.AtLeast(minStatus) {
+		return fmt.Errorf("cluster %s is %s, expected at least %s (%d unassigned shards)",
+			clusterHealth.ClusterName, clusterHealth.Status, minStatus, clusterHealth.UnassignedShards)
+	}
+	return nil
+}
+
+// WaitUntilReady blocks until the cluster is reachable and at least minStatus, retrying with exponential backoff,
+// e.g. on service startup. It returns the last failure once ctx is done.
+func (es *ElasticsearchClient) WaitUntilReady(ctx context.Context, minStatus HealthStatus) error {
+	if minStatus.rank() == 0 {
+		return fmt.Errorf("invalid health status %q", minStatus)
+	}
+
+	backoff := readyInitialBackoff
+	for {
+		err := es.checkHealth(ctx, minStatus)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("elasticsearch not ready: %w", err)
+		}
+		logger.Warnf("elasticsearch not ready, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("elasticsearch not ready: %w", err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, readyMaxBackoff)
+	}
+}
+
+// HealthCheck returns a readiness check that fails while the cluster is unreachable or less healthy than minStatus
+func (es *ElasticsearchClient) HealthCheck(minStatus HealthStatus) health.Checker {
+	return health.CheckerFunc(func(ctx context.Context) error {
+		return es.checkHealth(ctx, minStatus)
+	})
+}
@@ -0,0 +1,125 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func clusterHealthBody(status HealthStatus) string {
+	return `{"cluster_name": "shop", "status": "` + string(status) + `", "timed_out": false, "number_of_nodes": 3,
+		"number_of_data_nodes": 3, "active_primary_shards": 10, "active_shards": 18, "relocating_shards": 0,
+		"initializing_shards": 0, "unassigned_shards": 2, "delayed_unassigned_shards": 0,
+		"number_of_pending_tasks": 0, "active_shards_percent_as_number": 90.0}`
+}
+
+func TestHealthStatus_AtLeast(t *testing.T) {
+	tests := []struct {
+		status HealthStatus
+		min    HealthStatus
+		want   bool
+	}{
+		{status: HealthGreen, min: HealthYellow, want: true},
+		{status: HealthYellow, min: HealthYellow, want: true},
+		{status: HealthRed, min: HealthYellow, want: false},
+		{status: HealthYellow, min: HealthGreen, want: false},
+		{status: HealthRed, min: HealthRed, want: true},
+		{status: "", min: HealthRed, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status)+">="+string(tt.min), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.status.AtLeast(tt.min))
+		})
+	}
+}
+
+func TestElasticsearchClient_Ping(t *testing.T) {
+	status := http.StatusOK
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, http.MethodHead, req.Method)
+		assert.Equal(t, "/", req.URL.Path)
+		return status, ""
+	})
+	assert.NoError(t, es.Ping(context.Background()))
+
+	status = http.StatusUnauthorized
+	assert.ErrorContains(t, es.Ping(context.Background()), "error pinging cluster")
+}
+
+func TestElasticsearchClient_ClusterHealth(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/_cluster/health/products", req.URL.Path)
+		return http.StatusOK, clusterHealthBody(HealthYellow)
+	})
+
+	clusterHealth, err := es.ClusterHealth(context.Background(), []string{"products"})
+	require.NoError(t, err)
+	assert.Equal(t, &ClusterHealth{
+		ClusterName:                 "shop",
+		Status:                      HealthYellow,
+		NumberOfNodes:               3,
+		NumberOfDataNodes:           3,
+		ActivePrimaryShards:         10,
+		ActiveShards:                18,
+		UnassignedShards:            2,
+		ActiveShardsPercentAsNumber: 90,
+	}, clusterHealth)
+}
+
+func TestElasticsearchClient_WaitUntilReady(t *testing.T) {
+	defer func(initial, max time.Duration) {
+		readyInitialBackoff, readyMaxBackoff = initial, max
+	}(readyInitialBackoff, readyMaxBackoff)
+	readyInitialBackoff, readyMaxBackoff = time.Millisecond, 2*time.Millisecond
+
+	// unreachable, then red, then yellow
+	calls := 0
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		calls++
+		switch calls {
+		case 1:
+			return http.StatusServiceUnavailable, `{"error": {"type": "master_not_discovered_exception", "reason": "no master"}}`
+		case 2:
+			return http.StatusOK, clusterHealthBody(HealthRed)
+		default:
+			return http.StatusOK, clusterHealthBody(HealthYellow)
+		}
+	})
+
+	require.NoError(t, es.WaitUntilReady(context.Background(), HealthYellow))
+	assert.Equal(t, 3, calls)
+}
+
+func TestElasticsearchClient_WaitUntilReady_Timeout(t *testing.T) {
+	defer func(initial time.Duration) { readyInitialBackoff = initial }(readyInitialBackoff)
+	readyInitialBackoff = time.Millisecond
+
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, clusterHealthBody(HealthRed)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := es.WaitUntilReady(ctx, HealthGreen)
+	assert.ErrorContains(t, err, "elasticsearch not ready")
+
+	assert.EqualError(t, es.WaitUntilReady(context.Background(), "blue"), `invalid health status "blue"`)
+}
+
+func TestElasticsearchClient_HealthCheck(t *testing.T) {
+	status := HealthGreen
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, clusterHealthBody(status)
+	})
+	check := es.HealthCheck(HealthYellow)
+
+	assert.NoError(t, check.Check(context.Background()))
+
+	status = HealthRed
+	assert.EqualError(t, check.Check(context.Background()), "cluster shop is red, expected at least yellow (2 unassigned shards)")
+}
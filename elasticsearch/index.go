@@ -0,0 +1,422 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/kdjuwidja/aishoppercommon/logger"
+)
+
+const (
+	reindexPollInterval = 5 * time.Second
+	// reindexAction is the task action of reindex requests
+	reindexAction = "indices:data/write/reindex"
+)
+
+// IndexDefinition is the settings, mappings and aliases an index is created with
+type IndexDefinition struct {
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	Mappings map[string]interface{} `json:"mappings,omitempty"`
+	Aliases  map[string]interface{} `json:"aliases,omitempty"`
+}
+
+// IndexTemplate is applied to every new index whose name matches one of its patterns
+type IndexTemplate struct {
+	IndexPatterns []string         `json:"index_patterns"`
+	Priority      int              `json:"priority,omitempty"`
+	Template      *IndexDefinition `json:"template,omitempty"`
+}
+
+// AliasAction is one change of an atomic alias update
+type AliasAction struct {
+	action string
+	index  string
+	alias  string
+}
+
+// AddAlias points an alias at an index
+func AddAlias(index string, alias string) AliasAction {
+	return AliasAction{action: "add", index: index, alias: alias}
+}
+
+// RemoveAlias removes an alias from an index
+func RemoveAlias(index string, alias string) AliasAction {
+	return AliasAction{action: "remove", index: index, alias: alias}
+}
+
+// CreateIndex creates an index, definition may be nil to use the cluster defaults and templates
+func (es *ElasticsearchClient) CreateIndex(ctx context.Context, index string, definition *IndexDefinition, opts ...RequestOption) error {
+	if index == "" {
+		return fmt.Errorf("index name cannot be empty")
+	}
+	if definition == nil {
+		definition = &IndexDefinition{}
+	}
+	body, err := json.Marshal(definition)
+	if err != nil {
+		return fmt.Errorf("error marshaling index definition: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	req := esapi.IndicesCreateRequest{Index: index, Body: bytes.NewReader(body)}
+	return es.perform(ctx, req, "creating index", nil)
+}
+
+// IndexExists reports whether an index, or an alias, exists
+func (es *ElasticsearchClient) IndexExists(ctx context.Context, index string, opts ...RequestOption) (bool, error) {
+	if index == "" {
+		return false, fmt.Errorf("index name cannot be empty")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	return es.exists(ctx, esapi.IndicesExistsRequest{Index: []string{index}}, "checking index")
+}
+
+// PutIndexTemplate creates or replaces an index template
+func (es *ElasticsearchClient) PutIndexTemplate(ctx context.Context, name string, template *IndexTemplate, opts ...RequestOption) error {
+	if name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	if template == nil || len(template.IndexPatterns) == 0 {
+		return fmt.Errorf("template %s requires at least one index pattern", name)
+	}
+	body, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("error marshaling index template: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	req := esapi.IndicesPutIndexTemplateRequest{Name: name, Body: bytes.NewReader(body)}
+	return es.perform(ctx, req, "putting index template", nil)
+}
+
+// IndexTemplateExists reports whether an index template exists
+func (es *ElasticsearchClient) IndexTemplateExists(ctx context.Context, name string, opts ...RequestOption) (bool, error) {
+	if name == "" {
+		return false, fmt.Errorf("template name cannot be empty")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	return es.exists(ctx, esapi.IndicesExistsIndexTemplateRequest{Name: name}, "checking index template")
+}
+
+// DeleteIndexTemplate deletes an index template, returning ErrNotFound if it does not exist
+func (es *ElasticsearchClient) DeleteIndexTemplate(ctx context.Context, name string, opts ...RequestOption) error {
+	if name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	return es.perform(ctx, esapi.IndicesDeleteIndexTemplateRequest{Name: name}, "deleting index template", nil)
+}
+
+// GetAliasIndices returns the indices an alias points at, sorted by name, or none if the alias does not exist
+func (es *ElasticsearchClient) GetAliasIndices(ctx context.Context, alias string, opts ...RequestOption) ([]string, error) {
+	if alias == "" {
+		return nil, fmt.Errorf("alias cannot be empty")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	var aliases map[string]json.RawMessage
+	err = es.perform(ctx, esapi.IndicesGetAliasRequest{Name: []string{alias}}, "getting alias", &aliases)
+	if errors.Is(err, ErrNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(aliases))
+	for index := range aliases {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// UpdateAliases applies alias changes atomically, e.g. moving an alias from one index to another
+func (es *ElasticsearchClient) UpdateAliases(ctx context.Context, actions []AliasAction, opts ...RequestOption) error {
+	if len(actions) == 0 {
+		return fmt.Errorf("no alias actions to apply")
+	}
+
+	body := make([]map[string]interface{}, 0, len(actions))
+	for _, action := range actions {
+		if action.index == "" || action.alias == "" {
+			return fmt.Errorf("alias action %s requires an index and an alias", action.action)
+		}
+		body = append(body, map[string]interface{}{
+			action.action: map[string]string{"index": action.index, "alias": action.alias},
+		})
+	}
+	bodyBytes, err := json.Marshal(map[string]interface{}{"actions": body})
+	if err != nil {
+		return fmt.Errorf("error marshaling alias actions: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	req := esapi.IndicesUpdateAliasesRequest{Body: bytes.NewReader(bodyBytes)}
+	return es.perform(ctx, req, "updating aliases", nil)
+}
+
+// Reindex copies the documents of source into dest. It runs as a task on the cluster and is polled until it
+// completes, so it is not bound by HTTP timeouts. Documents are copied with external versioning: those
+// missing from dest or changed in source since they were copied are written, those already up to date are
+// counted as version conflicts and skipped. This makes an interrupted reindex safe to run again, and a reindex
+// from source into dest still running on the cluster, e.g. after ctx was cancelled, is waited for rather
+// than started a second time.
+func (es *ElasticsearchClient) Reindex(ctx context.Context, source string, dest string, opts ...RequestOption) (*ByQueryResult, error) {
+	if source == "" || dest == "" {
+		return nil, fmt.Errorf("source and destination index cannot be empty")
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"conflicts": "proceed",
+		"source":    map[string]interface{}{"index": source},
+		"dest":      map[string]interface{}{"index": dest, "version_type": "external"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling reindex: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+	refresh, err := o.refreshBool()
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := es.runningReindex(ctx, source, dest)
+	if err != nil {
+		return nil, err
+	}
+	if running != "" {
+		logger.Infof("reindex of %s into %s already running as task %s, waiting for it", source, dest, running)
+		return es.waitForTask(ctx, running)
+	}
+
+	waitForCompletion := false
+	var task struct {
+		Task string `json:"task"`
+	}
+	req := esapi.ReindexRequest{Body: bytes.NewReader(body), WaitForCompletion: &waitForCompletion, Refresh: refresh}
+	if err := es.perform(ctx, req, "starting reindex", &task); err != nil {
+		return nil, err
+	}
+	if task.Task == "" {
+		return nil, fmt.Errorf("reindex response has no task id")
+	}
+
+	return es.waitForTask(ctx, task.Task)
+}
+
+// runningReindex returns the id of a reindex task from source into dest running on the cluster, if any
+func (es *ElasticsearchClient) runningReindex(ctx context.Context, source string, dest string) (string, error) {
+	var tasks struct {
+		Nodes map[string]struct {
+			Tasks map[string]struct {
+				Description  string `json:"description"`
+				ParentTaskID string `json:"parent_task_id"`
+			} `json:"tasks"`
+		} `json:"nodes"`
+	}
+	detailed := true
+	req := esapi.TasksListRequest{Actions: []string{reindexAction}, Detailed: &detailed}
+	if err := es.perform(ctx, req, "listing reindex tasks", &tasks); err != nil {
+		return "", err
+	}
+
+	// the description of a reindex task names its source and destination
+	description := fmt.Sprintf("reindex from [%s] to [%s]", source, dest)
+	for _, node := range tasks.Nodes {
+		for id, task := range node.Tasks {
+			// sliced reindexes report their slices as child tasks of the one to wait for
+			if task.ParentTaskID == "" && task.Description == description {
+				return id, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// waitForTask polls a by-query or reindex task until it completes and returns its result
+func (es *ElasticsearchClient) waitForTask(ctx context.Context, taskID string) (*ByQueryResult, error) {
+	for {
+		var status struct {
+			Completed bool           `json:"completed"`
+			Response  *ByQueryResult `json:"response"`
+			Error     *ErrorCause    `json:"error"`
+		}
+		if err := es.perform(ctx, esapi.TasksGetRequest{TaskID: taskID}, "getting task", &status); err != nil {
+			return nil, err
+		}
+
+		if status.Completed {
+			if status.Error != nil {
+				return nil, fmt.Errorf("task %s failed: %s", taskID, status.Error)
+			}
+			if status.Response == nil {
+				return nil, fmt.Errorf("task %s completed without a response", taskID)
+			}
+			if len(status.Response.Failures) > 0 {
+				return status.Response, fmt.Errorf("task %s: %d failures, first: %s", taskID, len(status.Response.Failures), truncateBody(status.Response.Failures[0]))
+			}
+			return status.Response, nil
+		}
+
+		select {
+		case <-time.After(reindexPollInterval):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for task %s, which keeps running on the cluster: %w", taskID, ctx.Err())
+		}
+	}
+}
+
+// BlueGreenReindex moves an alias to a freshly built index with no downtime for readers of the alias
+type BlueGreenReindex struct {
+	// Alias is the name clients read from, e.g. products
+	Alias string
+	// Source is the index the alias points at today, e.g. products_v1
+	Source string
+	// Target is the new index, e.g. products_v2
+	Target string
+	// Definition is used to create Target
+	Definition *IndexDefinition
+	// DeleteSource deletes Source once the alias points at Target
+	DeleteSource bool
+}
+
+// BlueGreenReindex creates the target index, copies the source into it, atomically moves the alias from the
+// source to the target and optionally deletes the source. Every step checks the state of the cluster before
+// acting, so if the operation is interrupted it resumes where it stopped when called again with the same spec.
+func (es *ElasticsearchClient) BlueGreenReindex(ctx context.Context, spec BlueGreenReindex) error {
+	if spec.Alias == "" || spec.Source == "" || spec.Target == "" {
+		return fmt.Errorf("alias, source and target cannot be empty")
+	}
+	if spec.Source == spec.Target {
+		return fmt.Errorf("source and target must be different indices")
+	}
+
+	current, err := es.GetAliasIndices(ctx, spec.Alias)
+	if err != nil {
+		return err
+	}
+	swapped := len(current) == 1 && current[0] == spec.Target
+
+	if !swapped {
+		targetExists, err := es.IndexExists(ctx, spec.Target)
+		if err != nil {
+			return err
+		}
+		if !targetExists {
+			logger.Infof("blue/green reindex of %s: creating %s", spec.Alias, spec.Target)
+			if err := es.CreateIndex(ctx, spec.Target, spec.Definition); err != nil {
+				return err
+			}
+		}
+
+		logger.Infof("blue/green reindex of %s: copying %s into %s", spec.Alias, spec.Source, spec.Target)
+		result, err := es.Reindex(ctx, spec.Source, spec.Target, WithRefresh(RefreshTrue))
+		if err != nil {
+			return err
+		}
+		logger.Infof("blue/green reindex of %s: copied %d new and %d changed of %d documents, %d already up to date",
+			spec.Alias, result.Created, result.Updated, result.Total, result.VersionConflicts)
+
+		actions := []AliasAction{AddAlias(spec.Target, spec.Alias)}
+		for _, index := range current {
+			if index != spec.Target {
+				actions = append(actions, RemoveAlias(index, spec.Alias))
+			}
+		}
+		logger.Infof("blue/green reindex of %s: moving alias to %s", spec.Alias, spec.Target)
+		if err := es.UpdateAliases(ctx, actions); err != nil {
+			return err
+		}
+	}
+
+	if spec.DeleteSource {
+		sourceExists, err := es.IndexExists(ctx, spec.Source)
+		if err != nil {
+			return err
+		}
+		if sourceExists {
+			logger.Infof("blue/green reindex of %s: deleting %s", spec.Alias, spec.Source)
+			if err := es.DeleteIndex(ctx, spec.Source); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exists performs a HEAD request, mapping 200 to true and 404 to false
+func (es *ElasticsearchClient) exists(ctx context.Context, req esapi.Request, action string) (bool, error) {
+	res, err := req.Do(ctx, es.client)
+	if err != nil {
+		return false, fmt.Errorf("error %s: %w", action, err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("error %s: %s", action, res.String())
+	}
+}
@@ -0,0 +1,255 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCluster keeps just enough index and alias state to answer the requests of BlueGreenReindex
+type fakeCluster struct {
+	mu      sync.Mutex
+	indices map[string]bool
+	aliases map[string][]string
+	// running is the description of a reindex task left running as node-1:41 by an earlier call
+	running  string
+	requests []string
+}
+
+func (c *fakeCluster) handle(req *http.Request) (int, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	c.requests = append(c.requests, req.Method+" "+path)
+	switch {
+	case req.Method == http.MethodGet && strings.HasPrefix(path, "_alias/"):
+		indices := c.aliases[strings.TrimPrefix(path, "_alias/")]
+		if len(indices) == 0 {
+			return http.StatusNotFound, `{"error":"alias missing","status":404}`
+		}
+		body := map[string]interface{}{}
+		for _, index := range indices {
+			body[index] = map[string]interface{}{"aliases": map[string]interface{}{}}
+		}
+		data, _ := json.Marshal(body)
+		return http.StatusOK, string(data)
+	case req.Method == http.MethodHead:
+		if c.indices[path] {
+			return http.StatusOK, ""
+		}
+		return http.StatusNotFound, ""
+	case req.Method == http.MethodPut:
+		c.indices[path] = true
+		return http.StatusOK, `{"acknowledged":true}`
+	case req.Method == http.MethodDelete:
+		delete(c.indices, path)
+		return http.StatusOK, `{"acknowledged":true}`
+	case path == "_reindex":
+		return http.StatusOK, `{"task":"node-1:42"}`
+	case path == "_tasks":
+		if req.URL.Query().Get("actions") != reindexAction || c.running == "" {
+			return http.StatusOK, `{"nodes":{}}`
+		}
+		return http.StatusOK, `{"nodes":{"node-1":{"tasks":{
+			"node-1:41":{"action":"indices:data/write/reindex","description":"` + c.running + `"},
+			"node-1:43":{"action":"indices:data/write/reindex","description":"` + c.running + `","parent_task_id":"node-1:41"}
+		}}}}`
+	case path == "_tasks/node-1:41", path == "_tasks/node-1:42":
+		return http.StatusOK, `{"completed":true,"response":{"total":3,"created":1,"updated":1,"version_conflicts":1,"failures":[]}}`
+	case path == "_aliases":
+		var body struct {
+			Actions []map[string]map[string]string `json:"actions"`
+		}
+		data, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			return http.StatusBadRequest, `{}`
+		}
+		for _, action := range body.Actions {
+			for kind, target := range action {
+				alias := target["alias"]
+				if kind == "add" {
+					c.aliases[alias] = append(c.aliases[alias], target["index"])
+					continue
+				}
+				var kept []string
+				for _, index := range c.aliases[alias] {
+					if index != target["index"] {
+						kept = append(kept, index)
+					}
+				}
+				c.aliases[alias] = kept
+			}
+		}
+		return http.StatusOK, `{"acknowledged":true}`
+	}
+	return http.StatusBadRequest, `{"error":"unexpected request"}`
+}
+
+func TestElasticsearchClient_BlueGreenReindex(t *testing.T) {
+	cluster := &fakeCluster{
+		indices: map[string]bool{"products_v1": true},
+		aliases: map[string][]string{"products": {"products_v1"}},
+	}
+	client := newTestClient(t, cluster.handle)
+	spec := BlueGreenReindex{
+		Alias:        "products",
+		Source:       "products_v1",
+		Target:       "products_v2",
+		Definition:   &IndexDefinition{Mappings: map[string]interface{}{"properties": map[string]interface{}{}}},
+		DeleteSource: true,
+	}
+
+	require.NoError(t, client.BlueGreenReindex(context.Background(), spec))
+	assert.Equal(t, []string{
+		"GET _alias/products",
+		"HEAD products_v2",
+		"PUT products_v2",
+		"GET _tasks",
+		"POST _reindex",
+		"GET _tasks/node-1:42",
+		"POST _aliases",
+		"HEAD products_v1",
+		"DELETE products_v1",
+	}, cluster.requests)
+	assert.Equal(t, []string{"products_v2"}, cluster.aliases["products"])
+	assert.Equal(t, map[string]bool{"products_v2": true}, cluster.indices)
+
+	// running it again finds the work done
+	cluster.requests = nil
+	require.NoError(t, client.BlueGreenReindex(context.Background(), spec))
+	assert.Equal(t, []string{"GET _alias/products", "HEAD products_v1"}, cluster.requests)
+}
+
+func TestElasticsearchClient_BlueGreenReindex_Resume(t *testing.T) {
+	// a previous run created the target but stopped before moving the alias
+	cluster := &fakeCluster{
+		indices: map[string]bool{"products_v1": true, "products_v2": true},
+		aliases: map[string][]string{"products": {"products_v1"}},
+	}
+	client := newTestClient(t, cluster.handle)
+
+	err := client.BlueGreenReindex(context.Background(), BlueGreenReindex{Alias: "products", Source: "products_v1", Target: "products_v2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"GET _alias/products",
+		"HEAD products_v2",
+		"GET _tasks",
+		"POST _reindex",
+		"GET _tasks/node-1:42",
+		"POST _aliases",
+	}, cluster.requests)
+	assert.Equal(t, []string{"products_v2"}, cluster.aliases["products"])
+	assert.True(t, cluster.indices["products_v1"])
+
+	err = client.BlueGreenReindex(context.Background(), BlueGreenReindex{Alias: "products", Source: "products_v2", Target: "products_v2"})
+	assert.Error(t, err)
+}
+
+func TestElasticsearchClient_BlueGreenReindex_ResumeRunningTask(t *testing.T) {
+	// a previous run was cancelled while its reindex task kept running on the cluster
+	cluster := &fakeCluster{
+		indices: map[string]bool{"products_v1": true, "products_v2": true},
+		aliases: map[string][]string{"products": {"products_v1"}},
+		running: "reindex from [products_v1] to [products_v2]",
+	}
+	client := newTestClient(t, cluster.handle)
+
+	err := client.BlueGreenReindex(context.Background(), BlueGreenReindex{Alias: "products", Source: "products_v1", Target: "products_v2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"GET _alias/products",
+		"HEAD products_v2",
+		"GET _tasks",
+		"GET _tasks/node-1:41",
+		"POST _aliases",
+	}, cluster.requests)
+
+	// a task between other indices is not waited for
+	cluster.requests = nil
+	cluster.aliases["products"] = []string{"products_v1"}
+	cluster.running = "reindex from [orders_v1] to [products_v2]"
+	require.NoError(t, client.BlueGreenReindex(context.Background(), BlueGreenReindex{Alias: "products", Source: "products_v1", Target: "products_v2"}))
+	assert.Contains(t, cluster.requests, "POST _reindex")
+}
+
+func TestElasticsearchClient_Reindex(t *testing.T) {
+	var body string
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		if req.URL.Path == "/_reindex" {
+			data, _ := io.ReadAll(req.Body)
+			body = string(data)
+			assert.Equal(t, "false", req.URL.Query().Get("wait_for_completion"))
+			return http.StatusOK, `{"task":"node-1:7"}`
+		}
+		if req.URL.Path == "/_tasks" {
+			return http.StatusOK, `{"nodes":{}}`
+		}
+		return http.StatusOK, `{"completed":true,"error":{"type":"index_not_found_exception","reason":"no such index [products_v0]"}}`
+	})
+
+	_, err := client.Reindex(context.Background(), "products_v0", "products_v1")
+	assert.ErrorContains(t, err, "index_not_found_exception")
+	assert.JSONEq(t, `{"conflicts":"proceed","source":{"index":"products_v0"},"dest":{"index":"products_v1","version_type":"external"}}`, body)
+}
+
+func TestElasticsearchClient_IndexManagement(t *testing.T) {
+	var method, path, body string
+	status := http.StatusOK
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		method, path = req.Method, req.URL.Path
+		body = ""
+		if req.Body != nil {
+			data, _ := io.ReadAll(req.Body)
+			body = string(data)
+		}
+		return status, `{"acknowledged":true}`
+	})
+	ctx := context.Background()
+
+	require.NoError(t, client.CreateIndex(ctx, "products_v1", &IndexDefinition{
+		Settings: map[string]interface{}{"number_of_shards": 1},
+		Mappings: map[string]interface{}{"properties": map[string]interface{}{"name": map[string]interface{}{"type": "text"}}},
+	}))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/products_v1", path)
+	assert.JSONEq(t, `{"settings":{"number_of_shards":1},"mappings":{"properties":{"name":{"type":"text"}}}}`, body)
+
+	require.NoError(t, client.PutIndexTemplate(ctx, "products", &IndexTemplate{
+		IndexPatterns: []string{"products_*"},
+		Template:      &IndexDefinition{Settings: map[string]interface{}{"number_of_replicas": 1}},
+	}))
+	assert.Equal(t, "/_index_template/products", path)
+	assert.JSONEq(t, `{"index_patterns":["products_*"],"template":{"settings":{"number_of_replicas":1}}}`, body)
+	assert.Error(t, client.PutIndexTemplate(ctx, "products", &IndexTemplate{}))
+
+	require.NoError(t, client.UpdateAliases(ctx, []AliasAction{AddAlias("products_v2", "products"), RemoveAlias("products_v1", "products")}))
+	assert.JSONEq(t, `{"actions":[{"add":{"index":"products_v2","alias":"products"}},{"remove":{"index":"products_v1","alias":"products"}}]}`, body)
+	assert.Error(t, client.UpdateAliases(ctx, nil))
+
+	exists, err := client.IndexExists(ctx, "products_v1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = client.IndexTemplateExists(ctx, "products")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	status = http.StatusNotFound
+	exists, err = client.IndexExists(ctx, "products_v1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	indices, err := client.GetAliasIndices(ctx, "products")
+	require.NoError(t, err)
+	assert.Empty(t, indices)
+	assert.ErrorIs(t, client.DeleteIndexTemplate(ctx, "products"), ErrNotFound)
+
+	status = http.StatusBadRequest
+	assert.Error(t, client.CreateIndex(ctx, "products_v1", nil))
+}
@@ -0,0 +1,388 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+	"github.com/kdjuwidja/aishoppercommon/logger"
+)
+
+// defaultMaxLoggedBodyBytes bounds the request body quoted in slow query logs
+const defaultMaxLoggedBodyBytes = 2048
+
+// redactedValue replaces the values removed from logged request bodies
+const redactedValue = "[redacted]"
+
+// RequestMetrics are the metrics of a request to the cluster. Duration runs until the response headers
+// are received and includes the retries.
+type RequestMetrics struct {
+	// Operation is the API called, e.g. search or bulk, or the name given with WithOperation
+	Operation string
+	Method    string
+	Path      string
+	// Index is the target of the request, empty for cluster level APIs
+	Index    string
+	Status   int
+	Duration time.Duration
+	// ResponseBytes is the Content-Length of the response, or the size of the body read by the client
+	// when the length is unknown
+	ResponseBytes int64
+	// Err is set when no response was received, e.g. on a timeout
+	Err error
+}
+
+// MetricsRecorder receives the metrics of every request, e.g. to update Prometheus histograms
+type MetricsRecorder interface {
+	RecordRequest(ctx context.Context, metrics RequestMetrics)
+}
+
+// MetricsRecorderFunc adapts a function to a MetricsRecorder
+type MetricsRecorderFunc func(ctx context.Context, metrics RequestMetrics)
+
+func (f MetricsRecorderFunc) RecordRequest(ctx context.Context, metrics RequestMetrics) {
+	f(ctx, metrics)
+}
+
+// InstrumentationConfig configures ClientConfig.Instrumentation
+type InstrumentationConfig struct {
+	// Metrics receives the metrics of every request, none are recorded when nil
+	Metrics MetricsRecorder
+	// SlowQueryThreshold logs a warning with the redacted body of the requests taking longer, disabled when zero
+	SlowQueryThreshold time.Duration
+	// Redact removes sensitive values from the logged bodies, RedactStrings by default
+	Redact func(body []byte) []byte
+	// MaxLoggedBodyBytes truncates the logged bodies, 2048 by default
+	MaxLoggedBodyBytes int
+	// Propagate sets the trace headers of a request from its context, PropagateTraceContext by default.
+	// Replace it to inject the headers of a tracing library instead.
+	Propagate func(ctx context.Context, header http.Header)
+}
+
+func (c InstrumentationConfig) withDefaults() InstrumentationConfig {
+	if c.Redact == nil {
+		c.Redact = RedactStrings
+	}
+	if c.MaxLoggedBodyBytes == 0 {
+		c.MaxLoggedBodyBytes = defaultMaxLoggedBodyBytes
+	}
+	if c.Propagate == nil {
+		c.Propagate = PropagateTraceContext
+	}
+	return c
+}
+
+func (c InstrumentationConfig) validate() error {
+	if c.SlowQueryThreshold < 0 {
+		return fmt.Errorf("slow query threshold cannot be negative")
+	}
+	if c.MaxLoggedBodyBytes < 0 {
+		return fmt.Errorf("max logged body bytes cannot be negative")
+	}
+	return nil
+}
+
+// TraceContext identifies the operation a request is made for. Elasticsearch links its own traces to
+// TraceParent and TraceState, and shows OpaqueID in its slow logs and in the tasks API.
+type TraceContext struct {
+	// TraceParent and TraceState are W3C trace context headers
+	TraceParent string
+	TraceState  string
+	OpaqueID    string
+}
+
+type traceContextKey struct{}
+
+// ContextWithTrace attaches a trace context to the requests made with ctx
+func ContextWithTrace(ctx context.Context, trace TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// TraceFromContext returns the trace context attached with ContextWithTrace
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	trace, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return trace, ok
+}
+
+// PropagateTraceContext sets the traceparent, tracestate and X-Opaque-Id headers from ContextWithTrace
+func PropagateTraceContext(ctx context.Context, header http.Header) {
+	trace, ok := TraceFromContext(ctx)
+	if !ok {
+		return
+	}
+	setHeaderIfNotEmpty(header, "traceparent", trace.TraceParent)
+	setHeaderIfNotEmpty(header, "tracestate", trace.TraceState)
+	setHeaderIfNotEmpty(header, "X-Opaque-Id", trace.OpaqueID)
+}
+
+func setHeaderIfNotEmpty(header http.Header, key string, value string) {
+	if value != "" {
+		header.Set(key, value)
+	}
+}
+
+type operationKey struct{}
+
+// WithOperation names the call in metrics and slow query logs, e.g. "autocomplete" rather than search
+func WithOperation(name string) RequestOption {
+	return func(o *requestOptions) {
+		o.operation = name
+	}
+}
+
+// instrumentedTransport records the metrics of the requests of a client and propagates their trace context.
+// It wraps the transport of the client, so that a request is measured once whatever the number of retries.
+type instrumentedTransport struct {
+	next elastictransport.Interface
+	cfg  InstrumentationConfig
+}
+
+func newInstrumentedTransport(next elastictransport.Interface, cfg InstrumentationConfig) *instrumentedTransport {
+	return &instrumentedTransport{next: next, cfg: cfg.withDefaults()}
+}
+
+func (t *instrumentedTransport) Perform(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	t.cfg.Propagate(ctx, req.Header)
+
+	var body []byte
+	if t.cfg.SlowQueryThreshold > 0 && req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body: %w", err)
+		}
+		body = data
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	metrics := RequestMetrics{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Index:  requestIndex(req.URL.Path),
+	}
+	metrics.Operation, _ = ctx.Value(operationKey{}).(string)
+	if metrics.Operation == "" {
+		metrics.Operation = operationName(req.Method, req.URL.Path)
+	}
+
+	start := time.Now()
+	res, err := t.next.Perform(req)
+	metrics.Duration = time.Since(start)
+	if err != nil {
+		metrics.Err = err
+		t.record(ctx, metrics, body)
+		return nil, err
+	}
+
+	metrics.Status = res.StatusCode
+	// the size is only known once the caller has read the response
+	res.Body = &countingBody{ReadCloser: res.Body, done: func(n int64) {
+		metrics.ResponseBytes = max(n, res.ContentLength)
+		t.record(ctx, metrics, body)
+	}}
+	return res, nil
+}
+
+func (t *instrumentedTransport) record(ctx context.Context, metrics RequestMetrics, body []byte) {
+	if t.cfg.Metrics != nil {
+		t.cfg.Metrics.RecordRequest(ctx, metrics)
+	}
+	if t.cfg.SlowQueryThreshold == 0 || metrics.Duration < t.cfg.SlowQueryThreshold {
+		return
+	}
+
+	logger.Warn(t.slowQueryMessage(metrics, body))
+}
+
+// slowQueryMessage describes a slow request with its redacted body
+func (t *instrumentedTransport) slowQueryMessage(metrics RequestMetrics, body []byte) string {
+	outcome := fmt.Sprintf("status %d", metrics.Status)
+	if metrics.Err != nil {
+		outcome = fmt.Sprintf("error %v", metrics.Err)
+	}
+	return fmt.Sprintf("slow elasticsearch %s %s %s took %s (%s): %s", metrics.Operation, metrics.Method, metrics.Path,
+		metrics.Duration, outcome, t.loggedBody(body))
+}
+
+// loggedBody redacts and truncates a request body for the logs
+func (t *instrumentedTransport) loggedBody(body []byte) string {
+	if len(body) == 0 {
+		return "<no body>"
+	}
+	redacted := t.cfg.Redact(body)
+	if len(redacted) > t.cfg.MaxLoggedBodyBytes {
+		return fmt.Sprintf("%s... (%d bytes)", redacted[:t.cfg.MaxLoggedBodyBytes], len(redacted))
+	}
+	return string(redacted)
+}
+
+// countingBody counts the bytes read from a response body and reports them once when it is closed
+type countingBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(n int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.done(b.n)
+	})
+	return err
+}
+
+// unredactedKeys hold names of fields, indices and options rather than values that may be sensitive
+var unredactedKeys = map[string]bool{
+	"index":             true,
+	"_index":            true,
+	"field":             true,
+	"fields":            true,
+	"type":              true,
+	"operator":          true,
+	"order":             true,
+	"analyzer":          true,
+	"format":            true,
+	"lang":              true,
+	"id":                true,
+	"_source":           true,
+	"includes":          true,
+	"excludes":          true,
+	"calendar_interval": true,
+}
+
+// RedactStrings replaces the string values of a JSON or NDJSON body, e.g. the text a user searched for,
+// keeping the structure of the query, its numbers and the names of fields and indices
+func RedactStrings(body []byte) []byte {
+	var redacted [][]byte
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	for decoder.More() {
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			return []byte(redactedValue)
+		}
+		data, err := json.Marshal(redactValue(document, false))
+		if err != nil {
+			return []byte(redactedValue)
+		}
+		redacted = append(redacted, data)
+	}
+	return bytes.Join(redacted, []byte("\n"))
+}
+
+func redactValue(value interface{}, keep bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			redacted[key] = redactValue(child, unredactedKeys[key])
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, child := range v {
+			redacted[i] = redactValue(child, keep)
+		}
+		return redacted
+	case string:
+		if keep {
+			return v
+		}
+		return redactedValue
+	default:
+		return v
+	}
+}
+
+// requestIndex returns the index, alias or comma separated indices a request targets
+func requestIndex(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if strings.HasPrefix(segment, "_") {
+		return ""
+	}
+	return segment
+}
+
+// operationNames name the APIs whose name depends on the method or spans two path segments,
+// after the names of the go-elasticsearch API
+var operationNames = map[string]string{
+	"PUT doc":               "index",
+	"POST doc":              "index",
+	"GET doc":               "get",
+	"HEAD doc":              "exists",
+	"DELETE doc":            "delete",
+	"POST pit":              "open_point_in_time",
+	"DELETE pit":            "close_point_in_time",
+	"GET mapping":           "indices.get_mapping",
+	"PUT mapping":           "indices.put_mapping",
+	"GET alias":             "indices.get_alias",
+	"POST aliases":          "indices.update_aliases",
+	"PUT index_template":    "indices.put_index_template",
+	"HEAD index_template":   "indices.exists_index_template",
+	"GET index_template":    "indices.get_index_template",
+	"DELETE index_template": "indices.delete_index_template",
+	"PUT scripts":           "put_script",
+	"POST scripts":          "put_script",
+	"GET scripts":           "get_script",
+	"DELETE scripts":        "delete_script",
+	"search/template":       "search_template",
+	"msearch/template":      "msearch_template",
+	"render/template":       "render_search_template",
+	"validate/query":        "indices.validate_query",
+	"search/scroll":         "scroll",
+	"cluster/health":        "cluster.health",
+	"PUT index":             "indices.create",
+	"GET index":             "indices.get",
+	"HEAD index":            "indices.exists",
+	"DELETE index":          "indices.delete",
+	"GET ":                  "info",
+	"HEAD ":                 "ping",
+}
+
+// operationName names the API of a request from its method and path, e.g. search for POST /products/_search
+func operationName(method string, path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "_") {
+			continue
+		}
+		api := strings.TrimPrefix(segment, "_")
+		if i+1 < len(segments) {
+			if name, ok := operationNames[api+"/"+segments[i+1]]; ok {
+				return name
+			}
+		}
+		if name, ok := operationNames[method+" "+api]; ok {
+			return name
+		}
+		return api
+	}
+
+	// the path is empty or only holds an index
+	key := method + " "
+	if segments[0] != "" {
+		key += "index"
+	}
+	if name, ok := operationNames[key]; ok {
+		return name
+	}
+	return strings.ToLower(method)
+}
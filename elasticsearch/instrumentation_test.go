@@ -0,0 +1,211 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metricsRecorder keeps the metrics of every request
+type metricsRecorder struct {
+	mu      sync.Mutex
+	metrics []RequestMetrics
+}
+
+func (r *metricsRecorder) RecordRequest(ctx context.Context, metrics RequestMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, metrics)
+}
+
+func newInstrumentedClient(t *testing.T, transport http.RoundTripper, cfg InstrumentationConfig) *ElasticsearchClient {
+	es, err := NewElasticsearchClientWithConfig(ClientConfig{
+		Addresses:       []string{"http://elasticsearch.test:9200"},
+		Transport:       transport,
+		RetryOnStatus:   []int{http.StatusServiceUnavailable},
+		Instrumentation: &cfg,
+	})
+	require.NoError(t, err)
+	return es
+}
+
+func TestInstrumentation_Metrics(t *testing.T) {
+	recorder := &metricsRecorder{}
+	transport := &recordingTransport{statuses: []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusNotFound}}
+	es := newInstrumentedClient(t, transport, InstrumentationConfig{Metrics: recorder})
+
+	// the retry of the first request is measured as part of it
+	require.NoError(t, es.DeleteIndex(context.Background(), "products"))
+	_, err := es.Exists(context.Background(), "products", "7", WithOperation("product-lookup"))
+	require.NoError(t, err)
+
+	require.Len(t, transport.requests, 3)
+	require.Len(t, recorder.metrics, 2)
+	deleteMetrics := recorder.metrics[0]
+	assert.Equal(t, "indices.delete", deleteMetrics.Operation)
+	assert.Equal(t, http.MethodDelete, deleteMetrics.Method)
+	assert.Equal(t, "/products", deleteMetrics.Path)
+	assert.Equal(t, "products", deleteMetrics.Index)
+	assert.Equal(t, http.StatusOK, deleteMetrics.Status)
+	assert.Equal(t, int64(len(`{"acknowledged": true}`)), deleteMetrics.ResponseBytes)
+	assert.NoError(t, deleteMetrics.Err)
+
+	assert.Equal(t, "product-lookup", recorder.metrics[1].Operation)
+	assert.Equal(t, http.StatusNotFound, recorder.metrics[1].Status)
+}
+
+func TestInstrumentation_Error(t *testing.T) {
+	recorder := &metricsRecorder{}
+	failure := errors.New("connection reset")
+	es := newInstrumentedClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, failure
+	}), InstrumentationConfig{Metrics: recorder})
+
+	_, err := es.Search(context.Background(), CreateESQuery("products", map[string]interface{}{"size": 1}))
+	require.Error(t, err)
+
+	require.Len(t, recorder.metrics, 1)
+	assert.Equal(t, "search", recorder.metrics[0].Operation)
+	assert.Equal(t, 0, recorder.metrics[0].Status)
+	assert.ErrorIs(t, recorder.metrics[0].Err, failure)
+}
+
+func TestInstrumentation_TraceHeaders(t *testing.T) {
+	transport := &recordingTransport{}
+	es := newInstrumentedClient(t, transport, InstrumentationConfig{})
+
+	ctx := ContextWithTrace(context.Background(), TraceContext{
+		TraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		OpaqueID:    "checkout-42",
+	})
+	require.NoError(t, es.DeleteIndex(ctx, "products"))
+	require.NoError(t, es.DeleteIndex(context.Background(), "products"))
+
+	require.Len(t, transport.requests, 2)
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", transport.requests[0].Header.Get("traceparent"))
+	assert.Empty(t, transport.requests[0].Header.Get("tracestate"))
+	assert.Equal(t, "checkout-42", transport.requests[0].Header.Get("X-Opaque-Id"))
+	assert.Empty(t, transport.requests[1].Header.Get("traceparent"))
+
+	// a tracing library can inject its own headers instead
+	transport = &recordingTransport{}
+	es = newInstrumentedClient(t, transport, InstrumentationConfig{Propagate: func(ctx context.Context, header http.Header) {
+		header.Set("X-B3-TraceId", "80f198ee56343ba864fe8b2a57d3eff7")
+	}})
+	require.NoError(t, es.DeleteIndex(ctx, "products"))
+	assert.Equal(t, "80f198ee56343ba864fe8b2a57d3eff7", transport.requests[0].Header.Get("X-B3-TraceId"))
+	assert.Empty(t, transport.requests[0].Header.Get("traceparent"))
+}
+
+func TestInstrumentation_SlowQueryMessage(t *testing.T) {
+	transport := newInstrumentedTransport(nil, InstrumentationConfig{SlowQueryThreshold: time.Second, MaxLoggedBodyBytes: 100})
+	metrics := RequestMetrics{Operation: "search", Method: http.MethodPost, Path: "/products/_search", Status: http.StatusOK, Duration: 1500 * time.Millisecond}
+
+	body := []byte(`{"query": {"match": {"name": {"query": "oat milk", "operator": "and"}}}, "size": 10}`)
+	assert.Equal(t, `slow elasticsearch search POST /products/_search took 1.5s (status 200): `+
+		`{"query":{"match":{"name":{"operator":"and","query":"[redacted]"}}},"size":10}`, transport.slowQueryMessage(metrics, body))
+
+	long := []byte(`{"query": {"terms": {"sku": ["a", "b", "c", "d", "e", "f", "g", "h", "i", "j"]}}}`)
+	assert.Equal(t, `slow elasticsearch search POST /products/_search took 1.5s (status 200): `+
+		`{"query":{"terms":{"sku":["[redacted]","[redacted]","[redacted]","[redacted]","[redacted]","[redacte... (159 bytes)`, transport.slowQueryMessage(metrics, long))
+
+	metrics.Err = errors.New("context deadline exceeded")
+	assert.Equal(t, "slow elasticsearch search POST /products/_search took 1.5s (error context deadline exceeded): <no body>",
+		transport.slowQueryMessage(metrics, nil))
+}
+
+func TestInstrumentation_KeepsRequestBody(t *testing.T) {
+	var bodies []string
+	es := newInstrumentedClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		data, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(data))
+		status := http.StatusOK
+		if len(bodies) == 1 {
+			status = http.StatusServiceUnavailable
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}, "Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"hits": {"hits": []}}`)),
+		}, nil
+	}), InstrumentationConfig{SlowQueryThreshold: time.Hour})
+
+	_, err := es.Search(context.Background(), CreateESQuery("products", map[string]interface{}{"size": 1}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"size":1}`, `{"size":1}`}, bodies)
+}
+
+func TestRedactStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "query text",
+			body: `{"query": {"bool": {"must": {"multi_match": {"query": "jane@example.com", "fields": ["email", "name"], "type": "best_fields"}}, "filter": {"range": {"price": {"lte": 5}}}}}}`,
+			want: `{"query":{"bool":{"filter":{"range":{"price":{"lte":5}}},"must":{"multi_match":{"fields":["email","name"],"query":"[redacted]","type":"best_fields"}}}}}`,
+		},
+		{
+			name: "ndjson",
+			body: "{\"index\":\"customers\"}\n{\"query\":{\"term\":{\"phone\":\"555-0100\"}}}\n",
+			want: "{\"index\":\"customers\"}\n{\"query\":{\"term\":{\"phone\":\"[redacted]\"}}}",
+		},
+		{
+			name: "not json",
+			body: `name=jane`,
+			want: `[redacted]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(RedactStrings([]byte(tt.body))))
+		})
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodPost, path: "/products/_search", want: "search"},
+		{method: http.MethodPost, path: "/_search", want: "search"},
+		{method: http.MethodPost, path: "/products/_msearch/template", want: "msearch_template"},
+		{method: http.MethodPost, path: "/_bulk", want: "bulk"},
+		{method: http.MethodPut, path: "/products/_doc/7", want: "index"},
+		{method: http.MethodGet, path: "/products/_doc/7", want: "get"},
+		{method: http.MethodHead, path: "/products/_doc/7", want: "exists"},
+		{method: http.MethodPost, path: "/products/_update/7", want: "update"},
+		{method: http.MethodPost, path: "/products/_delete_by_query", want: "delete_by_query"},
+		{method: http.MethodDelete, path: "/_pit", want: "close_point_in_time"},
+		{method: http.MethodGet, path: "/_cluster/health/products", want: "cluster.health"},
+		{method: http.MethodPut, path: "/_index_template/products", want: "indices.put_index_template"},
+		{method: http.MethodPut, path: "/products-v2", want: "indices.create"},
+		{method: http.MethodHead, path: "/products", want: "indices.exists"},
+		{method: http.MethodHead, path: "/", want: "ping"},
+		{method: http.MethodPost, path: "/products", want: "post"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, operationName(tt.method, tt.path))
+		})
+	}
+}
+
+func TestClientConfig_ValidateInstrumentation(t *testing.T) {
+	_, err := NewElasticsearchClientWithConfig(ClientConfig{Instrumentation: &InstrumentationConfig{SlowQueryThreshold: -time.Second}})
+	assert.EqualError(t, err, "invalid elasticsearch config: invalid instrumentation: slow query threshold cannot be negative")
+}
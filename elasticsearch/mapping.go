@@ -0,0 +1,415 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// GenerateMapping derives the mappings of an index from the fields of a struct, for use in IndexDefinition.Mappings.
+//
+// Field names follow the json tags. The es tag sets the mapping parameters of a field, e.g.
+//
+//	Name      string    `json:"name" es:"type=text,analyzer=english,fields.raw=keyword"`
+//	Brand     string    `json:"brand" es:"type=keyword"`
+//	Variants  []Variant `json:"variants" es:"type=nested"`
+//	Embedding []float32 `json:"embedding" es:"type=dense_vector,dims=384,similarity=cosine"`
+//	Internal  string    `json:"internal" es:"-"`
+//
+// Without a type the field type is inferred: strings are keyword, integers long or integer, uint64 unsigned_long,
+// floats double or float, bools boolean, time.Time date and structs object.
+func GenerateMapping(v interface{}) (map[string]interface{}, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("mapping can only be generated from a struct, got %v", t)
+	}
+
+	properties, err := structProperties(t, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"properties": properties}, nil
+}
+
+func structProperties(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	if visiting[t] {
+		return nil, fmt.Errorf("type %v is recursive and cannot be mapped", t)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		esTag := field.Tag.Get("es")
+		name, named, skip := jsonFieldName(field)
+		if skip || esTag == "-" {
+			continue
+		}
+
+		// embedded structs without a json name are flattened into their parent, like encoding/json does
+		fieldType := derefType(field.Type)
+		if field.Anonymous && !named && fieldType.Kind() == reflect.Struct {
+			embedded, err := structProperties(fieldType, visiting)
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range embedded {
+				properties[key] = value
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		mapping, err := fieldMapping(field.Type, esTag, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		properties[name] = mapping
+	}
+
+	return properties, nil
+}
+
+// jsonFieldName returns the name of a field in the document, whether the json tag named it, and whether it is skipped
+func jsonFieldName(field reflect.StructField) (string, bool, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		return field.Name, false, false
+	}
+	return name, true, false
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func fieldMapping(t reflect.Type, tag string, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	mapping, err := parseESTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	// arrays are mapped like their elements, except for []byte which encoding/json writes as a base64 string
+	elem := derefType(t)
+	if (elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array) && elem.Elem().Kind() != reflect.Uint8 {
+		elem = derefType(elem.Elem())
+	}
+
+	esType, _ := mapping["type"].(string)
+	if elem.Kind() == reflect.Struct && elem != timeType {
+		switch esType {
+		case "object":
+			// objects are the default and Elasticsearch does not report their type
+			delete(mapping, "type")
+		case "", "nested", "flattened":
+		default:
+			return nil, fmt.Errorf("struct type %v cannot be mapped as %s", elem, esType)
+		}
+		if esType == "flattened" {
+			return mapping, nil
+		}
+
+		properties, err := structProperties(elem, visiting)
+		if err != nil {
+			return nil, err
+		}
+		mapping["properties"] = properties
+		return mapping, nil
+	}
+
+	if esType == "" {
+		inferred, ok := inferFieldType(elem)
+		if !ok {
+			return nil, fmt.Errorf("cannot infer the mapping type of %v, set it with es:\"type=...\"", t)
+		}
+		mapping["type"] = inferred
+	}
+	if esType == "dense_vector" {
+		if _, ok := mapping["dims"]; !ok {
+			return nil, fmt.Errorf("dense_vector requires dims")
+		}
+	}
+
+	return mapping, nil
+}
+
+func inferFieldType(t reflect.Type) (string, bool) {
+	if t == timeType {
+		return "date", true
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "keyword", true
+	case reflect.Bool:
+		return "boolean", true
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return "long", true
+	case reflect.Uint, reflect.Uint64:
+		return "unsigned_long", true
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "integer", true
+	case reflect.Float64:
+		return "double", true
+	case reflect.Float32:
+		return "float", true
+	case reflect.Slice:
+		// only []byte gets here
+		return "binary", true
+	default:
+		return "", false
+	}
+}
+
+// parseESTag parses comma separated key=value mapping parameters. Numbers and booleans are converted,
+// fields.<name>=<type> adds a multi-field, e.g. a keyword copy of a text field.
+func parseESTag(tag string) (map[string]interface{}, error) {
+	mapping := map[string]interface{}{}
+	if tag == "" {
+		return mapping, nil
+	}
+
+	for _, part := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid es tag %q, expected key=value pairs", tag)
+		}
+
+		if subfield, ok := strings.CutPrefix(key, "fields."); ok {
+			fields, _ := mapping["fields"].(map[string]interface{})
+			if fields == nil {
+				fields = map[string]interface{}{}
+				mapping["fields"] = fields
+			}
+			fields[subfield] = map[string]interface{}{"type": value}
+			continue
+		}
+
+		if _, exists := mapping[key]; exists {
+			return nil, fmt.Errorf("es tag %q sets %s twice", tag, key)
+		}
+		mapping[key] = tagValue(value)
+	}
+
+	return mapping, nil
+}
+
+func tagValue(value string) interface{} {
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
+
+// MappingDifference is a field or mapping parameter that differs between two mappings.
+// Parameter is empty when the whole field is missing on one side.
+type MappingDifference struct {
+	Field     string
+	Parameter string
+	Expected  interface{}
+	Actual    interface{}
+}
+
+func (d MappingDifference) String() string {
+	switch {
+	case d.Parameter == "" && d.Actual == nil:
+		return fmt.Sprintf("%s: missing from the index", d.Field)
+	case d.Parameter == "" && d.Expected == nil:
+		return fmt.Sprintf("%s: only in the index", d.Field)
+	default:
+		return fmt.Sprintf("%s: %s is %v in the index, expected %v", d.Field, d.Parameter, d.Actual, d.Expected)
+	}
+}
+
+// mappingDefaults lists mapping parameters that Elasticsearch may report for a field type although they were
+// not set, with their default values. "*" applies to every type.
+var mappingDefaults = map[string]map[string][]interface{}{
+	"*": {
+		"index":      {true},
+		"doc_values": {true},
+		"store":      {false},
+		"enabled":    {true},
+	},
+	"date": {
+		"format": {"strict_date_optional_time||epoch_millis"},
+	},
+	"dense_vector": {
+		"similarity": {"cosine"},
+		// the default depends on the version of Elasticsearch
+		"index_options": {
+			map[string]interface{}{"type": "int8_hnsw", "m": float64(16), "ef_construction": float64(100)},
+			map[string]interface{}{"type": "hnsw", "m": float64(16), "ef_construction": float64(100)},
+		},
+	},
+}
+
+// isMappingDefault reports whether value is a default of a mapping parameter of the field type
+func isMappingDefault(fieldType string, param string, value interface{}) bool {
+	for _, defaults := range []map[string][]interface{}{mappingDefaults[fieldType], mappingDefaults["*"]} {
+		for _, defaultValue := range defaults[param] {
+			if reflect.DeepEqual(defaultValue, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DiffMapping compares an expected mapping, e.g. from GenerateMapping, with the mapping of an index.
+// A parameter set on one side only is not a difference if its value is the default, as Elasticsearch
+// reports some defaults it was not given.
+func DiffMapping(expected map[string]interface{}, actual map[string]interface{}) ([]MappingDifference, error) {
+	var expectedNorm, actualNorm map[string]interface{}
+	if err := normalizeMapping(expected, &expectedNorm); err != nil {
+		return nil, err
+	}
+	if err := normalizeMapping(actual, &actualNorm); err != nil {
+		return nil, err
+	}
+
+	differences := diffProperties("", expectedNorm["properties"], actualNorm["properties"])
+	sort.Slice(differences, func(i, j int) bool {
+		if differences[i].Field != differences[j].Field {
+			return differences[i].Field < differences[j].Field
+		}
+		return differences[i].Parameter < differences[j].Parameter
+	})
+	return differences, nil
+}
+
+// normalizeMapping round trips a mapping through JSON so that both sides use the same number and map types
+func normalizeMapping(mapping map[string]interface{}, out *map[string]interface{}) error {
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("error marshaling mapping: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error parsing mapping: %w", err)
+	}
+	return nil
+}
+
+func diffProperties(prefix string, expected interface{}, actual interface{}) []MappingDifference {
+	expectedFields, _ := expected.(map[string]interface{})
+	actualFields, _ := actual.(map[string]interface{})
+
+	var differences []MappingDifference
+	for name, expectedField := range expectedFields {
+		path := prefix + name
+		actualField, ok := actualFields[name]
+		if !ok {
+			differences = append(differences, MappingDifference{Field: path, Expected: expectedField})
+			continue
+		}
+		differences = append(differences, diffField(path, expectedField, actualField)...)
+	}
+	for name, actualField := range actualFields {
+		if _, ok := expectedFields[name]; !ok {
+			differences = append(differences, MappingDifference{Field: prefix + name, Actual: actualField})
+		}
+	}
+
+	return differences
+}
+
+func diffField(path string, expected interface{}, actual interface{}) []MappingDifference {
+	expectedParams, _ := expected.(map[string]interface{})
+	actualParams, _ := actual.(map[string]interface{})
+
+	fieldType, _ := expectedParams["type"].(string)
+	if fieldType == "" {
+		fieldType, _ = actualParams["type"].(string)
+	}
+
+	var differences []MappingDifference
+	for param, expectedValue := range expectedParams {
+		if param == "properties" {
+			continue
+		}
+		actualValue, ok := actualParams[param]
+		if !ok && isMappingDefault(fieldType, param, expectedValue) {
+			continue
+		}
+		if !reflect.DeepEqual(expectedValue, actualValue) {
+			differences = append(differences, MappingDifference{Field: path, Parameter: param, Expected: expectedValue, Actual: actualValue})
+		}
+	}
+	for param, actualValue := range actualParams {
+		if _, ok := expectedParams[param]; !ok && param != "properties" && !isMappingDefault(fieldType, param, actualValue) {
+			differences = append(differences, MappingDifference{Field: path, Parameter: param, Actual: actualValue})
+		}
+	}
+
+	if expectedParams["properties"] != nil || actualParams["properties"] != nil {
+		differences = append(differences, diffProperties(path+".", expectedParams["properties"], actualParams["properties"])...)
+	}
+	return differences
+}
+
+// GetMapping returns the mappings of an index, or of the single index behind an alias
+func (es *ElasticsearchClient) GetMapping(ctx context.Context, index string, opts ...RequestOption) (map[string]interface{}, error) {
+	if index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	var indices map[string]struct {
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	if err := es.perform(ctx, esapi.IndicesGetMappingRequest{Index: []string{index}}, "getting mapping", &indices); err != nil {
+		return nil, err
+	}
+	if len(indices) != 1 {
+		return nil, fmt.Errorf("%s resolves to %d indices, expected one", index, len(indices))
+	}
+
+	for _, mapping := range indices {
+		return mapping.Mappings, nil
+	}
+	return nil, nil
+}
+
+// DiffIndexMapping compares an expected mapping with the live mapping of an index
+func (es *ElasticsearchClient) DiffIndexMapping(ctx context.Context, index string, expected map[string]interface{}, opts ...RequestOption) ([]MappingDifference, error) {
+	actual, err := es.GetMapping(ctx, index, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return DiffMapping(expected, actual)
+}
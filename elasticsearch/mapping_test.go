@@ -0,0 +1,180 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mappingAudit struct {
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedBy string    `json:"updated_by"`
+}
+
+type mappingVariant struct {
+	Size  string  `json:"size"`
+	Stock int32   `json:"stock"`
+	Price float64 `json:"price" es:"type=scaled_float,scaling_factor=100"`
+}
+
+type mappingProduct struct {
+	mappingAudit
+	SKU        string            `json:"sku"`
+	Name       string            `json:"name" es:"type=text,analyzer=english,fields.raw=keyword"`
+	Tags       []string          `json:"tags"`
+	InStock    bool              `json:"in_stock"`
+	Rating     *float32          `json:"rating,omitempty"`
+	Variants   []mappingVariant  `json:"variants" es:"type=nested"`
+	Seller     mappingAudit      `json:"seller"`
+	Attributes map[string]string `json:"attributes" es:"type=flattened"`
+	Embedding  []float32         `json:"embedding" es:"type=dense_vector,dims=3,similarity=cosine"`
+	Views      uint64            `json:"views"`
+	Revision   uint32            `json:"revision"`
+	Internal   string            `json:"internal" es:"-"`
+	Ignored    string            `json:"-"`
+	unexported string
+}
+
+const mappingProductJSON = `{"properties":{
+	"created_at":{"type":"date"},
+	"updated_by":{"type":"keyword"},
+	"sku":{"type":"keyword"},
+	"name":{"type":"text","analyzer":"english","fields":{"raw":{"type":"keyword"}}},
+	"tags":{"type":"keyword"},
+	"in_stock":{"type":"boolean"},
+	"rating":{"type":"float"},
+	"variants":{"type":"nested","properties":{
+		"size":{"type":"keyword"},
+		"stock":{"type":"integer"},
+		"price":{"type":"scaled_float","scaling_factor":100}}},
+	"seller":{"properties":{"created_at":{"type":"date"},"updated_by":{"type":"keyword"}}},
+	"attributes":{"type":"flattened"},
+	"embedding":{"type":"dense_vector","dims":3,"similarity":"cosine"},
+	"views":{"type":"unsigned_long"},
+	"revision":{"type":"long"}
+}}`
+
+func TestGenerateMapping(t *testing.T) {
+	mapping, err := GenerateMapping(&mappingProduct{})
+	require.NoError(t, err)
+	got, err := json.Marshal(mapping)
+	require.NoError(t, err)
+	assert.JSONEq(t, mappingProductJSON, string(got))
+}
+
+type mappingRecursive struct {
+	Parent *mappingRecursive `json:"parent"`
+}
+
+func TestGenerateMapping_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "not a struct", value: "product"},
+		{name: "nil", value: nil},
+		{name: "recursive type", value: mappingRecursive{}},
+		{name: "map without type", value: struct {
+			Attributes map[string]string `json:"attributes"`
+		}{}},
+		{name: "dense vector without dims", value: struct {
+			Embedding []float32 `json:"embedding" es:"type=dense_vector"`
+		}{}},
+		{name: "struct as keyword", value: struct {
+			Seller mappingAudit `json:"seller" es:"type=keyword"`
+		}{}},
+		{name: "malformed tag", value: struct {
+			Name string `json:"name" es:"type"`
+		}{}},
+		{name: "repeated parameter", value: struct {
+			Name string `json:"name" es:"type=text,type=keyword"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateMapping(tt.value)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDiffMapping(t *testing.T) {
+	expected, err := GenerateMapping(mappingProduct{})
+	require.NoError(t, err)
+
+	var actual map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(mappingProductJSON), &actual))
+	differences, err := DiffMapping(expected, actual)
+	require.NoError(t, err)
+	assert.Empty(t, differences)
+
+	properties := actual["properties"].(map[string]interface{})
+	delete(properties, "sku")
+	properties["legacy_id"] = map[string]interface{}{"type": "long"}
+	properties["name"].(map[string]interface{})["analyzer"] = "standard"
+	properties["variants"].(map[string]interface{})["properties"].(map[string]interface{})["stock"] = map[string]interface{}{"type": "long"}
+
+	differences, err = DiffMapping(expected, actual)
+	require.NoError(t, err)
+	assert.Equal(t, []MappingDifference{
+		{Field: "legacy_id", Actual: map[string]interface{}{"type": "long"}},
+		{Field: "name", Parameter: "analyzer", Expected: "english", Actual: "standard"},
+		{Field: "sku", Expected: map[string]interface{}{"type": "keyword"}},
+		{Field: "variants.stock", Parameter: "type", Expected: "integer", Actual: "long"},
+	}, differences)
+	assert.Equal(t, "legacy_id: only in the index", differences[0].String())
+	assert.Equal(t, "name: analyzer is standard in the index, expected english", differences[1].String())
+	assert.Equal(t, "sku: missing from the index", differences[2].String())
+}
+
+func TestDiffMapping_Defaults(t *testing.T) {
+	expected := map[string]interface{}{"properties": map[string]interface{}{
+		"embedding":  map[string]interface{}{"type": "dense_vector", "dims": 3},
+		"created_at": map[string]interface{}{"type": "date", "index": true},
+		"sku":        map[string]interface{}{"type": "keyword", "index": false},
+		"seller":     map[string]interface{}{"properties": map[string]interface{}{"name": map[string]interface{}{"type": "keyword"}}},
+	}}
+
+	// parameters Elasticsearch reports with their default value are not differences
+	var actual map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"properties":{
+		"embedding":{"type":"dense_vector","dims":3,"index":true,"similarity":"cosine",
+			"index_options":{"type":"int8_hnsw","m":16,"ef_construction":100}},
+		"created_at":{"type":"date","format":"strict_date_optional_time||epoch_millis"},
+		"sku":{"type":"keyword"},
+		"seller":{"enabled":true,"properties":{"name":{"type":"keyword","doc_values":true}}}
+	}}`), &actual))
+
+	differences, err := DiffMapping(expected, actual)
+	require.NoError(t, err)
+	assert.Equal(t, []MappingDifference{{Field: "sku", Parameter: "index", Expected: false}}, differences)
+
+	// other values are
+	embedding := actual["properties"].(map[string]interface{})["embedding"].(map[string]interface{})
+	embedding["similarity"] = "dot_product"
+	differences, err = DiffMapping(expected, actual)
+	require.NoError(t, err)
+	assert.Contains(t, differences, MappingDifference{Field: "embedding", Parameter: "similarity", Actual: "dot_product"})
+}
+
+func TestElasticsearchClient_DiffIndexMapping(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/products/_mapping", req.URL.Path)
+		return http.StatusOK, `{"products_v2":{"mappings":{"properties":{"sku":{"type":"keyword"},"price":{"type":"float"}}}}}`
+	})
+
+	differences, err := client.DiffIndexMapping(context.Background(), "products", map[string]interface{}{
+		"properties": map[string]interface{}{
+			"sku":   map[string]interface{}{"type": "keyword"},
+			"price": map[string]interface{}{"type": "double"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []MappingDifference{{Field: "price", Parameter: "type", Expected: "double", Actual: "float"}}, differences)
+}
@@ -0,0 +1,146 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// SearchError is the failure of one query of a multi-search, e.g. because its index does not exist
+type SearchError struct {
+	// Query is the position of the query in the MultiESQuery
+	Query  int
+	Name   string
+	Status int
+	Cause  ErrorCause
+}
+
+func (e *SearchError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("error in search response %s: %s", e.Name, e.Cause)
+	}
+	return fmt.Sprintf("error in search response %d: %s", e.Query, e.Cause)
+}
+
+// MultiSearchOptions configures MultiSearch
+type MultiSearchOptions struct {
+	// MaxConcurrentSearches limits how many of the queries the cluster runs at once, 0 lets the cluster decide
+	MaxConcurrentSearches int
+	// QueryTimeout bounds the time every query runs on the shards, unless the query set its own with SetTimeout.
+	// A query that times out returns the hits found so far with TimedOut set.
+	QueryTimeout time.Duration
+}
+
+// MultiSearchResponse is the outcome of one query of a multi-search, either Result or Err is set
+type MultiSearchResponse struct {
+	Name   string
+	Result *SearchResult
+	Err    error
+}
+
+// MultiSearchResult holds the responses of a multi-search in the order of the queries of the MultiESQuery
+type MultiSearchResult struct {
+	Responses []MultiSearchResponse
+}
+
+// Get returns the result of the query added with AddNamedQuery, or its error if it failed
+func (r *MultiSearchResult) Get(name string) (*SearchResult, error) {
+	for _, response := range r.Responses {
+		if response.Name == name {
+			return response.Result, response.Err
+		}
+	}
+	return nil, fmt.Errorf("no query named %s", name)
+}
+
+// Err returns the errors of all the failed queries joined together, or nil if all of them succeeded
+func (r *MultiSearchResult) Err() error {
+	var errs []error
+	for _, response := range r.Responses {
+		if response.Err != nil {
+			errs = append(errs, response.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// results returns the result of every query, or the error of the first query that failed
+func (r *MultiSearchResult) results() ([]*SearchResult, error) {
+	results := make([]*SearchResult, len(r.Responses))
+	for i, response := range r.Responses {
+		if response.Err != nil {
+			return nil, response.Err
+		}
+		results[i] = response.Result
+	}
+	return results, nil
+}
+
+// MultiSearch runs the queries of a multi-query in a single request. Unlike SearchDocumentsWithMQuery, a failed
+// query does not fail the call: its error is returned in its response next to the results of the other queries.
+// The returned error is only set when the request as a whole failed.
+func (es *ElasticsearchClient) MultiSearch(ctx context.Context, index string, query *MultiESQuery, msOpts MultiSearchOptions, opts ...RequestOption) (*MultiSearchResult, error) {
+	if query == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	if msOpts.MaxConcurrentSearches < 0 {
+		return nil, fmt.Errorf("max concurrent searches cannot be negative")
+	}
+	if msOpts.QueryTimeout < 0 {
+		return nil, fmt.Errorf("query timeout cannot be negative")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	if msOpts.QueryTimeout > 0 {
+		query = query.withQueryTimeout(msOpts.QueryTimeout)
+	}
+	buffer, err := query.createMQueryBuffer(index)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing multi-search request: %w", err)
+	}
+
+	req := esapi.MsearchRequest{
+		Index: []string{index},
+		Body:  bytes.NewReader(buffer.Bytes()),
+	}
+	if msOpts.MaxConcurrentSearches > 0 {
+		req.MaxConcurrentSearches = &msOpts.MaxConcurrentSearches
+	}
+	res, err := req.Do(ctx, es.client)
+	if err != nil {
+		return nil, fmt.Errorf("error performing multi-search: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error in multi-search response: %s", res.String())
+	}
+
+	responses, err := decodeMultiSearchResponses(res.Body, len(query.queries))
+	if err != nil {
+		return nil, err
+	}
+	return newMultiSearchResult(responses, query.names), nil
+}
+
+// newMultiSearchResult labels the responses with the names of their queries
+func newMultiSearchResult(responses []MultiSearchResponse, names []string) *MultiSearchResult {
+	for i := range responses {
+		responses[i].Name = names[i]
+		var searchErr *SearchError
+		if errors.As(responses[i].Err, &searchErr) {
+			searchErr.Name = names[i]
+		}
+	}
+	return &MultiSearchResult{Responses: responses}
+}
@@ -0,0 +1,154 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// msearchBodies returns the query bodies of a multi-search request, skipping the header lines
+func msearchBodies(t *testing.T, req *http.Request) []map[string]interface{} {
+	var bodies []map[string]interface{}
+	scanner := bufio.NewScanner(req.Body)
+	for line := 0; scanner.Scan(); line++ {
+		if line%2 == 0 {
+			continue
+		}
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &body))
+		bodies = append(bodies, body)
+	}
+	require.NoError(t, scanner.Err())
+	return bodies
+}
+
+func matchAllQuery(index string) *ESQuery {
+	return CreateESQuery(index, map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}})
+}
+
+func TestMultiESQuery_AddNamedQuery(t *testing.T) {
+	query := CreateMQuery()
+	require.NoError(t, query.AddNamedQuery("keyword", matchAllQuery("products")))
+	query.AddQuery(matchAllQuery("products"))
+
+	assert.EqualError(t, query.AddNamedQuery("keyword", matchAllQuery("products")), "query keyword was already added")
+	assert.EqualError(t, query.AddNamedQuery("", matchAllQuery("products")), "query name cannot be empty")
+	assert.EqualError(t, query.AddNamedQuery("category", nil), "query category is nil")
+	assert.Equal(t, []string{"keyword", ""}, query.names)
+	assert.Equal(t, []float64{1, 1}, query.weights)
+}
+
+func TestMultiESQuery_AddNamedWeightedQuery(t *testing.T) {
+	query := CreateMQuery()
+	require.NoError(t, query.AddNamedWeightedQuery("keyword", matchAllQuery("products"), 2))
+	require.NoError(t, query.AddNamedWeightedQuery("", matchAllQuery("products"), 0.5))
+	query.AddWeightedQuery(matchAllQuery("products"), 3)
+
+	assert.EqualError(t, query.AddNamedWeightedQuery("keyword", matchAllQuery("products"), 1), "query keyword was already added")
+	assert.EqualError(t, query.AddNamedWeightedQuery("category", nil, 1), "query category is nil")
+	assert.Equal(t, []string{"keyword", "", ""}, query.names)
+	assert.Equal(t, []float64{2, 0.5, 3}, query.weights)
+}
+
+func TestMultiSearch_PartialFailure(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, `{"responses": [
+			{"took": 2, "hits": {"total": {"value": 1}, "hits": [{"_index": "products", "_id": "1", "_source": {"name": "milk"}}]}, "status": 200},
+			{"error": {"type": "index_not_found_exception", "reason": "no such index [promotions]"}, "status": 404},
+			{"took": 1, "timed_out": true, "hits": {"total": {"value": 0}, "hits": []}, "status": 200}
+		]}`
+	})
+
+	query := CreateMQuery()
+	require.NoError(t, query.AddNamedQuery("keyword", matchAllQuery("products")))
+	require.NoError(t, query.AddNamedQuery("promotions", matchAllQuery("promotions")))
+	query.AddQuery(matchAllQuery("products"))
+
+	result, err := es.MultiSearch(context.Background(), "products", query, MultiSearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Responses, 3)
+
+	keyword, err := result.Get("keyword")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "milk"}`, string(keyword.Hits[0].Source))
+
+	_, err = result.Get("promotions")
+	var searchErr *SearchError
+	require.True(t, errors.As(err, &searchErr))
+	assert.Equal(t, SearchError{Query: 1, Name: "promotions", Status: http.StatusNotFound, Cause: ErrorCause{Type: "index_not_found_exception", Reason: "no such index [promotions]"}}, *searchErr)
+	assert.EqualError(t, err, "error in search response promotions: index_not_found_exception: no such index [promotions]")
+
+	assert.Empty(t, result.Responses[2].Name)
+	assert.True(t, result.Responses[2].Result.TimedOut)
+
+	_, err = result.Get("category")
+	assert.EqualError(t, err, "no query named category")
+	assert.ErrorIs(t, result.Err(), result.Responses[1].Err)
+
+	// the strict variants still fail as a whole
+	_, err = es.SearchDocumentsWithMQuery(context.Background(), "products", query)
+	assert.ErrorContains(t, err, "index_not_found_exception")
+}
+
+func TestMultiSearch_Options(t *testing.T) {
+	var path string
+	var bodies []map[string]interface{}
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		path = req.URL.RequestURI()
+		bodies = msearchBodies(t, req)
+		return http.StatusOK, `{"responses": [{"hits": {"hits": []}}, {"hits": {"hits": []}}]}`
+	})
+
+	slow := matchAllQuery("products")
+	slow.SetTimeout(2 * time.Second)
+	query := CreateMQuery()
+	query.AddQuery(matchAllQuery("products"))
+	query.AddQuery(slow)
+
+	result, err := es.MultiSearch(context.Background(), "products", query, MultiSearchOptions{MaxConcurrentSearches: 2, QueryTimeout: 500 * time.Millisecond})
+	require.NoError(t, err)
+	assert.NoError(t, result.Err())
+
+	assert.Equal(t, "/products/_msearch?max_concurrent_searches=2", path)
+	require.Len(t, bodies, 2)
+	assert.Equal(t, "500ms", bodies[0]["timeout"])
+	assert.Equal(t, "2000ms", bodies[1]["timeout"])
+	// the default timeout is not written into the caller's query
+	assert.NotContains(t, query.queries[0].query, "timeout")
+}
+
+func TestMultiSearch_Invalid(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		t.Fatal("no request expected")
+		return 0, ""
+	})
+	query := CreateMQuery()
+	query.AddQuery(matchAllQuery("products"))
+
+	_, err := es.MultiSearch(context.Background(), "products", nil, MultiSearchOptions{})
+	assert.EqualError(t, err, "query is nil")
+	_, err = es.MultiSearch(context.Background(), "products", query, MultiSearchOptions{MaxConcurrentSearches: -1})
+	assert.EqualError(t, err, "max concurrent searches cannot be negative")
+	_, err = es.MultiSearch(context.Background(), "products", query, MultiSearchOptions{QueryTimeout: -time.Second})
+	assert.EqualError(t, err, "query timeout cannot be negative")
+	_, err = es.MultiSearch(context.Background(), "products", CreateMQuery(), MultiSearchOptions{})
+	assert.ErrorContains(t, err, "no queries")
+}
+
+func TestMultiSearch_RequestError(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusBadRequest, `{"error": {"type": "illegal_argument_exception", "reason": "bad msearch"}}`
+	})
+	query := CreateMQuery()
+	query.AddQuery(matchAllQuery("products"))
+
+	_, err := es.MultiSearch(context.Background(), "products", query, MultiSearchOptions{})
+	assert.ErrorContains(t, err, "illegal_argument_exception")
+}
@@ -0,0 +1,116 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RefreshPolicy controls when the changes of a write become visible to search
+type RefreshPolicy string
+
+const (
+	// RefreshFalse leaves the changes to the next periodic refresh, this is the default
+	RefreshFalse RefreshPolicy = "false"
+	// RefreshTrue refreshes the affected shards immediately
+	RefreshTrue RefreshPolicy = "true"
+	// RefreshWaitFor waits for the next periodic refresh before responding
+	RefreshWaitFor RefreshPolicy = "wait_for"
+)
+
+// RequestOption sets an optional parameter of a request. Options that do not apply to a call are ignored.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	timeout            time.Duration
+	refresh            RefreshPolicy
+	ifSeqNo            *int
+	ifPrimaryTerm      *int
+	proceedOnConflicts bool
+	operation          string
+}
+
+func newRequestOptions(opts []RequestOption) (*requestOptions, error) {
+	o := &requestOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// WithTimeout bounds the call, on top of any deadline of its context
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRefresh sets the refresh policy of a write, e.g. RefreshWaitFor so that the change is searchable on return
+func WithRefresh(refresh RefreshPolicy) RequestOption {
+	return func(o *requestOptions) {
+		o.refresh = refresh
+	}
+}
+
+// IfSeqNo only applies a write if the document was last changed at this sequence number and primary term,
+// as returned by GetDocument or a previous write. Otherwise the write fails with ErrVersionConflict.
+func IfSeqNo(seqNo int64, primaryTerm int64) RequestOption {
+	return func(o *requestOptions) {
+		s, p := int(seqNo), int(primaryTerm)
+		o.ifSeqNo = &s
+		o.ifPrimaryTerm = &p
+	}
+}
+
+// ProceedOnConflicts makes DeleteByQuery and UpdateByQuery count version conflicts instead of aborting on the first one
+func ProceedOnConflicts() RequestOption {
+	return func(o *requestOptions) {
+		o.proceedOnConflicts = true
+	}
+}
+
+// context returns the context of the call, bounded by the timeout if one was set
+func (o *requestOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.operation != "" {
+		ctx = context.WithValue(ctx, operationKey{}, o.operation)
+	}
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return ctx, func() {}
+}
+
+func (o *requestOptions) validate() error {
+	if o.timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	switch o.refresh {
+	case "", RefreshFalse, RefreshTrue, RefreshWaitFor:
+		return nil
+	default:
+		return fmt.Errorf("unknown refresh policy %q", o.refresh)
+	}
+}
+
+// refreshBool returns the refresh policy of APIs that only accept true or false
+func (o *requestOptions) refreshBool() (*bool, error) {
+	switch o.refresh {
+	case "":
+		return nil, nil
+	case RefreshWaitFor:
+		return nil, fmt.Errorf("refresh policy %s is not supported by this request", RefreshWaitFor)
+	default:
+		refresh := o.refresh == RefreshTrue
+		return &refresh, nil
+	}
+}
+
+func (o *requestOptions) conflicts() string {
+	if o.proceedOnConflicts {
+		return "proceed"
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestOptions(t *testing.T) {
+	o, err := newRequestOptions([]RequestOption{WithTimeout(time.Second), WithRefresh(RefreshWaitFor), IfSeqNo(3, 1), ProceedOnConflicts()})
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, o.timeout)
+	assert.Equal(t, RefreshWaitFor, o.refresh)
+	assert.Equal(t, 3, *o.ifSeqNo)
+	assert.Equal(t, 1, *o.ifPrimaryTerm)
+	assert.Equal(t, "proceed", o.conflicts())
+
+	_, err = newRequestOptions([]RequestOption{WithRefresh("sometimes")})
+	assert.ErrorContains(t, err, "unknown refresh policy")
+	_, err = newRequestOptions([]RequestOption{WithTimeout(-time.Second)})
+	assert.Error(t, err)
+
+	for refresh, want := range map[RefreshPolicy]interface{}{"": nil, RefreshTrue: true, RefreshFalse: false} {
+		o, err := newRequestOptions([]RequestOption{WithRefresh(refresh)})
+		require.NoError(t, err)
+		got, err := o.refreshBool()
+		require.NoError(t, err)
+		if want == nil {
+			assert.Nil(t, got)
+		} else {
+			assert.Equal(t, want, *got)
+		}
+	}
+	o, err = newRequestOptions([]RequestOption{WithRefresh(RefreshWaitFor)})
+	require.NoError(t, err)
+	_, err = o.refreshBool()
+	assert.Error(t, err)
+}
+
+func TestElasticsearchClient_Refresh(t *testing.T) {
+	var query string
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		query = req.URL.RawQuery
+		return http.StatusOK, `{"_index":"products","_id":"1","result":"created","total":0,"deleted":0}`
+	})
+	ctx := context.Background()
+
+	require.NoError(t, client.IndexDocument(ctx, "products", testProduct{}, WithRefresh(RefreshWaitFor)))
+	assert.Equal(t, "refresh=wait_for", query)
+
+	_, err := client.DeleteDocument(ctx, "products", "1", WithRefresh(RefreshTrue))
+	require.NoError(t, err)
+	assert.Equal(t, "refresh=true", query)
+
+	_, err = client.DeleteByQuery(ctx, CreateESQuery("products", map[string]interface{}{}), WithRefresh(RefreshWaitFor))
+	assert.Error(t, err)
+}
+
+func TestElasticsearchClient_Context(t *testing.T) {
+	// the fake cluster only answers once the request context is done
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		<-req.Context().Done()
+		return http.StatusOK, `{}`
+	})
+	query := CreateESQuery("products", map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}})
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context, opts ...RequestOption) error
+	}{
+		{name: "index", call: func(ctx context.Context, opts ...RequestOption) error {
+			return client.IndexDocument(ctx, "products", testProduct{}, opts...)
+		}},
+		{name: "search", call: func(ctx context.Context, opts ...RequestOption) error {
+			_, err := client.Search(ctx, query, opts...)
+			return err
+		}},
+		{name: "multi search", call: func(ctx context.Context, opts ...RequestOption) error {
+			mquery := CreateMQuery()
+			mquery.AddQuery(query)
+			_, err := client.SearchDocumentsWithMQuery(ctx, "products", mquery, opts...)
+			return err
+		}},
+		{name: "delete index", call: func(ctx context.Context, opts ...RequestOption) error {
+			return client.DeleteIndex(ctx, "products", opts...)
+		}},
+		{name: "get", call: func(ctx context.Context, opts ...RequestOption) error {
+			_, err := client.GetDocument(ctx, "products", "1", opts...)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" with timeout", func(t *testing.T) {
+			start := time.Now()
+			err := tt.call(context.Background(), WithTimeout(20*time.Millisecond))
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Less(t, time.Since(start), 5*time.Second)
+		})
+		t.Run(tt.name+" with cancelled context", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			assert.ErrorIs(t, tt.call(ctx), context.Canceled)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // ESQuery represents a single Elasticsearch query
@@ -22,19 +23,73 @@ func CreateESQuery(index string, query map[string]interface{}) *ESQuery {
 	}
 }
 
+// CreateESQueryStr creates a query from a JSON string, returning nil if the index is empty or the JSON is invalid.
+// Use ParseESQuery to find out why a query was rejected.
 func CreateESQueryStr(index string, queryStr string) *ESQuery {
-	if index == "" {
+	query, err := ParseESQuery(index, queryStr)
+	if err != nil {
 		return nil
 	}
+	return query
+}
+
+// ParseESQuery creates a query from a JSON string
+func ParseESQuery(index string, queryStr string) (*ESQuery, error) {
+	if index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
 
 	query := make(map[string]interface{})
 	if err := json.Unmarshal([]byte(queryStr), &query); err != nil {
-		return nil
+		return nil, fmt.Errorf("invalid query JSON: %w", err)
 	}
 	return &ESQuery{
 		index: index,
 		query: query,
+	}, nil
+}
+
+// AddAggregation adds a named aggregation to the query, replacing any aggregation with the same name.
+// It is added to the "aggs" or "aggregations" object already in the query, or to a new "aggs" object.
+func (q *ESQuery) AddAggregation(name string, agg Aggregation) error {
+	sources, err := aggregationSources(map[string]Aggregation{name: agg})
+	if err != nil {
+		return err
+	}
+
+	if q.query == nil {
+		q.query = map[string]interface{}{}
+	}
+	_, hasAggs := q.query["aggs"]
+	_, hasAggregations := q.query["aggregations"]
+	if hasAggs && hasAggregations {
+		return fmt.Errorf("query cannot contain both aggs and aggregations")
+	}
+	key := "aggs"
+	if hasAggregations {
+		key = "aggregations"
+	}
+
+	existing, ok := q.query[key]
+	if !ok {
+		existing = map[string]interface{}{}
+		q.query[key] = existing
+	}
+	aggs, ok := existing.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s of the query must be an object, got %T", key, existing)
 	}
+	aggs[name] = sources[name]
+	return nil
+}
+
+// SetTimeout bounds the time the query runs on the shards. A query that times out returns the hits found so far
+// with TimedOut set instead of failing.
+func (q *ESQuery) SetTimeout(timeout time.Duration) {
+	if q.query == nil {
+		q.query = map[string]interface{}{}
+	}
+	q.query["timeout"] = formatDuration(timeout)
 }
 
 func (q *ESQuery) appendBufferForMQuery(index string, buffer *bytes.Buffer) error {
@@ -72,18 +127,93 @@ func (q *ESQuery) appendBufferForMQuery(index string, buffer *bytes.Buffer) erro
 // MultiESQuery represents a multi-query in Elasticsearch
 type MultiESQuery struct {
 	queries []*ESQuery
+	weights []float64
+	names   []string
 }
 
 func CreateMQuery() *MultiESQuery {
 	return &MultiESQuery{
 		queries: []*ESQuery{},
+		weights: []float64{},
+		names:   []string{},
 	}
 }
 
 func (m *MultiESQuery) AddQuery(query *ESQuery) {
+	m.AddWeightedQuery(query, 1)
+}
+
+// AddWeightedQuery adds a query whose results count weight times as much as the others when fused with SearchFused
+func (m *MultiESQuery) AddWeightedQuery(query *ESQuery, weight float64) {
 	if query != nil {
-		m.queries = append(m.queries, query)
+		// cannot fail for an unnamed query
+		_ = m.AddNamedWeightedQuery("", query, weight)
+	}
+}
+
+// AddNamedQuery adds a query whose result can be looked up by name in the MultiSearchResult
+func (m *MultiESQuery) AddNamedQuery(name string, query *ESQuery) error {
+	if name == "" {
+		return fmt.Errorf("query name cannot be empty")
+	}
+	return m.AddNamedWeightedQuery(name, query, 1)
+}
+
+// AddNamedWeightedQuery adds a query that is both looked up by name in the MultiSearchResult and weighted
+// when fused with SearchFused. The query is unnamed if name is empty.
+func (m *MultiESQuery) AddNamedWeightedQuery(name string, query *ESQuery, weight float64) error {
+	if query == nil {
+		return fmt.Errorf("query %s is nil", name)
+	}
+	if name != "" {
+		if err := checkQueryName(m.names, name); err != nil {
+			return err
+		}
+	}
+	m.add(query, weight, name)
+	return nil
+}
+
+// checkQueryName checks that a query name is set and not used by another query of a multi-search
+func checkQueryName(names []string, name string) error {
+	if name == "" {
+		return fmt.Errorf("query name cannot be empty")
+	}
+	for _, existing := range names {
+		if existing == name {
+			return fmt.Errorf("query %s was already added", name)
+		}
+	}
+	return nil
+}
+
+func (m *MultiESQuery) add(query *ESQuery, weight float64, name string) {
+	m.queries = append(m.queries, query)
+	m.weights = append(m.weights, weight)
+	m.names = append(m.names, name)
+}
+
+// withQueryTimeout returns a copy of the multi-query where the queries without a timeout of their own get timeout
+func (m *MultiESQuery) withQueryTimeout(timeout time.Duration) *MultiESQuery {
+	copied := &MultiESQuery{
+		queries: make([]*ESQuery, len(m.queries)),
+		weights: m.weights,
+		names:   m.names,
+	}
+	for i, query := range m.queries {
+		copied.queries[i] = query
+		if _, ok := query.query["timeout"]; ok {
+			continue
+		}
+
+		body := make(map[string]interface{}, len(query.query)+1)
+		for key, value := range query.query {
+			body[key] = value
+		}
+		body["timeout"] = formatDuration(timeout)
+		copied.queries[i] = &ESQuery{index: query.index, query: body}
 	}
+	return copied
 }
 
 func (m *MultiESQuery) createMQueryBuffer(index string) (*bytes.Buffer, error) {
@@ -105,6 +235,7 @@ func (m *MultiESQuery) PrintQuery(index string) {
 	buffer, err := m.createMQueryBuffer(index)
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 	fmt.Println(buffer.String())
 }
@@ -0,0 +1,672 @@
+package elasticsearch
+
+import (
+	"fmt"
+)
+
+// Query is a clause of the Elasticsearch query DSL
+type Query interface {
+	// Source returns the JSON body of the clause, or an error if the clause is invalid
+	Source() (map[string]interface{}, error)
+}
+
+// querySources returns the bodies of a list of clauses
+func querySources(queries []Query) ([]interface{}, error) {
+	sources := make([]interface{}, 0, len(queries))
+	for _, q := range queries {
+		if q == nil {
+			return nil, fmt.Errorf("query clause cannot be nil")
+		}
+		source, err := q.Source()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// RawQuery is a clause given as a map, for DSL features the builder does not cover
+type RawQuery map[string]interface{}
+
+func (q RawQuery) Source() (map[string]interface{}, error) {
+	if len(q) == 0 {
+		return nil, fmt.Errorf("raw query cannot be empty")
+	}
+	return q, nil
+}
+
+// MatchAllQuery matches every document
+type MatchAllQuery struct{}
+
+func NewMatchAllQuery() *MatchAllQuery {
+	return &MatchAllQuery{}
+}
+
+func (q *MatchAllQuery) Source() (map[string]interface{}, error) {
+	return map[string]interface{}{"match_all": map[string]interface{}{}}, nil
+}
+
+// BoolQuery combines clauses with must, should, filter and must_not
+type BoolQuery struct {
+	must               []Query
+	should             []Query
+	filter             []Query
+	mustNot            []Query
+	minimumShouldMatch string
+	boost              *float64
+}
+
+func NewBoolQuery() *BoolQuery {
+	return &BoolQuery{}
+}
+
+func (q *BoolQuery) Must(queries ...Query) *BoolQuery {
+	q.must = append(q.must, queries...)
+	return q
+}
+
+func (q *BoolQuery) Should(queries ...Query) *BoolQuery {
+	q.should = append(q.should, queries...)
+	return q
+}
+
+func (q *BoolQuery) Filter(queries ...Query) *BoolQuery {
+	q.filter = append(q.filter, queries...)
+	return q
+}
+
+func (q *BoolQuery) MustNot(queries ...Query) *BoolQuery {
+	q.mustNot = append(q.mustNot, queries...)
+	return q
+}
+
+// MinimumShouldMatch sets how many should clauses must match, e.g. "1" or "75%"
+func (q *BoolQuery) MinimumShouldMatch(minimum string) *BoolQuery {
+	q.minimumShouldMatch = minimum
+	return q
+}
+
+func (q *BoolQuery) Boost(boost float64) *BoolQuery {
+	q.boost = &boost
+	return q
+}
+
+func (q *BoolQuery) Source() (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	clauses := []struct {
+		name    string
+		queries []Query
+	}{
+		{"must", q.must},
+		{"should", q.should},
+		{"filter", q.filter},
+		{"must_not", q.mustNot},
+	}
+	for _, clause := range clauses {
+		if len(clause.queries) == 0 {
+			continue
+		}
+		sources, err := querySources(clause.queries)
+		if err != nil {
+			return nil, fmt.Errorf("bool %s: %w", clause.name, err)
+		}
+		body[clause.name] = sources
+	}
+
+	if q.minimumShouldMatch != "" {
+		if len(q.should) == 0 {
+			return nil, fmt.Errorf("bool minimum_should_match requires should clauses")
+		}
+		body["minimum_should_match"] = q.minimumShouldMatch
+	}
+	if q.boost != nil {
+		body["boost"] = *q.boost
+	}
+
+	return map[string]interface{}{"bool": body}, nil
+}
+
+// MatchQuery is a full text query on a single field
+type MatchQuery struct {
+	field     string
+	text      interface{}
+	operator  string
+	fuzziness string
+	analyzer  string
+	boost     *float64
+}
+
+func NewMatchQuery(field string, text interface{}) *MatchQuery {
+	return &MatchQuery{field: field, text: text}
+}
+
+// Operator sets whether all terms ("and") or any term ("or") must match
+func (q *MatchQuery) Operator(operator string) *MatchQuery {
+	q.operator = operator
+	return q
+}
+
+// Fuzziness sets the allowed edit distance, e.g. "AUTO"
+func (q *MatchQuery) Fuzziness(fuzziness string) *MatchQuery {
+	q.fuzziness = fuzziness
+	return q
+}
+
+func (q *MatchQuery) Analyzer(analyzer string) *MatchQuery {
+	q.analyzer = analyzer
+	return q
+}
+
+func (q *MatchQuery) Boost(boost float64) *MatchQuery {
+	q.boost = &boost
+	return q
+}
+
+func (q *MatchQuery) Source() (map[string]interface{}, error) {
+	if q.field == "" {
+		return nil, fmt.Errorf("match query requires a field")
+	}
+	if q.text == nil || q.text == "" {
+		return nil, fmt.Errorf("match query on %s requires a query text", q.field)
+	}
+	if err := validateOperator(q.operator); err != nil {
+		return nil, fmt.Errorf("match query on %s: %w", q.field, err)
+	}
+
+	body := map[string]interface{}{"query": q.text}
+	setIfNotEmpty(body, "operator", q.operator)
+	setIfNotEmpty(body, "fuzziness", q.fuzziness)
+	setIfNotEmpty(body, "analyzer", q.analyzer)
+	if q.boost != nil {
+		body["boost"] = *q.boost
+	}
+
+	return map[string]interface{}{"match": map[string]interface{}{q.field: body}}, nil
+}
+
+// MultiMatchQuery is a full text query over several fields
+type MultiMatchQuery struct {
+	text      string
+	fields    []string
+	matchType string
+	operator  string
+	fuzziness string
+	boost     *float64
+}
+
+// NewMultiMatchQuery creates a multi_match query. Fields may carry a boost, e.g. "name^3".
+func NewMultiMatchQuery(text string, fields ...string) *MultiMatchQuery {
+	return &MultiMatchQuery{text: text, fields: fields}
+}
+
+// Type sets the multi_match type, e.g. best_fields, most_fields, cross_fields, phrase or bool_prefix
+func (q *MultiMatchQuery) Type(matchType string) *MultiMatchQuery {
+	q.matchType = matchType
+	return q
+}
+
+func (q *MultiMatchQuery) Operator(operator string) *MultiMatchQuery {
+	q.operator = operator
+	return q
+}
+
+func (q *MultiMatchQuery) Fuzziness(fuzziness string) *MultiMatchQuery {
+	q.fuzziness = fuzziness
+	return q
+}
+
+func (q *MultiMatchQuery) Boost(boost float64) *MultiMatchQuery {
+	q.boost = &boost
+	return q
+}
+
+func (q *MultiMatchQuery) Source() (map[string]interface{}, error) {
+	if q.text == "" {
+		return nil, fmt.Errorf("multi_match query requires a query text")
+	}
+	if len(q.fields) == 0 {
+		return nil, fmt.Errorf("multi_match query requires at least one field")
+	}
+	for _, field := range q.fields {
+		if field == "" {
+			return nil, fmt.Errorf("multi_match query fields cannot be empty")
+		}
+	}
+	switch q.matchType {
+	case "", "best_fields", "most_fields", "cross_fields", "phrase", "phrase_prefix", "bool_prefix":
+	default:
+		return nil, fmt.Errorf("unknown multi_match type %q", q.matchType)
+	}
+	if err := validateOperator(q.operator); err != nil {
+		return nil, fmt.Errorf("multi_match query: %w", err)
+	}
+
+	body := map[string]interface{}{
+		"query":  q.text,
+		"fields": q.fields,
+	}
+	setIfNotEmpty(body, "type", q.matchType)
+	setIfNotEmpty(body, "operator", q.operator)
+	setIfNotEmpty(body, "fuzziness", q.fuzziness)
+	if q.boost != nil {
+		body["boost"] = *q.boost
+	}
+
+	return map[string]interface{}{"multi_match": body}, nil
+}
+
+// TermQuery matches documents whose field holds exactly the value
+type TermQuery struct {
+	field string
+	value interface{}
+	boost *float64
+}
+
+func NewTermQuery(field string, value interface{}) *TermQuery {
+	return &TermQuery{field: field, value: value}
+}
+
+func (q *TermQuery) Boost(boost float64) *TermQuery {
+	q.boost = &boost
+	return q
+}
+
+func (q *TermQuery) Source() (map[string]interface{}, error) {
+	if q.field == "" {
+		return nil, fmt.Errorf("term query requires a field")
+	}
+	if q.value == nil {
+		return nil, fmt.Errorf("term query on %s requires a value", q.field)
+	}
+
+	body := map[string]interface{}{"value": q.value}
+	if q.boost != nil {
+		body["boost"] = *q.boost
+	}
+
+	return map[string]interface{}{"term": map[string]interface{}{q.field: body}}, nil
+}
+
+// TermsQuery matches documents whose field holds any of the values
+type TermsQuery struct {
+	field  string
+	values []interface{}
+}
+
+func NewTermsQuery(field string, values ...interface{}) *TermsQuery {
+	return &TermsQuery{field: field, values: values}
+}
+
+func (q *TermsQuery) Source() (map[string]interface{}, error) {
+	if q.field == "" {
+		return nil, fmt.Errorf("terms query requires a field")
+	}
+	if len(q.values) == 0 {
+		return nil, fmt.Errorf("terms query on %s requires at least one value", q.field)
+	}
+
+	return map[string]interface{}{"terms": map[string]interface{}{q.field: q.values}}, nil
+}
+
+// RangeQuery matches documents whose field lies within bounds
+type RangeQuery struct {
+	field  string
+	bounds map[string]interface{}
+	format string
+}
+
+func NewRangeQuery(field string) *RangeQuery {
+	return &RangeQuery{field: field, bounds: map[string]interface{}{}}
+}
+
+func (q *RangeQuery) Gt(value interface{}) *RangeQuery {
+	q.bounds["gt"] = value
+	return q
+}
+
+func (q *RangeQuery) Gte(value interface{}) *RangeQuery {
+	q.bounds["gte"] = value
+	return q
+}
+
+func (q *RangeQuery) Lt(value interface{}) *RangeQuery {
+	q.bounds["lt"] = value
+	return q
+}
+
+func (q *RangeQuery) Lte(value interface{}) *RangeQuery {
+	q.bounds["lte"] = value
+	return q
+}
+
+// Format sets the date format of the bounds
+func (q *RangeQuery) Format(format string) *RangeQuery {
+	q.format = format
+	return q
+}
+
+func (q *RangeQuery) Source() (map[string]interface{}, error) {
+	if q.field == "" {
+		return nil, fmt.Errorf("range query requires a field")
+	}
+	if len(q.bounds) == 0 {
+		return nil, fmt.Errorf("range query on %s requires at least one bound", q.field)
+	}
+
+	body := make(map[string]interface{}, len(q.bounds)+1)
+	for k, v := range q.bounds {
+		body[k] = v
+	}
+	setIfNotEmpty(body, "format", q.format)
+
+	return map[string]interface{}{"range": map[string]interface{}{q.field: body}}, nil
+}
+
+// ExistsQuery matches documents that have a value for the field
+type ExistsQuery struct {
+	field string
+}
+
+func NewExistsQuery(field string) *ExistsQuery {
+	return &ExistsQuery{field: field}
+}
+
+func (q *ExistsQuery) Source() (map[string]interface{}, error) {
+	if q.field == "" {
+		return nil, fmt.Errorf("exists query requires a field")
+	}
+	return map[string]interface{}{"exists": map[string]interface{}{"field": q.field}}, nil
+}
+
+// NestedQuery runs a query against nested objects
+type NestedQuery struct {
+	path      string
+	query     Query
+	scoreMode string
+	innerHits map[string]interface{}
+}
+
+func NewNestedQuery(path string, query Query) *NestedQuery {
+	return &NestedQuery{path: path, query: query}
+}
+
+// ScoreMode sets how nested scores are combined: avg, max, min, sum or none
+func (q *NestedQuery) ScoreMode(scoreMode string) *NestedQuery {
+	q.scoreMode = scoreMode
+	return q
+}
+
+// InnerHits returns the matching nested objects with each hit, e.g. InnerHits(map[string]interface{}{"size": 3})
+func (q *NestedQuery) InnerHits(innerHits map[string]interface{}) *NestedQuery {
+	if innerHits == nil {
+		innerHits = map[string]interface{}{}
+	}
+	q.innerHits = innerHits
+	return q
+}
+
+func (q *NestedQuery) Source() (map[string]interface{}, error) {
+	if q.path == "" {
+		return nil, fmt.Errorf("nested query requires a path")
+	}
+	if q.query == nil {
+		return nil, fmt.Errorf("nested query on %s requires a query", q.path)
+	}
+	switch q.scoreMode {
+	case "", "avg", "max", "min", "sum", "none":
+	default:
+		return nil, fmt.Errorf("unknown nested score_mode %q", q.scoreMode)
+	}
+
+	inner, err := q.query.Source()
+	if err != nil {
+		return nil, fmt.Errorf("nested query on %s: %w", q.path, err)
+	}
+
+	body := map[string]interface{}{
+		"path":  q.path,
+		"query": inner,
+	}
+	setIfNotEmpty(body, "score_mode", q.scoreMode)
+	if q.innerHits != nil {
+		body["inner_hits"] = q.innerHits
+	}
+
+	return map[string]interface{}{"nested": body}, nil
+}
+
+// ScoreFunction is a function of a function_score query
+type ScoreFunction interface {
+	Source() (map[string]interface{}, error)
+}
+
+// FieldValueFactorFunction scores documents by a numeric field, e.g. popularity
+type FieldValueFactorFunction struct {
+	field    string
+	factor   *float64
+	modifier string
+	missing  *float64
+}
+
+func NewFieldValueFactorFunction(field string) *FieldValueFactorFunction {
+	return &FieldValueFactorFunction{field: field}
+}
+
+func (f *FieldValueFactorFunction) Factor(factor float64) *FieldValueFactorFunction {
+	f.factor = &factor
+	return f
+}
+
+// Modifier sets the function applied to the field value, e.g. log1p or sqrt
+func (f *FieldValueFactorFunction) Modifier(modifier string) *FieldValueFactorFunction {
+	f.modifier = modifier
+	return f
+}
+
+func (f *FieldValueFactorFunction) Missing(missing float64) *FieldValueFactorFunction {
+	f.missing = &missing
+	return f
+}
+
+func (f *FieldValueFactorFunction) Source() (map[string]interface{}, error) {
+	if f.field == "" {
+		return nil, fmt.Errorf("field_value_factor requires a field")
+	}
+
+	body := map[string]interface{}{"field": f.field}
+	if f.factor != nil {
+		body["factor"] = *f.factor
+	}
+	setIfNotEmpty(body, "modifier", f.modifier)
+	if f.missing != nil {
+		body["missing"] = *f.missing
+	}
+
+	return map[string]interface{}{"field_value_factor": body}, nil
+}
+
+// WeightFunction multiplies the score by a constant
+type WeightFunction struct {
+	weight float64
+}
+
+func NewWeightFunction(weight float64) *WeightFunction {
+	return &WeightFunction{weight: weight}
+}
+
+func (f *WeightFunction) Source() (map[string]interface{}, error) {
+	return map[string]interface{}{"weight": f.weight}, nil
+}
+
+// DecayFunction scores documents by the distance of a field from an origin
+type DecayFunction struct {
+	kind   string
+	field  string
+	origin interface{}
+	scale  interface{}
+	offset interface{}
+	decay  *float64
+}
+
+// NewDecayFunction creates a gauss, exp or linear decay function
+func NewDecayFunction(kind string, field string, origin interface{}, scale interface{}) *DecayFunction {
+	return &DecayFunction{kind: kind, field: field, origin: origin, scale: scale}
+}
+
+func (f *DecayFunction) Offset(offset interface{}) *DecayFunction {
+	f.offset = offset
+	return f
+}
+
+func (f *DecayFunction) Decay(decay float64) *DecayFunction {
+	f.decay = &decay
+	return f
+}
+
+func (f *DecayFunction) Source() (map[string]interface{}, error) {
+	switch f.kind {
+	case "gauss", "exp", "linear":
+	default:
+		return nil, fmt.Errorf("unknown decay function %q", f.kind)
+	}
+	if f.field == "" {
+		return nil, fmt.Errorf("%s decay function requires a field", f.kind)
+	}
+	if f.scale == nil {
+		return nil, fmt.Errorf("%s decay function on %s requires a scale", f.kind, f.field)
+	}
+
+	body := map[string]interface{}{"scale": f.scale}
+	if f.origin != nil {
+		body["origin"] = f.origin
+	}
+	if f.offset != nil {
+		body["offset"] = f.offset
+	}
+	if f.decay != nil {
+		body["decay"] = *f.decay
+	}
+
+	return map[string]interface{}{f.kind: map[string]interface{}{f.field: body}}, nil
+}
+
+// FunctionScoreQuery modifies the score of the documents matched by a query
+type FunctionScoreQuery struct {
+	query     Query
+	functions []functionScoreEntry
+	scoreMode string
+	boostMode string
+	maxBoost  *float64
+}
+
+type functionScoreEntry struct {
+	filter   Query
+	function ScoreFunction
+	weight   *float64
+}
+
+// NewFunctionScoreQuery creates a function_score query, query may be nil to score all documents
+func NewFunctionScoreQuery(query Query) *FunctionScoreQuery {
+	return &FunctionScoreQuery{query: query}
+}
+
+// Add applies a function to every matched document
+func (q *FunctionScoreQuery) Add(function ScoreFunction) *FunctionScoreQuery {
+	q.functions = append(q.functions, functionScoreEntry{function: function})
+	return q
+}
+
+// AddWithFilter applies a function, with an optional weight, to the matched documents that also match filter
+func (q *FunctionScoreQuery) AddWithFilter(filter Query, function ScoreFunction, weight *float64) *FunctionScoreQuery {
+	q.functions = append(q.functions, functionScoreEntry{filter: filter, function: function, weight: weight})
+	return q
+}
+
+// ScoreMode sets how function scores are combined: multiply, sum, avg, first, max or min
+func (q *FunctionScoreQuery) ScoreMode(scoreMode string) *FunctionScoreQuery {
+	q.scoreMode = scoreMode
+	return q
+}
+
+// BoostMode sets how the function score is combined with the query score: multiply, replace, sum, avg, max or min
+func (q *FunctionScoreQuery) BoostMode(boostMode string) *FunctionScoreQuery {
+	q.boostMode = boostMode
+	return q
+}
+
+func (q *FunctionScoreQuery) MaxBoost(maxBoost float64) *FunctionScoreQuery {
+	q.maxBoost = &maxBoost
+	return q
+}
+
+func (q *FunctionScoreQuery) Source() (map[string]interface{}, error) {
+	if len(q.functions) == 0 {
+		return nil, fmt.Errorf("function_score query requires at least one function")
+	}
+	switch q.scoreMode {
+	case "", "multiply", "sum", "avg", "first", "max", "min":
+	default:
+		return nil, fmt.Errorf("unknown function_score score_mode %q", q.scoreMode)
+	}
+	switch q.boostMode {
+	case "", "multiply", "replace", "sum", "avg", "max", "min":
+	default:
+		return nil, fmt.Errorf("unknown function_score boost_mode %q", q.boostMode)
+	}
+
+	body := map[string]interface{}{}
+	if q.query != nil {
+		inner, err := q.query.Source()
+		if err != nil {
+			return nil, fmt.Errorf("function_score query: %w", err)
+		}
+		body["query"] = inner
+	}
+
+	functions := make([]interface{}, 0, len(q.functions))
+	for _, entry := range q.functions {
+		if entry.function == nil {
+			return nil, fmt.Errorf("function_score function cannot be nil")
+		}
+		function, err := entry.function.Source()
+		if err != nil {
+			return nil, fmt.Errorf("function_score function: %w", err)
+		}
+		if entry.filter != nil {
+			filter, err := entry.filter.Source()
+			if err != nil {
+				return nil, fmt.Errorf("function_score filter: %w", err)
+			}
+			function["filter"] = filter
+		}
+		if entry.weight != nil {
+			function["weight"] = *entry.weight
+		}
+		functions = append(functions, function)
+	}
+	body["functions"] = functions
+
+	setIfNotEmpty(body, "score_mode", q.scoreMode)
+	setIfNotEmpty(body, "boost_mode", q.boostMode)
+	if q.maxBoost != nil {
+		body["max_boost"] = *q.maxBoost
+	}
+
+	return map[string]interface{}{"function_score": body}, nil
+}
+
+func validateOperator(operator string) error {
+	switch operator {
+	case "", "and", "or", "AND", "OR":
+		return nil
+	default:
+		return fmt.Errorf("unknown operator %q", operator)
+	}
+}
+
+func setIfNotEmpty(body map[string]interface{}, key string, value string) {
+	if value != "" {
+		body[key] = value
+	}
+}
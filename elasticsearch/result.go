@@ -0,0 +1,247 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kdjuwidja/aishoppercommon/logger"
+)
+
+// maxErrorBodyLength bounds how much of an undecodable response body is quoted in errors
+const maxErrorBodyLength = 256
+
+// SearchResult is a decoded search response
+type SearchResult struct {
+	Took         int64
+	TimedOut     bool
+	Shards       ShardStats
+	Total        TotalHits
+	MaxScore     *float64
+	Hits         []Hit
+	Aggregations Aggregations
+	Suggest      Suggestions
+	Profile      *Profile
+}
+
+// ShardStats reports how many shards took part in a search and why some of them failed
+type ShardStats struct {
+	Total      int            `json:"total"`
+	Successful int            `json:"successful"`
+	Skipped    int            `json:"skipped"`
+	Failed     int            `json:"failed"`
+	Failures   []ShardFailure `json:"failures,omitempty"`
+}
+
+// ShardFailure describes a shard that failed to execute a search
+type ShardFailure struct {
+	Shard  int        `json:"shard"`
+	Index  string     `json:"index"`
+	Node   string     `json:"node"`
+	Reason ErrorCause `json:"reason"`
+}
+
+// ErrorCause is an error reported by Elasticsearch
+type ErrorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (e ErrorCause) String() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Reason)
+}
+
+// TotalHits is the number of documents matching a query.
+// Relation is "eq" when Value is exact and "gte" when it is a lower bound.
+type TotalHits struct {
+	Value    int64  `json:"value"`
+	Relation string `json:"relation"`
+}
+
+// Hit is a single document returned by a search
+type Hit struct {
+	Index     string                   `json:"_index"`
+	ID        string                   `json:"_id"`
+	Score     *float64                 `json:"_score"`
+	Source    json.RawMessage          `json:"_source"`
+	Highlight map[string][]string      `json:"highlight,omitempty"`
+	Sort      []interface{}            `json:"sort,omitempty"`
+	InnerHits map[string]*SearchResult `json:"inner_hits,omitempty"`
+	// Explanation is only set when the search was run with SetExplain or SearchBuilder.Explain
+	Explanation *Explanation `json:"_explanation,omitempty"`
+}
+
+// UnmarshalJSON decodes a search response, or the hits of an inner_hits section
+func (r *SearchResult) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Took     int64      `json:"took"`
+		TimedOut bool       `json:"timed_out"`
+		Shards   ShardStats `json:"_shards"`
+		Hits     *struct {
+			Total    *TotalHits `json:"total"`
+			MaxScore *float64   `json:"max_score"`
+			Hits     []Hit      `json:"hits"`
+		} `json:"hits"`
+		Aggregations Aggregations `json:"aggregations"`
+		Suggest      Suggestions  `json:"suggest"`
+		Profile      *Profile     `json:"profile"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Hits == nil {
+		return fmt.Errorf("response has no hits section")
+	}
+
+	*r = SearchResult{
+		Took:         raw.Took,
+		TimedOut:     raw.TimedOut,
+		Shards:       raw.Shards,
+		MaxScore:     raw.Hits.MaxScore,
+		Hits:         raw.Hits.Hits,
+		Aggregations: raw.Aggregations,
+		Suggest:      raw.Suggest,
+		Profile:      raw.Profile,
+	}
+	if raw.Hits.Total != nil {
+		r.Total = *raw.Hits.Total
+	}
+	if r.Hits == nil {
+		r.Hits = []Hit{}
+	}
+
+	return nil
+}
+
+// Partial reports whether the result may be missing hits because the search timed out or shards failed
+func (r *SearchResult) Partial() bool {
+	return r.TimedOut || r.Shards.Failed > 0
+}
+
+// Warnings describes why a partial result is incomplete
+func (r *SearchResult) Warnings() []string {
+	var warnings []string
+	if r.TimedOut {
+		warnings = append(warnings, "search timed out")
+	}
+	for _, failure := range r.Shards.Failures {
+		warnings = append(warnings, fmt.Sprintf("shard %d of index %s failed: %s", failure.Shard, failure.Index, failure.Reason))
+	}
+	if r.Shards.Failed > len(r.Shards.Failures) {
+		warnings = append(warnings, fmt.Sprintf("%d of %d shards failed", r.Shards.Failed, r.Shards.Total))
+	}
+	return warnings
+}
+
+// Sources returns the _source of every hit
+func (r *SearchResult) Sources() []json.RawMessage {
+	sources := make([]json.RawMessage, len(r.Hits))
+	for i, hit := range r.Hits {
+		sources[i] = hit.Source
+	}
+	return sources
+}
+
+// TypedSearchResult is a search result whose sources are decoded into T.
+// Documents[i] is the source of Hits[i], or the zero value of T if the hit has no source,
+// e.g. when the query disables _source or only asks for fields.
+type TypedSearchResult[T any] struct {
+	SearchResult
+	Documents []T
+}
+
+// decodeSources decodes the source of every hit of a result into T
+func decodeSources[T any](result *SearchResult) (*TypedSearchResult[T], error) {
+	typed := &TypedSearchResult[T]{
+		SearchResult: *result,
+		Documents:    make([]T, len(result.Hits)),
+	}
+	for i, hit := range result.Hits {
+		if len(hit.Source) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(hit.Source, &typed.Documents[i]); err != nil {
+			return nil, fmt.Errorf("error decoding source of document %s: %w", hit.ID, err)
+		}
+	}
+	return typed, nil
+}
+
+// decodeSearchResult decodes a search response body. A result where every shard failed is an error,
+// other partial results are logged as warnings and returned.
+func decodeSearchResult(body io.Reader) (*SearchResult, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	return parseSearchResult(data)
+}
+
+func parseSearchResult(data []byte) (*SearchResult, error) {
+	var result SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w (body: %s)", err, truncateBody(data))
+	}
+
+	if result.Shards.Total > 0 && result.Shards.Failed >= result.Shards.Total {
+		return nil, fmt.Errorf("all %d shards failed: %s", result.Shards.Total, strings.Join(result.Warnings(), "; "))
+	}
+	for _, warning := range result.Warnings() {
+		logger.Warnf("partial search result: %s", warning)
+	}
+
+	return &result, nil
+}
+
+// decodeMultiSearchResponses decodes a multi-search response into the result or error of every query
+func decodeMultiSearchResponses(body io.Reader, queries int) ([]MultiSearchResponse, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading multi-search response: %w", err)
+	}
+
+	var raw struct {
+		Responses []json.RawMessage `json:"responses"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing multi-search response: %w (body: %s)", err, truncateBody(data))
+	}
+	if len(raw.Responses) != queries {
+		return nil, fmt.Errorf("multi-search returned %d responses for %d queries", len(raw.Responses), queries)
+	}
+
+	responses := make([]MultiSearchResponse, len(raw.Responses))
+	for i, response := range raw.Responses {
+		var failure struct {
+			Error  *ErrorCause `json:"error"`
+			Status int         `json:"status"`
+		}
+		if err := json.Unmarshal(response, &failure); err != nil {
+			responses[i].Err = fmt.Errorf("error parsing search response %d: %w", i, err)
+			continue
+		}
+		if failure.Error != nil {
+			responses[i].Err = &SearchError{Query: i, Status: failure.Status, Cause: *failure.Error}
+			continue
+		}
+
+		result, err := parseSearchResult(response)
+		if err != nil {
+			responses[i].Err = fmt.Errorf("error in search response %d: %w", i, err)
+			continue
+		}
+		responses[i].Result = result
+	}
+
+	return responses, nil
+}
+
+// truncateBody shortens a response body for inclusion in an error message
+func truncateBody(data []byte) string {
+	if len(data) > maxErrorBodyLength {
+		return string(data[:maxErrorBodyLength]) + "..."
+	}
+	return string(data)
+}
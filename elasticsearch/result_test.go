@@ -0,0 +1,220 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSearchResponse = `{
+	"took": 12,
+	"timed_out": false,
+	"hits": {
+		"total": {"value": 1200, "relation": "gte"},
+		"max_score": 3.5,
+		"hits": [
+			{
+				"_index": "products",
+				"_id": "sku-1",
+				"_score": 3.5,
+				"_source": {"name": "Trail Shoe", "price": 120},
+				"highlight": {"name": ["<em>Trail</em> Shoe"]},
+				"sort": [3.5, "sku-1"],
+				"inner_hits": {
+					"variants": {
+						"hits": {
+							"total": {"value": 1, "relation": "eq"},
+							"max_score": 1.0,
+							"hits": [{"_index": "products", "_id": "sku-1", "_score": 1.0, "_source": {"size": "42"}}]
+						}
+					}
+				}
+			},
+			{
+				"_index": "products",
+				"_id": "sku-2",
+				"_score": null,
+				"_source": {"name": "Road Shoe", "price": 90}
+			}
+		]
+	},
+	"aggregations": {"brands": {"buckets": []}}
+}`
+
+type testProduct struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+func TestSearchResult_UnmarshalJSON(t *testing.T) {
+	var result SearchResult
+	require.NoError(t, json.Unmarshal([]byte(testSearchResponse), &result))
+
+	assert.Equal(t, int64(12), result.Took)
+	assert.False(t, result.TimedOut)
+	assert.Equal(t, TotalHits{Value: 1200, Relation: "gte"}, result.Total)
+	require.NotNil(t, result.MaxScore)
+	assert.Equal(t, 3.5, *result.MaxScore)
+	require.Len(t, result.Hits, 2)
+
+	hit := result.Hits[0]
+	assert.Equal(t, "products", hit.Index)
+	assert.Equal(t, "sku-1", hit.ID)
+	require.NotNil(t, hit.Score)
+	assert.Equal(t, 3.5, *hit.Score)
+	assert.JSONEq(t, `{"name": "Trail Shoe", "price": 120}`, string(hit.Source))
+	assert.Equal(t, []string{"<em>Trail</em> Shoe"}, hit.Highlight["name"])
+	assert.Equal(t, []interface{}{3.5, "sku-1"}, hit.Sort)
+
+	require.Contains(t, hit.InnerHits, "variants")
+	variants := hit.InnerHits["variants"]
+	assert.Equal(t, TotalHits{Value: 1, Relation: "eq"}, variants.Total)
+	require.Len(t, variants.Hits, 1)
+	assert.JSONEq(t, `{"size": "42"}`, string(variants.Hits[0].Source))
+
+	assert.Nil(t, result.Hits[1].Score)
+	assert.Contains(t, result.Aggregations, "brands")
+}
+
+func TestSearchResult_UnmarshalJSON_NoHits(t *testing.T) {
+	var result SearchResult
+	require.NoError(t, json.Unmarshal([]byte(`{"took": 1, "hits": {"hits": []}}`), &result))
+	assert.NotNil(t, result.Hits)
+	assert.Empty(t, result.Hits)
+	assert.Equal(t, TotalHits{}, result.Total)
+}
+
+func TestSearchResult_Sources(t *testing.T) {
+	var result SearchResult
+	require.NoError(t, json.Unmarshal([]byte(testSearchResponse), &result))
+
+	sources := result.Sources()
+	require.Len(t, sources, 2)
+	assert.JSONEq(t, `{"name": "Road Shoe", "price": 90}`, string(sources[1]))
+}
+
+func TestDecodeSources(t *testing.T) {
+	var result SearchResult
+	require.NoError(t, json.Unmarshal([]byte(testSearchResponse), &result))
+
+	typed, err := decodeSources[testProduct](&result)
+	require.NoError(t, err)
+	assert.Equal(t, []testProduct{{Name: "Trail Shoe", Price: 120}, {Name: "Road Shoe", Price: 90}}, typed.Documents)
+	assert.Equal(t, "sku-2", typed.Hits[1].ID)
+	assert.Equal(t, int64(1200), typed.Total.Value)
+
+	// hits without a source are left as the zero value
+	result.Hits[0].Source = nil
+	result.Hits[1].Source = json.RawMessage(`null`)
+	typed, err = decodeSources[testProduct](&result)
+	require.NoError(t, err)
+	assert.Equal(t, []testProduct{{}, {}}, typed.Documents)
+
+	result.Hits[0].Source = json.RawMessage(`{"price": "free"}`)
+	_, err = decodeSources[testProduct](&result)
+	assert.Error(t, err)
+}
+
+func TestParseSearchResult(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantErr      string
+		wantPartial  bool
+		wantWarnings int
+	}{
+		{
+			name: "complete result",
+			body: `{"took": 1, "timed_out": false, "_shards": {"total": 2, "successful": 2, "skipped": 0, "failed": 0}, "hits": {"hits": []}}`,
+		},
+		{
+			name:         "timed out",
+			body:         `{"took": 1, "timed_out": true, "_shards": {"total": 2, "successful": 2, "failed": 0}, "hits": {"hits": []}}`,
+			wantPartial:  true,
+			wantWarnings: 1,
+		},
+		{
+			name: "partial shard failure",
+			body: `{"took": 1, "timed_out": false,
+				"_shards": {"total": 2, "successful": 1, "failed": 1,
+					"failures": [{"shard": 1, "index": "products", "node": "n1", "reason": {"type": "query_shard_exception", "reason": "failed to create query"}}]},
+				"hits": {"hits": [{"_id": "1", "_source": {}}]}}`,
+			wantPartial:  true,
+			wantWarnings: 1,
+		},
+		{
+			name: "all shards failed",
+			body: `{"took": 1, "timed_out": false,
+				"_shards": {"total": 1, "successful": 0, "failed": 1,
+					"failures": [{"shard": 0, "index": "products", "reason": {"type": "query_shard_exception", "reason": "failed to create query"}}]},
+				"hits": {"hits": []}}`,
+			wantErr: "query_shard_exception",
+		},
+		{
+			name:    "proxy error page",
+			body:    `<html><body>502 Bad Gateway</body></html>`,
+			wantErr: "502 Bad Gateway",
+		},
+		{
+			name:    "missing hits",
+			body:    `{"took": 1, "timed_out": false}`,
+			wantErr: "no hits section",
+		},
+		{
+			name:    "unexpected hits shape",
+			body:    `{"took": 1, "hits": []}`,
+			wantErr: "error parsing response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseSearchResult([]byte(tt.body))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.Nil(t, result)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPartial, result.Partial())
+			assert.Len(t, result.Warnings(), tt.wantWarnings)
+		})
+	}
+}
+
+func TestDecodeMultiSearchResponses(t *testing.T) {
+	ok := `{"took": 1, "hits": {"hits": [{"_id": "1", "_source": {"name": "a"}}]}}`
+
+	tests := []struct {
+		name    string
+		body    string
+		queries int
+		wantErr string
+	}{
+		{name: "all succeeded", body: `{"responses": [` + ok + `,` + ok + `]}`, queries: 2},
+		{name: "sub-response error", body: `{"responses": [` + ok + `,{"error": {"type": "index_not_found_exception", "reason": "no such index"}, "status": 404}]}`, queries: 2, wantErr: "index_not_found_exception"},
+		{name: "response count mismatch", body: `{"responses": [` + ok + `]}`, queries: 2, wantErr: "1 responses for 2 queries"},
+		{name: "missing responses", body: `{"error": "oops"}`, queries: 1, wantErr: "0 responses"},
+		{name: "not JSON", body: `Service Unavailable`, queries: 1, wantErr: "Service Unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*SearchResult
+			responses, err := decodeMultiSearchResponses(strings.NewReader(tt.body), tt.queries)
+			if err == nil {
+				require.Len(t, responses, tt.queries)
+				results, err = (&MultiSearchResult{Responses: responses}).results()
+			}
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"name": "a"}`, string(results[1].Hits[0].Source))
+		})
+	}
+}
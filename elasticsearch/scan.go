@@ -0,0 +1,274 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"time"
+
+	"github.com/kdjuwidja/aishoppercommon/logger"
+)
+
+const (
+	defaultScanPageSize  = 1000
+	defaultScanKeepAlive = time.Minute
+	closePITTimeout      = 10 * time.Second
+)
+
+// ScanOptions controls how Scan pages through an index, zero values use the defaults
+type ScanOptions struct {
+	// PageSize is the number of hits fetched per request, 1000 by default
+	PageSize int
+	// KeepAlive is how long the point-in-time is kept open between two pages, one minute by default
+	KeepAlive time.Duration
+}
+
+func (o ScanOptions) withDefaults() ScanOptions {
+	if o.PageSize <= 0 {
+		o.PageSize = defaultScanPageSize
+	}
+	if o.KeepAlive <= 0 {
+		o.KeepAlive = defaultScanKeepAlive
+	}
+	return o
+}
+
+// Scan iterates over every hit of a query, however deep. It opens a point-in-time on the index so that
+// the pages see a consistent snapshot, pages with search_after on the query sort plus a _shard_doc tiebreaker,
+// and closes the point-in-time once iteration ends, whether it completed, failed, was stopped or ctx was cancelled.
+// The query's from and size are ignored. An error is yielded at most once and ends the iteration.
+func (es *ElasticsearchClient) Scan(ctx context.Context, query *ESQuery, opts ScanOptions) iter.Seq2[Hit, error] {
+	return func(yield func(Hit, error) bool) {
+		if query == nil || query.query == nil {
+			yield(Hit{}, fmt.Errorf("query is nil"))
+			return
+		}
+		opts = opts.withDefaults()
+
+		pitID, err := es.openPointInTime(ctx, query.index, opts.KeepAlive)
+		if err != nil {
+			yield(Hit{}, err)
+			return
+		}
+		defer func() {
+			es.closePointInTime(pitID)
+		}()
+
+		var searchAfter []interface{}
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(Hit{}, err)
+				return
+			}
+
+			body := scanPageBody(query.query, pitID, opts, searchAfter)
+			result, nextPITID, err := es.searchPage(ctx, body)
+			if err != nil {
+				yield(Hit{}, err)
+				return
+			}
+			if nextPITID != "" {
+				pitID = nextPITID
+			}
+
+			for _, hit := range result.Hits {
+				if !yield(hit, nil) {
+					return
+				}
+			}
+
+			if len(result.Hits) < opts.PageSize {
+				return
+			}
+			searchAfter = result.Hits[len(result.Hits)-1].Sort
+			if len(searchAfter) == 0 {
+				yield(Hit{}, fmt.Errorf("hit %s has no sort values to continue from", result.Hits[len(result.Hits)-1].ID))
+				return
+			}
+		}
+	}
+}
+
+// Scan iterates over every hit of a query like ElasticsearchClient.Scan and decodes the source of each hit into T.
+// Hits without a source are yielded as the zero value of T.
+func Scan[T any](ctx context.Context, es SearchClient, query *ESQuery, opts ScanOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for hit, err := range es.Scan(ctx, query, opts) {
+			var doc T
+			if err != nil {
+				yield(doc, err)
+				return
+			}
+			if len(hit.Source) == 0 {
+				// the hit has no source, e.g. because the query disables it
+				if !yield(doc, nil) {
+					return
+				}
+				continue
+			}
+			if err := json.Unmarshal(hit.Source, &doc); err != nil {
+				yield(doc, fmt.Errorf("error decoding source of hit %s: %w", hit.ID, err))
+				return
+			}
+			if !yield(doc, nil) {
+				return
+			}
+		}
+	}
+}
+
+// scanPageBody returns the body of the next page request. The index is omitted as it is bound to the point-in-time.
+func scanPageBody(query map[string]interface{}, pitID string, opts ScanOptions, searchAfter []interface{}) map[string]interface{} {
+	body := make(map[string]interface{}, len(query)+3)
+	for key, value := range query {
+		body[key] = value
+	}
+	delete(body, "from")
+
+	body["size"] = opts.PageSize
+	body["pit"] = map[string]interface{}{
+		"id":         pitID,
+		"keep_alive": formatDuration(opts.KeepAlive),
+	}
+	body["sort"] = withTiebreaker(body["sort"])
+	if len(searchAfter) > 0 {
+		body["search_after"] = searchAfter
+	}
+
+	return body
+}
+
+// withTiebreaker appends a _shard_doc sort so that every hit has a unique sort position.
+// The sort may be a single sort or a slice of any element type, e.g. []string or []map[string]interface{}.
+func withTiebreaker(sort interface{}) []interface{} {
+	var sorts []interface{}
+	if v := reflect.ValueOf(sort); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			sorts = append(sorts, v.Index(i).Interface())
+		}
+	} else if sort != nil {
+		sorts = append(sorts, sort)
+	}
+
+	for _, s := range sorts {
+		if isShardDocSort(s) {
+			return sorts
+		}
+	}
+
+	return append(sorts, map[string]interface{}{"_shard_doc": "asc"})
+}
+
+// isShardDocSort reports whether a sort is on _shard_doc, either as a field name or as an object keyed by it
+func isShardDocSort(sort interface{}) bool {
+	if field, ok := sort.(string); ok {
+		return field == "_shard_doc"
+	}
+
+	v := reflect.ValueOf(sort)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return false
+	}
+	return v.MapIndex(reflect.ValueOf("_shard_doc").Convert(v.Type().Key())).IsValid()
+}
+
+// formatDuration writes a duration in the time units of the Elasticsearch API
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+func (es *ElasticsearchClient) openPointInTime(ctx context.Context, index string, keepAlive time.Duration) (string, error) {
+	if index == "" {
+		return "", fmt.Errorf("index name cannot be empty")
+	}
+
+	res, err := es.client.OpenPointInTime(
+		[]string{index},
+		formatDuration(keepAlive),
+		es.client.OpenPointInTime.WithContext(ctx),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error opening point in time: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return "", fmt.Errorf("error opening point in time: %s", res.String())
+	}
+
+	var pit struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&pit); err != nil {
+		return "", fmt.Errorf("error parsing point in time response: %w", err)
+	}
+	if pit.ID == "" {
+		return "", fmt.Errorf("point in time response has no id")
+	}
+
+	return pit.ID, nil
+}
+
+// closePointInTime releases the point-in-time. It does not use the scan context, which may already be cancelled.
+func (es *ElasticsearchClient) closePointInTime(pitID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), closePITTimeout)
+	defer cancel()
+
+	body, _ := json.Marshal(map[string]string{"id": pitID})
+	res, err := es.client.ClosePointInTime(
+		es.client.ClosePointInTime.WithContext(ctx),
+		es.client.ClosePointInTime.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		logger.Warnf("error closing point in time: %v", err)
+		return
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		logger.Warnf("error closing point in time: %s", res.String())
+	}
+}
+
+// searchPage runs one page of a scan and returns the hits along with the possibly refreshed point-in-time id
+func (es *ElasticsearchClient) searchPage(ctx context.Context, body map[string]interface{}) (*SearchResult, string, error) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("error marshaling query: %w", err)
+	}
+
+	res, err := es.client.Search(
+		es.client.Search.WithContext(ctx),
+		es.client.Search.WithBody(bytes.NewReader(bodyBytes)),
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("error searching documents: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, "", fmt.Errorf("error searching documents: %s", res.String())
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading response: %w", err)
+	}
+	result, err := parseSearchResult(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var pit struct {
+		PitID string `json:"pit_id"`
+	}
+	if err := json.Unmarshal(data, &pit); err != nil {
+		return nil, "", fmt.Errorf("error parsing response: %w", err)
+	}
+
+	return result, pit.PitID, nil
+}
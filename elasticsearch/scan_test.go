@@ -0,0 +1,78 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanPageBody(t *testing.T) {
+	opts := ScanOptions{PageSize: 500, KeepAlive: 2 * time.Minute}
+
+	tests := []struct {
+		name        string
+		query       map[string]interface{}
+		searchAfter []interface{}
+		wantJSON    string
+	}{
+		{
+			name:     "first page without sort",
+			query:    map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}, "from": 40, "size": 10},
+			wantJSON: `{"query":{"match_all":{}},"size":500,"pit":{"id":"pit-1","keep_alive":"120000ms"},"sort":[{"_shard_doc":"asc"}]}`,
+		},
+		{
+			name:        "next page with sort",
+			query:       map[string]interface{}{"sort": []interface{}{map[string]interface{}{"price": "asc"}}},
+			searchAfter: []interface{}{90, 12},
+			wantJSON:    `{"size":500,"pit":{"id":"pit-1","keep_alive":"120000ms"},"sort":[{"price":"asc"},{"_shard_doc":"asc"}],"search_after":[90,12]}`,
+		},
+		{
+			name:     "single sort value",
+			query:    map[string]interface{}{"sort": "_score"},
+			wantJSON: `{"size":500,"pit":{"id":"pit-1","keep_alive":"120000ms"},"sort":["_score",{"_shard_doc":"asc"}]}`,
+		},
+		{
+			name:     "typed sort slice",
+			query:    map[string]interface{}{"sort": []map[string]interface{}{{"price": "asc"}, {"name": "desc"}}},
+			wantJSON: `{"size":500,"pit":{"id":"pit-1","keep_alive":"120000ms"},"sort":[{"price":"asc"},{"name":"desc"},{"_shard_doc":"asc"}]}`,
+		},
+		{
+			name:     "sort field names",
+			query:    map[string]interface{}{"sort": []string{"_score", "price"}},
+			wantJSON: `{"size":500,"pit":{"id":"pit-1","keep_alive":"120000ms"},"sort":["_score","price",{"_shard_doc":"asc"}]}`,
+		},
+		{
+			name:     "typed tiebreaker already present",
+			query:    map[string]interface{}{"sort": []map[string]string{{"_shard_doc": "desc"}}},
+			wantJSON: `{"size":500,"pit":{"id":"pit-1","keep_alive":"120000ms"},"sort":[{"_shard_doc":"desc"}]}`,
+		},
+		{
+			name:     "tiebreaker already present",
+			query:    map[string]interface{}{"sort": []interface{}{map[string]interface{}{"_shard_doc": "desc"}}},
+			wantJSON: `{"size":500,"pit":{"id":"pit-1","keep_alive":"120000ms"},"sort":[{"_shard_doc":"desc"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := scanPageBody(tt.query, "pit-1", opts, tt.searchAfter)
+			got, err := json.Marshal(body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantJSON, string(got))
+		})
+	}
+}
+
+func TestScanPageBody_DoesNotModifyQuery(t *testing.T) {
+	query := map[string]interface{}{"from": 10, "sort": []interface{}{"_score"}}
+	scanPageBody(query, "pit-1", ScanOptions{}.withDefaults(), nil)
+	assert.Equal(t, map[string]interface{}{"from": 10, "sort": []interface{}{"_score"}}, query)
+}
+
+func TestScanOptions_WithDefaults(t *testing.T) {
+	assert.Equal(t, ScanOptions{PageSize: defaultScanPageSize, KeepAlive: defaultScanKeepAlive}, ScanOptions{}.withDefaults())
+	assert.Equal(t, ScanOptions{PageSize: 10, KeepAlive: time.Second}, ScanOptions{PageSize: 10, KeepAlive: time.Second}.withDefaults())
+}
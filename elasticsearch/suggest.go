@@ -0,0 +1,343 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Suggester is a suggester of the Elasticsearch search API, e.g. for type-ahead or "did you mean"
+type Suggester interface {
+	// Source returns the JSON body of the suggester, or an error if it is invalid
+	Source() (map[string]interface{}, error)
+}
+
+// suggesterSources returns the bodies of a set of named suggesters
+func suggesterSources(suggesters map[string]Suggester) (map[string]interface{}, error) {
+	sources := make(map[string]interface{}, len(suggesters))
+	for name, suggester := range suggesters {
+		if name == "" {
+			return nil, fmt.Errorf("suggester name cannot be empty")
+		}
+		if suggester == nil {
+			return nil, fmt.Errorf("suggester %s cannot be nil", name)
+		}
+		source, err := suggester.Source()
+		if err != nil {
+			return nil, fmt.Errorf("suggester %s: %w", name, err)
+		}
+		sources[name] = source
+	}
+	return sources, nil
+}
+
+// CompletionSuggester completes a prefix from a completion field, returning the matching documents
+type CompletionSuggester struct {
+	field          string
+	prefix         string
+	size           *int
+	skipDuplicates bool
+	fuzziness      string
+	contexts       map[string][]string
+}
+
+func NewCompletionSuggester(field string, prefix string) *CompletionSuggester {
+	return &CompletionSuggester{field: field, prefix: prefix}
+}
+
+func (s *CompletionSuggester) Size(size int) *CompletionSuggester {
+	s.size = &size
+	return s
+}
+
+// SkipDuplicates returns each suggestion text once, even if several documents share it
+func (s *CompletionSuggester) SkipDuplicates() *CompletionSuggester {
+	s.skipDuplicates = true
+	return s
+}
+
+// Fuzzy tolerates typos in the prefix, e.g. "AUTO" or "1"
+func (s *CompletionSuggester) Fuzzy(fuzziness string) *CompletionSuggester {
+	s.fuzziness = fuzziness
+	return s
+}
+
+// Context restricts the suggestions to documents with one of the values in a context of the completion field,
+// e.g. a category
+func (s *CompletionSuggester) Context(name string, values ...string) *CompletionSuggester {
+	if s.contexts == nil {
+		s.contexts = map[string][]string{}
+	}
+	s.contexts[name] = append(s.contexts[name], values...)
+	return s
+}
+
+func (s *CompletionSuggester) Source() (map[string]interface{}, error) {
+	if s.field == "" {
+		return nil, fmt.Errorf("completion suggester requires a field")
+	}
+	if s.prefix == "" {
+		return nil, fmt.Errorf("completion suggester requires a prefix")
+	}
+
+	completion := map[string]interface{}{"field": s.field}
+	if s.size != nil {
+		if *s.size <= 0 {
+			return nil, fmt.Errorf("completion suggester size must be positive")
+		}
+		completion["size"] = *s.size
+	}
+	if s.skipDuplicates {
+		completion["skip_duplicates"] = true
+	}
+	if s.fuzziness != "" {
+		completion["fuzzy"] = map[string]interface{}{"fuzziness": s.fuzziness}
+	}
+	if len(s.contexts) > 0 {
+		contexts := make(map[string]interface{}, len(s.contexts))
+		for name, values := range s.contexts {
+			if name == "" || len(values) == 0 {
+				return nil, fmt.Errorf("completion suggester contexts require a name and values")
+			}
+			contexts[name] = values
+		}
+		completion["contexts"] = contexts
+	}
+
+	return map[string]interface{}{"prefix": s.prefix, "completion": completion}, nil
+}
+
+// TermSuggester suggests corrections for each term of a text that is misspelled
+type TermSuggester struct {
+	field       string
+	text        string
+	size        *int
+	suggestMode string
+}
+
+func NewTermSuggester(field string, text string) *TermSuggester {
+	return &TermSuggester{field: field, text: text}
+}
+
+func (s *TermSuggester) Size(size int) *TermSuggester {
+	s.size = &size
+	return s
+}
+
+// SuggestMode is missing (the default) to only correct terms not in the index, popular to suggest terms
+// more frequent than the given one, or always
+func (s *TermSuggester) SuggestMode(mode string) *TermSuggester {
+	s.suggestMode = mode
+	return s
+}
+
+func (s *TermSuggester) Source() (map[string]interface{}, error) {
+	if s.field == "" {
+		return nil, fmt.Errorf("term suggester requires a field")
+	}
+	if s.text == "" {
+		return nil, fmt.Errorf("term suggester requires a text")
+	}
+	switch s.suggestMode {
+	case "", "missing", "popular", "always":
+	default:
+		return nil, fmt.Errorf("unknown suggest mode %q", s.suggestMode)
+	}
+
+	term := map[string]interface{}{"field": s.field}
+	if s.size != nil {
+		if *s.size <= 0 {
+			return nil, fmt.Errorf("term suggester size must be positive")
+		}
+		term["size"] = *s.size
+	}
+	setIfNotEmpty(term, "suggest_mode", s.suggestMode)
+
+	return map[string]interface{}{"text": s.text, "term": term}, nil
+}
+
+// PhraseSuggester suggests corrections of a whole text, e.g. for "did you mean"
+type PhraseSuggester struct {
+	field      string
+	text       string
+	size       *int
+	confidence *float64
+	maxErrors  *float64
+	preTag     string
+	postTag    string
+}
+
+func NewPhraseSuggester(field string, text string) *PhraseSuggester {
+	return &PhraseSuggester{field: field, text: text}
+}
+
+func (s *PhraseSuggester) Size(size int) *PhraseSuggester {
+	s.size = &size
+	return s
+}
+
+// Confidence only returns corrections scoring this many times more than the text itself, 1 by default
+func (s *PhraseSuggester) Confidence(confidence float64) *PhraseSuggester {
+	s.confidence = &confidence
+	return s
+}
+
+// MaxErrors is the number of misspelled terms corrected, or the fraction of the terms when below 1
+func (s *PhraseSuggester) MaxErrors(maxErrors float64) *PhraseSuggester {
+	s.maxErrors = &maxErrors
+	return s
+}
+
+// Highlight sets the markup placed around corrected terms in SuggestionOption.Highlighted
+func (s *PhraseSuggester) Highlight(preTag string, postTag string) *PhraseSuggester {
+	s.preTag = preTag
+	s.postTag = postTag
+	return s
+}
+
+func (s *PhraseSuggester) Source() (map[string]interface{}, error) {
+	if s.field == "" {
+		return nil, fmt.Errorf("phrase suggester requires a field")
+	}
+	if s.text == "" {
+		return nil, fmt.Errorf("phrase suggester requires a text")
+	}
+
+	phrase := map[string]interface{}{"field": s.field}
+	if s.size != nil {
+		if *s.size <= 0 {
+			return nil, fmt.Errorf("phrase suggester size must be positive")
+		}
+		phrase["size"] = *s.size
+	}
+	if s.confidence != nil {
+		if *s.confidence < 0 {
+			return nil, fmt.Errorf("phrase suggester confidence cannot be negative")
+		}
+		phrase["confidence"] = *s.confidence
+	}
+	if s.maxErrors != nil {
+		if *s.maxErrors <= 0 {
+			return nil, fmt.Errorf("phrase suggester max errors must be positive")
+		}
+		phrase["max_errors"] = *s.maxErrors
+	}
+	if s.preTag != "" || s.postTag != "" {
+		phrase["highlight"] = map[string]interface{}{"pre_tag": s.preTag, "post_tag": s.postTag}
+	}
+
+	return map[string]interface{}{"text": s.text, "phrase": phrase}, nil
+}
+
+// NewSearchAsYouTypeQuery matches the words of a partially typed text against a search_as_you_type field,
+// the last word being a prefix
+func NewSearchAsYouTypeQuery(field string, text string) *MultiMatchQuery {
+	return NewMultiMatchQuery(text, field, field+"._2gram", field+"._3gram").Type("bool_prefix")
+}
+
+// Suggestions holds the entries of every suggester of a search, by suggester name
+type Suggestions map[string][]SuggestionEntry
+
+// SuggestionEntry holds the suggestions for a part of the suggested text: the prefix of a completion
+// suggester, a term of a term suggester or the whole text of a phrase suggester
+type SuggestionEntry struct {
+	Text    string             `json:"text"`
+	Offset  int                `json:"offset"`
+	Length  int                `json:"length"`
+	Options []SuggestionOption `json:"options"`
+}
+
+// SuggestionOption is a suggestion. Freq is only set by term suggesters, Highlighted by phrase suggesters,
+// and the document fields by completion suggesters.
+type SuggestionOption struct {
+	Text        string
+	Score       float64
+	Freq        int
+	Highlighted string
+	Index       string
+	ID          string
+	Source      json.RawMessage
+	Contexts    map[string][]string
+}
+
+// UnmarshalJSON decodes an option, whose score is "_score" for completion suggesters and "score" for the others
+func (o *SuggestionOption) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Text        string              `json:"text"`
+		Score       *float64            `json:"score"`
+		DocScore    *float64            `json:"_score"`
+		Freq        int                 `json:"freq"`
+		Highlighted string              `json:"highlighted"`
+		Index       string              `json:"_index"`
+		ID          string              `json:"_id"`
+		Source      json.RawMessage     `json:"_source"`
+		Contexts    map[string][]string `json:"contexts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*o = SuggestionOption{
+		Text:        raw.Text,
+		Freq:        raw.Freq,
+		Highlighted: raw.Highlighted,
+		Index:       raw.Index,
+		ID:          raw.ID,
+		Source:      raw.Source,
+		Contexts:    raw.Contexts,
+	}
+	if raw.Score != nil {
+		o.Score = *raw.Score
+	} else if raw.DocScore != nil {
+		o.Score = *raw.DocScore
+	}
+	return nil
+}
+
+// Options returns the options of all the entries of a suggester, e.g. the completions of its prefix
+func (s Suggestions) Options(name string) []SuggestionOption {
+	var options []SuggestionOption
+	for _, entry := range s[name] {
+		options = append(options, entry.Options...)
+	}
+	return options
+}
+
+// AddSuggester adds a named suggester to the query, replacing any suggester with the same name
+func (q *ESQuery) AddSuggester(name string, suggester Suggester) error {
+	sources, err := suggesterSources(map[string]Suggester{name: suggester})
+	if err != nil {
+		return err
+	}
+
+	if q.query == nil {
+		q.query = map[string]interface{}{}
+	}
+	suggest, ok := q.query["suggest"].(map[string]interface{})
+	if !ok {
+		suggest = map[string]interface{}{}
+		q.query["suggest"] = suggest
+	}
+	suggest[name] = sources[name]
+	return nil
+}
+
+// Suggest runs suggesters on an index without returning search hits
+func (es *ElasticsearchClient) Suggest(ctx context.Context, index string, suggesters map[string]Suggester, opts ...RequestOption) (Suggestions, error) {
+	if index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
+	if len(suggesters) == 0 {
+		return nil, fmt.Errorf("no suggesters to run")
+	}
+	suggest, err := suggesterSources(suggesters)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := es.Search(ctx, CreateESQuery(index, map[string]interface{}{"size": 0, "suggest": suggest}), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return result.Suggest, nil
+}
@@ -0,0 +1,158 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggester_Source(t *testing.T) {
+	tests := []struct {
+		name      string
+		suggester Suggester
+		want      string
+		wantErr   string
+	}{
+		{
+			name:      "completion",
+			suggester: NewCompletionSuggester("name.suggest", "oat m"),
+			want:      `{"prefix": "oat m", "completion": {"field": "name.suggest"}}`,
+		},
+		{
+			name: "completion with options",
+			suggester: NewCompletionSuggester("name.suggest", "oat m").Size(5).SkipDuplicates().Fuzzy("AUTO").
+				Context("category", "dairy", "plant-based"),
+			want: `{"prefix": "oat m", "completion": {"field": "name.suggest", "size": 5, "skip_duplicates": true,
+				"fuzzy": {"fuzziness": "AUTO"}, "contexts": {"category": ["dairy", "plant-based"]}}}`,
+		},
+		{
+			name:      "completion without prefix",
+			suggester: NewCompletionSuggester("name.suggest", ""),
+			wantErr:   "completion suggester requires a prefix",
+		},
+		{
+			name:      "completion context without values",
+			suggester: NewCompletionSuggester("name.suggest", "oat").Context("category"),
+			wantErr:   "completion suggester contexts require a name and values",
+		},
+		{
+			name:      "term",
+			suggester: NewTermSuggester("name", "oat milj").Size(3).SuggestMode("popular"),
+			want:      `{"text": "oat milj", "term": {"field": "name", "size": 3, "suggest_mode": "popular"}}`,
+		},
+		{
+			name:      "term with unknown mode",
+			suggester: NewTermSuggester("name", "oat milj").SuggestMode("sometimes"),
+			wantErr:   `unknown suggest mode "sometimes"`,
+		},
+		{
+			name:      "phrase",
+			suggester: NewPhraseSuggester("name.trigram", "oat milj").Size(1).Confidence(0).MaxErrors(2).Highlight("<em>", "</em>"),
+			want: `{"text": "oat milj", "phrase": {"field": "name.trigram", "size": 1, "confidence": 0, "max_errors": 2,
+				"highlight": {"pre_tag": "<em>", "post_tag": "</em>"}}}`,
+		},
+		{
+			name:      "phrase without field",
+			suggester: NewPhraseSuggester("", "oat milj"),
+			wantErr:   "phrase suggester requires a field",
+		},
+		{
+			name:      "phrase with invalid max errors",
+			suggester: NewPhraseSuggester("name.trigram", "oat milj").MaxErrors(0),
+			wantErr:   "phrase suggester max errors must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := tt.suggester.Source()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			data, err := json.Marshal(source)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(data))
+		})
+	}
+}
+
+func TestNewSearchAsYouTypeQuery(t *testing.T) {
+	source, err := NewSearchAsYouTypeQuery("name", "oat mi").Source()
+	require.NoError(t, err)
+	data, err := json.Marshal(source)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"multi_match": {"query": "oat mi", "type": "bool_prefix", "fields": ["name", "name._2gram", "name._3gram"]}}`, string(data))
+}
+
+func TestSearchBuilder_Suggester(t *testing.T) {
+	query, err := NewSearchBuilder("products").
+		Query(NewSearchAsYouTypeQuery("name", "oat mi")).
+		Suggester("did_you_mean", NewPhraseSuggester("name.trigram", "oat mi")).
+		Build()
+	require.NoError(t, err)
+	assert.Contains(t, query.query["suggest"], "did_you_mean")
+
+	_, err = NewSearchBuilder("products").Suggester("complete", nil).Build()
+	assert.EqualError(t, err, "invalid suggester: suggester complete cannot be nil")
+
+	esQuery := CreateESQuery("products", map[string]interface{}{})
+	require.NoError(t, esQuery.AddSuggester("complete", NewCompletionSuggester("name.suggest", "oat")))
+	require.NoError(t, esQuery.AddSuggester("spelling", NewTermSuggester("name", "oat")))
+	assert.Len(t, esQuery.query["suggest"], 2)
+}
+
+func TestSuggestions_UnmarshalJSON(t *testing.T) {
+	var result SearchResult
+	require.NoError(t, json.Unmarshal([]byte(`{"hits": {"hits": []}, "suggest": {
+		"complete": [{"text": "oat m", "offset": 0, "length": 5, "options": [
+			{"text": "Oat Milk", "_index": "products", "_id": "7", "_score": 12.0, "_source": {"name": "Oat Milk"}, "contexts": {"category": ["dairy"]}}]}],
+		"spelling": [
+			{"text": "oat", "offset": 0, "length": 3, "options": []},
+			{"text": "milj", "offset": 4, "length": 4, "options": [{"text": "milk", "score": 0.75, "freq": 42}]}],
+		"did_you_mean": [{"text": "oat milj", "offset": 0, "length": 8, "options": [
+			{"text": "oat milk", "highlighted": "oat <em>milk</em>", "score": 0.31}]}]
+	}}`), &result))
+
+	complete := result.Suggest.Options("complete")
+	require.Len(t, complete, 1)
+	assert.Equal(t, "Oat Milk", complete[0].Text)
+	assert.Equal(t, 12.0, complete[0].Score)
+	assert.Equal(t, "7", complete[0].ID)
+	assert.JSONEq(t, `{"name": "Oat Milk"}`, string(complete[0].Source))
+	assert.Equal(t, map[string][]string{"category": {"dairy"}}, complete[0].Contexts)
+
+	assert.Len(t, result.Suggest["spelling"], 2)
+	assert.Equal(t, []SuggestionOption{{Text: "milk", Score: 0.75, Freq: 42}}, result.Suggest.Options("spelling"))
+	assert.Equal(t, []SuggestionOption{{Text: "oat milk", Score: 0.31, Highlighted: "oat <em>milk</em>"}}, result.Suggest.Options("did_you_mean"))
+	assert.Empty(t, result.Suggest.Options("missing"))
+}
+
+func TestElasticsearchClient_Suggest(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/products/_search", req.URL.Path)
+		data, _ := io.ReadAll(req.Body)
+		assert.JSONEq(t, `{"size": 0, "suggest": {"complete": {"prefix": "oat", "completion": {"field": "name.suggest", "size": 3}}}}`, string(data))
+		return http.StatusOK, `{"hits": {"total": {"value": 0}, "hits": []}, "suggest": {"complete": [{"text": "oat", "offset": 0, "length": 3,
+			"options": [{"text": "Oat Milk", "_id": "7", "_score": 3.0}, {"text": "Oatmeal", "_id": "9", "_score": 2.0}]}]}}`
+	})
+
+	suggestions, err := es.Suggest(context.Background(), "products", map[string]Suggester{
+		"complete": NewCompletionSuggester("name.suggest", "oat").Size(3),
+	})
+	require.NoError(t, err)
+	options := suggestions.Options("complete")
+	require.Len(t, options, 2)
+	assert.Equal(t, "Oatmeal", options[1].Text)
+
+	_, err = es.Suggest(context.Background(), "products", nil)
+	assert.EqualError(t, err, "no suggesters to run")
+	_, err = es.Suggest(context.Background(), "", map[string]Suggester{"complete": NewCompletionSuggester("name.suggest", "oat")})
+	assert.EqualError(t, err, "index name cannot be empty")
+}
@@ -0,0 +1,262 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// TemplateQuery searches an index with a mustache search template, either stored under an id with
+// PutSearchTemplate or given inline, expanded with params
+type TemplateQuery struct {
+	index  string
+	id     string
+	source interface{}
+	params map[string]interface{}
+}
+
+// NewTemplateQuery runs the stored template id with params
+func NewTemplateQuery(index string, id string, params map[string]interface{}) *TemplateQuery {
+	return &TemplateQuery{index: index, id: id, params: params}
+}
+
+// NewInlineTemplateQuery runs a template that is not stored, e.g. while tuning it
+func NewInlineTemplateQuery(index string, source interface{}, params map[string]interface{}) *TemplateQuery {
+	return &TemplateQuery{index: index, source: source, params: params}
+}
+
+func (t *TemplateQuery) body() (map[string]interface{}, error) {
+	if (t.id == "") == (t.source == nil) {
+		return nil, fmt.Errorf("template query requires either a template id or a source")
+	}
+
+	body := map[string]interface{}{}
+	if t.id != "" {
+		body["id"] = t.id
+	} else {
+		body["source"] = t.source
+	}
+	if len(t.params) > 0 {
+		body["params"] = t.params
+	}
+	return body, nil
+}
+
+// PutSearchTemplate stores or replaces a mustache search template. source is the template as a string,
+// which allows mustache sections such as {{#size}}, or as a query body whose strings contain {{params}}.
+func (es *ElasticsearchClient) PutSearchTemplate(ctx context.Context, id string, source interface{}, opts ...RequestOption) error {
+	if id == "" {
+		return fmt.Errorf("template id cannot be empty")
+	}
+	if source == nil {
+		return fmt.Errorf("template %s has no source", id)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"script": map[string]interface{}{"lang": "mustache", "source": source},
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling template: %w", err)
+	}
+
+	return es.perform(ctx, esapi.PutScriptRequest{ScriptID: id, Body: bytes.NewReader(body)}, "storing search template", nil)
+}
+
+// DeleteSearchTemplate deletes a stored search template, returning ErrNotFound if there is none
+func (es *ElasticsearchClient) DeleteSearchTemplate(ctx context.Context, id string, opts ...RequestOption) error {
+	if id == "" {
+		return fmt.Errorf("template id cannot be empty")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	return es.perform(ctx, esapi.DeleteScriptRequest{ScriptID: id}, "deleting search template", nil)
+}
+
+// SearchTemplate runs a template query and returns the hits with their metadata
+func (es *ElasticsearchClient) SearchTemplate(ctx context.Context, query *TemplateQuery, opts ...RequestOption) (*SearchResult, error) {
+	if query == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	if query.index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
+	body, err := query.body()
+	if err != nil {
+		return nil, err
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling template query: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	res, err := esapi.SearchTemplateRequest{Index: []string{query.index}, Body: bytes.NewReader(bodyBytes)}.Do(ctx, es.client)
+	if err != nil {
+		return nil, fmt.Errorf("error searching with template: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error searching with template: %s", res.String())
+	}
+
+	return decodeSearchResult(res.Body)
+}
+
+// RenderSearchTemplate returns the query a template query expands to, without running it
+func (es *ElasticsearchClient) RenderSearchTemplate(ctx context.Context, query *TemplateQuery, opts ...RequestOption) (map[string]interface{}, error) {
+	if query == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	body, err := query.body()
+	if err != nil {
+		return nil, err
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling template query: %w", err)
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	var rendered struct {
+		TemplateOutput map[string]interface{} `json:"template_output"`
+	}
+	req := esapi.RenderSearchTemplateRequest{Body: bytes.NewReader(bodyBytes)}
+	if err := es.perform(ctx, req, "rendering search template", &rendered); err != nil {
+		return nil, err
+	}
+	return rendered.TemplateOutput, nil
+}
+
+// MultiTemplateQuery holds template queries run together by MultiSearchTemplate
+type MultiTemplateQuery struct {
+	queries []*TemplateQuery
+	names   []string
+}
+
+func CreateMultiTemplateQuery() *MultiTemplateQuery {
+	return &MultiTemplateQuery{
+		queries: []*TemplateQuery{},
+		names:   []string{},
+	}
+}
+
+func (m *MultiTemplateQuery) AddQuery(query *TemplateQuery) {
+	if query != nil {
+		m.queries = append(m.queries, query)
+		m.names = append(m.names, "")
+	}
+}
+
+// AddNamedQuery adds a query whose result can be looked up by name in the MultiSearchResult
+func (m *MultiTemplateQuery) AddNamedQuery(name string, query *TemplateQuery) error {
+	if query == nil {
+		return fmt.Errorf("query %s is nil", name)
+	}
+	if err := checkQueryName(m.names, name); err != nil {
+		return err
+	}
+	m.queries = append(m.queries, query)
+	m.names = append(m.names, name)
+	return nil
+}
+
+func (m *MultiTemplateQuery) createBuffer(index string) (*bytes.Buffer, error) {
+	if len(m.queries) == 0 {
+		return nil, fmt.Errorf("no queries to create multi-search buffer")
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	for i, query := range m.queries {
+		if query.index == "" {
+			return nil, fmt.Errorf("index name cannot be empty")
+		}
+		body, err := query.body()
+		if err != nil {
+			return nil, fmt.Errorf("invalid template query %d: %w", i, err)
+		}
+
+		// like MultiESQuery, queries on the default index get an empty header
+		header := map[string]interface{}{}
+		if query.index != index {
+			header["index"] = query.index
+		}
+		for _, line := range []interface{}{header, body} {
+			lineBytes, err := json.Marshal(line)
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling template query %d: %w", i, err)
+			}
+			buffer.Write(lineBytes)
+			buffer.WriteByte('\n')
+		}
+	}
+
+	return buffer, nil
+}
+
+// MultiSearchTemplate runs template queries in a single request. Like MultiSearch, a failed query does not fail
+// the call but sets the error of its response.
+func (es *ElasticsearchClient) MultiSearchTemplate(ctx context.Context, index string, query *MultiTemplateQuery, opts ...RequestOption) (*MultiSearchResult, error) {
+	if query == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	if index == "" {
+		return nil, fmt.Errorf("index name cannot be empty")
+	}
+
+	o, err := newRequestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	buffer, err := query.createBuffer(index)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing multi-search template request: %w", err)
+	}
+
+	res, err := esapi.MsearchTemplateRequest{Index: []string{index}, Body: bytes.NewReader(buffer.Bytes())}.Do(ctx, es.client)
+	if err != nil {
+		return nil, fmt.Errorf("error performing multi-search template: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error in multi-search template response: %s", res.String())
+	}
+
+	responses, err := decodeMultiSearchResponses(res.Body, len(query.queries))
+	if err != nil {
+		return nil, err
+	}
+	return newMultiSearchResult(responses, query.names), nil
+}
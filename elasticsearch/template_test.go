@@ -0,0 +1,153 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const productSearchTemplate = `{"query": {"match": {"name": "{{query}}"}}{{#size}}, "size": {{size}}{{/size}}}`
+
+func jsonString(t *testing.T, s string) string {
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestElasticsearchClient_PutSearchTemplate(t *testing.T) {
+	var method, path, body string
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		method, path = req.Method, req.URL.Path
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+		return http.StatusOK, `{"acknowledged": true}`
+	})
+
+	require.NoError(t, es.PutSearchTemplate(context.Background(), "product-search", productSearchTemplate))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/_scripts/product-search", path)
+	assert.JSONEq(t, `{"script": {"lang": "mustache", "source": `+jsonString(t, productSearchTemplate)+`}}`, body)
+
+	source := map[string]interface{}{"query": map[string]interface{}{"term": map[string]interface{}{"brand": "{{brand}}"}}}
+	require.NoError(t, es.PutSearchTemplate(context.Background(), "brand-search", source))
+	assert.JSONEq(t, `{"script": {"lang": "mustache", "source": {"query": {"term": {"brand": "{{brand}}"}}}}}`, body)
+
+	assert.EqualError(t, es.PutSearchTemplate(context.Background(), "", source), "template id cannot be empty")
+	assert.EqualError(t, es.PutSearchTemplate(context.Background(), "empty", nil), "template empty has no source")
+}
+
+func TestElasticsearchClient_DeleteSearchTemplate(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, http.MethodDelete, req.Method)
+		if req.URL.Path == "/_scripts/product-search" {
+			return http.StatusOK, `{"acknowledged": true}`
+		}
+		return http.StatusNotFound, `{"error": {"type": "resource_not_found_exception", "reason": "stored script does not exist"}, "status": 404}`
+	})
+
+	assert.NoError(t, es.DeleteSearchTemplate(context.Background(), "product-search"))
+	assert.ErrorIs(t, es.DeleteSearchTemplate(context.Background(), "missing"), ErrNotFound)
+}
+
+func TestElasticsearchClient_SearchTemplate(t *testing.T) {
+	var path, body string
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		path = req.URL.Path
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+		return http.StatusOK, `{"took": 3, "hits": {"total": {"value": 1}, "hits": [{"_index": "products", "_id": "1", "_score": 1.2, "_source": {"name": "milk"}}]}}`
+	})
+
+	result, err := es.SearchTemplate(context.Background(), NewTemplateQuery("products", "product-search", map[string]interface{}{"query": "milk", "size": 5}))
+	require.NoError(t, err)
+	assert.Equal(t, "/products/_search/template", path)
+	assert.JSONEq(t, `{"id": "product-search", "params": {"query": "milk", "size": 5}}`, body)
+	require.Len(t, result.Hits, 1)
+	assert.JSONEq(t, `{"name": "milk"}`, string(result.Hits[0].Source))
+
+	_, err = es.SearchTemplate(context.Background(), NewInlineTemplateQuery("products", productSearchTemplate, nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"source": `+jsonString(t, productSearchTemplate)+`}`, body)
+}
+
+func TestElasticsearchClient_SearchTemplate_Invalid(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		return http.StatusBadRequest, `{"error": {"type": "illegal_argument_exception", "reason": "unable to find script [missing]"}}`
+	})
+
+	tests := []struct {
+		name    string
+		query   *TemplateQuery
+		wantErr string
+	}{
+		{name: "nil", query: nil, wantErr: "query is nil"},
+		{name: "no index", query: NewTemplateQuery("", "product-search", nil), wantErr: "index name cannot be empty"},
+		{name: "no template", query: NewTemplateQuery("products", "", nil), wantErr: "template query requires either a template id or a source"},
+		{name: "id and source", query: &TemplateQuery{index: "products", id: "product-search", source: "{}"}, wantErr: "template query requires either a template id or a source"},
+		{name: "missing template", query: NewTemplateQuery("products", "missing", nil), wantErr: "unable to find script [missing]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := es.SearchTemplate(context.Background(), tt.query)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestElasticsearchClient_RenderSearchTemplate(t *testing.T) {
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/_render/template", req.URL.Path)
+		data, _ := io.ReadAll(req.Body)
+		assert.JSONEq(t, `{"id": "product-search", "params": {"query": "milk"}}`, string(data))
+		return http.StatusOK, `{"template_output": {"query": {"match": {"name": "milk"}}}}`
+	})
+
+	rendered, err := es.RenderSearchTemplate(context.Background(), NewTemplateQuery("products", "product-search", map[string]interface{}{"query": "milk"}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"query": map[string]interface{}{"match": map[string]interface{}{"name": "milk"}}}, rendered)
+}
+
+func TestElasticsearchClient_MultiSearchTemplate(t *testing.T) {
+	var path, body string
+	es := newTestClient(t, func(req *http.Request) (int, string) {
+		path = req.URL.Path
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+		return http.StatusOK, `{"responses": [
+			{"hits": {"hits": [{"_index": "products", "_id": "1", "_source": {"name": "milk"}}]}, "status": 200},
+			{"error": {"type": "illegal_argument_exception", "reason": "unable to find script [promo-search]"}, "status": 400}
+		]}`
+	})
+
+	query := CreateMultiTemplateQuery()
+	require.NoError(t, query.AddNamedQuery("keyword", NewTemplateQuery("products", "product-search", map[string]interface{}{"query": "milk"})))
+	require.NoError(t, query.AddNamedQuery("promotions", NewTemplateQuery("promotions", "promo-search", nil)))
+	assert.EqualError(t, query.AddNamedQuery("keyword", NewTemplateQuery("products", "product-search", nil)), "query keyword was already added")
+
+	result, err := es.MultiSearchTemplate(context.Background(), "products", query)
+	require.NoError(t, err)
+	assert.Equal(t, "/products/_msearch/template", path)
+
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 4)
+	assert.JSONEq(t, `{}`, lines[0])
+	assert.JSONEq(t, `{"id": "product-search", "params": {"query": "milk"}}`, lines[1])
+	assert.JSONEq(t, `{"index": "promotions"}`, lines[2])
+	assert.JSONEq(t, `{"id": "promo-search"}`, lines[3])
+
+	keyword, err := result.Get("keyword")
+	require.NoError(t, err)
+	assert.Len(t, keyword.Hits, 1)
+	_, err = result.Get("promotions")
+	assert.EqualError(t, err, "error in search response promotions: illegal_argument_exception: unable to find script [promo-search]")
+
+	_, err = es.MultiSearchTemplate(context.Background(), "products", CreateMultiTemplateQuery())
+	assert.ErrorContains(t, err, "no queries")
+}
@@ -0,0 +1,291 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	defaultKnnK          = 10
+	maxKnnNumCandidates  = 10000
+	defaultEmbedderBatch = 64
+)
+
+// Embedder computes the embedding vectors of texts, e.g. with a hosted embedding model.
+// It returns one vector per text, in the same order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// KnnSearch finds the k nearest neighbours of a vector in a dense_vector field
+type KnnSearch struct {
+	field         string
+	vector        []float32
+	k             int
+	numCandidates int
+	filters       []Query
+	similarity    *float64
+	boost         *float64
+}
+
+// NewKnnSearch searches field for the 10 nearest neighbours of vector, use K to change it
+func NewKnnSearch(field string, vector []float32) *KnnSearch {
+	return &KnnSearch{field: field, vector: vector, k: defaultKnnK}
+}
+
+// EmbedKnnSearch computes the vector of a text with the embedder and searches field for its nearest neighbours
+func EmbedKnnSearch(ctx context.Context, embedder Embedder, field string, text string) (*KnnSearch, error) {
+	vectors, err := embed(ctx, embedder, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return NewKnnSearch(field, vectors[0]), nil
+}
+
+func (k *KnnSearch) K(n int) *KnnSearch {
+	k.k = n
+	return k
+}
+
+// NumCandidates is the number of candidates considered per shard, more is slower but more accurate. 10*k by default.
+func (k *KnnSearch) NumCandidates(n int) *KnnSearch {
+	k.numCandidates = n
+	return k
+}
+
+// Filter restricts the neighbours to documents matching all the queries, e.g. in stock products of a brand.
+// Unlike a post filter, it is applied during the search so that k neighbours are still returned.
+func (k *KnnSearch) Filter(queries ...Query) *KnnSearch {
+	k.filters = append(k.filters, queries...)
+	return k
+}
+
+// Similarity drops the neighbours whose similarity is below the given value
+func (k *KnnSearch) Similarity(similarity float64) *KnnSearch {
+	k.similarity = &similarity
+	return k
+}
+
+func (k *KnnSearch) Boost(boost float64) *KnnSearch {
+	k.boost = &boost
+	return k
+}
+
+func (k *KnnSearch) Source() (map[string]interface{}, error) {
+	if k.field == "" {
+		return nil, fmt.Errorf("knn requires a field")
+	}
+	if len(k.vector) == 0 {
+		return nil, fmt.Errorf("knn on %s requires a query vector", k.field)
+	}
+	if k.k <= 0 {
+		return nil, fmt.Errorf("knn on %s requires a positive k", k.field)
+	}
+	numCandidates := k.numCandidates
+	if numCandidates == 0 {
+		numCandidates = min(10*k.k, maxKnnNumCandidates)
+	}
+	if numCandidates < k.k || numCandidates > maxKnnNumCandidates {
+		return nil, fmt.Errorf("knn on %s requires num_candidates between k and %d", k.field, maxKnnNumCandidates)
+	}
+
+	body := map[string]interface{}{
+		"field":          k.field,
+		"query_vector":   k.vector,
+		"k":              k.k,
+		"num_candidates": numCandidates,
+	}
+	if len(k.filters) > 0 {
+		filters, err := querySources(k.filters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid knn filter: %w", err)
+		}
+		body["filter"] = filters
+	}
+	if k.similarity != nil {
+		body["similarity"] = *k.similarity
+	}
+	if k.boost != nil {
+		body["boost"] = *k.boost
+	}
+
+	return body, nil
+}
+
+// Retriever returns a search stage of a retriever tree, e.g. a child of an RRFRetriever
+type Retriever interface {
+	RetrieverSource() (map[string]interface{}, error)
+}
+
+// StandardRetriever retrieves the documents matching a query, scored by BM25
+type StandardRetriever struct {
+	query Query
+}
+
+func NewStandardRetriever(query Query) *StandardRetriever {
+	return &StandardRetriever{query: query}
+}
+
+func (r *StandardRetriever) RetrieverSource() (map[string]interface{}, error) {
+	if r.query == nil {
+		return nil, fmt.Errorf("standard retriever requires a query")
+	}
+	query, err := r.query.Source()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"standard": map[string]interface{}{"query": query}}, nil
+}
+
+// RetrieverSource lets a kNN search be used as a child of an RRFRetriever
+func (k *KnnSearch) RetrieverSource() (map[string]interface{}, error) {
+	knn, err := k.Source()
+	if err != nil {
+		return nil, err
+	}
+	delete(knn, "boost")
+	return map[string]interface{}{"knn": knn}, nil
+}
+
+// RRFRetriever merges the results of its children with reciprocal rank fusion, e.g. BM25 and kNN for hybrid search
+type RRFRetriever struct {
+	retrievers     []Retriever
+	rankConstant   *int
+	rankWindowSize *int
+}
+
+func NewRRFRetriever(retrievers ...Retriever) *RRFRetriever {
+	return &RRFRetriever{retrievers: retrievers}
+}
+
+// NewHybridRetriever combines a full text query and a kNN search with reciprocal rank fusion
+func NewHybridRetriever(query Query, knn *KnnSearch) *RRFRetriever {
+	return NewRRFRetriever(NewStandardRetriever(query), knn)
+}
+
+// RankConstant sets how much low ranked documents contribute, 60 by default
+func (r *RRFRetriever) RankConstant(constant int) *RRFRetriever {
+	r.rankConstant = &constant
+	return r
+}
+
+// RankWindowSize sets how many results of each child are fused
+func (r *RRFRetriever) RankWindowSize(size int) *RRFRetriever {
+	r.rankWindowSize = &size
+	return r
+}
+
+func (r *RRFRetriever) RetrieverSource() (map[string]interface{}, error) {
+	if len(r.retrievers) < 2 {
+		return nil, fmt.Errorf("rrf retriever requires at least two retrievers")
+	}
+
+	retrievers := make([]interface{}, 0, len(r.retrievers))
+	for _, retriever := range r.retrievers {
+		if retriever == nil {
+			return nil, fmt.Errorf("rrf retriever cannot be nil")
+		}
+		source, err := retriever.RetrieverSource()
+		if err != nil {
+			return nil, err
+		}
+		retrievers = append(retrievers, source)
+	}
+
+	rrf := map[string]interface{}{"retrievers": retrievers}
+	if r.rankConstant != nil {
+		if *r.rankConstant < 1 {
+			return nil, fmt.Errorf("rrf rank constant must be at least 1")
+		}
+		rrf["rank_constant"] = *r.rankConstant
+	}
+	if r.rankWindowSize != nil {
+		if *r.rankWindowSize < 1 {
+			return nil, fmt.Errorf("rrf rank window size must be at least 1")
+		}
+		rrf["rank_window_size"] = *r.rankWindowSize
+	}
+
+	return map[string]interface{}{"rrf": rrf}, nil
+}
+
+// AddKnn adds a kNN search to the query, next to its other kNN searches and full text query
+func (q *ESQuery) AddKnn(knn *KnnSearch) error {
+	if knn == nil {
+		return fmt.Errorf("knn cannot be nil")
+	}
+	source, err := knn.Source()
+	if err != nil {
+		return err
+	}
+
+	if q.query == nil {
+		q.query = map[string]interface{}{}
+	}
+	switch existing := q.query["knn"].(type) {
+	case nil:
+		q.query["knn"] = source
+	case []interface{}:
+		q.query["knn"] = append(existing, source)
+	default:
+		q.query["knn"] = []interface{}{existing, source}
+	}
+	return nil
+}
+
+// EmbedDocuments computes the embedding of every document from its text and stores it with set,
+// calling the embedder in batches
+func EmbedDocuments[T any](ctx context.Context, embedder Embedder, docs []T, text func(*T) string, set func(*T, []float32)) error {
+	for start := 0; start < len(docs); start += defaultEmbedderBatch {
+		batch := docs[start:min(start+defaultEmbedderBatch, len(docs))]
+		texts := make([]string, len(batch))
+		for i := range batch {
+			texts[i] = text(&batch[i])
+		}
+
+		vectors, err := embed(ctx, embedder, texts)
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			set(&batch[i], vectors[i])
+		}
+	}
+	return nil
+}
+
+// IndexWithEmbeddings embeds the documents like EmbedDocuments and queues them on a bulk indexer under their id
+func IndexWithEmbeddings[T any](ctx context.Context, indexer *BulkIndexer, embedder Embedder, docs []T, id func(*T) string, text func(*T) string, set func(*T, []float32)) error {
+	if err := EmbedDocuments(ctx, embedder, docs, text, set); err != nil {
+		return err
+	}
+	for i := range docs {
+		if err := indexer.Add(ctx, BulkItem{Action: BulkIndex, ID: id(&docs[i]), Document: docs[i]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// embed calls the embedder and checks that it returned one vector of the same size per text
+func embed(ctx context.Context, embedder Embedder, texts []string) ([][]float32, error) {
+	if embedder == nil {
+		return nil, fmt.Errorf("embedder is nil")
+	}
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("error computing embeddings: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embedder returned an empty vector for text %d", i)
+		}
+		if len(vector) != len(vectors[0]) {
+			return nil, fmt.Errorf("embedder returned a vector of %d dimensions for text %d, expected %d", len(vector), i, len(vectors[0]))
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,189 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEmbedder maps a text to a vector of its length and word count
+type fakeEmbedder struct {
+	calls [][]string
+	err   error
+	short bool
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls = append(e.calls, texts)
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), float32(len(strings.Fields(text)))}
+	}
+	if e.short {
+		return vectors[1:], nil
+	}
+	return vectors, nil
+}
+
+func TestKnnSearch_Source(t *testing.T) {
+	tests := []struct {
+		name     string
+		knn      *KnnSearch
+		wantJSON string
+		wantErr  bool
+	}{
+		{
+			name:     "defaults",
+			knn:      NewKnnSearch("embedding", []float32{0.5, 1}),
+			wantJSON: `{"field":"embedding","query_vector":[0.5,1],"k":10,"num_candidates":100}`,
+		},
+		{
+			name: "filtered",
+			knn: NewKnnSearch("embedding", []float32{0.5, 1}).K(5).NumCandidates(50).Similarity(0.7).
+				Filter(NewTermQuery("in_stock", true)),
+			wantJSON: `{"field":"embedding","query_vector":[0.5,1],"k":5,"num_candidates":50,"similarity":0.7,
+				"filter":[{"term":{"in_stock":{"value":true}}}]}`,
+		},
+		{name: "without field", knn: NewKnnSearch("", []float32{1}), wantErr: true},
+		{name: "without vector", knn: NewKnnSearch("embedding", nil), wantErr: true},
+		{name: "zero k", knn: NewKnnSearch("embedding", []float32{1}).K(0), wantErr: true},
+		{name: "fewer candidates than k", knn: NewKnnSearch("embedding", []float32{1}).K(20).NumCandidates(10), wantErr: true},
+		{name: "invalid filter", knn: NewKnnSearch("embedding", []float32{1}).Filter(NewTermQuery("", "x")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := tt.knn.Source()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := json.Marshal(source)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantJSON, string(got))
+		})
+	}
+}
+
+func TestSearchBuilder_Knn(t *testing.T) {
+	query, err := NewSearchBuilder("products").
+		Query(NewMatchQuery("name", "running shoe")).
+		Knn(NewKnnSearch("embedding", []float32{1, 2}).K(3).Boost(0.5)).
+		Build()
+	require.NoError(t, err)
+	got, err := json.Marshal(query.query)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"query":{"match":{"name":{"query":"running shoe"}}},
+		"knn":[{"field":"embedding","query_vector":[1,2],"k":3,"num_candidates":30,"boost":0.5}]}`, string(got))
+
+	query, err = NewSearchBuilder("products").
+		Retriever(NewHybridRetriever(NewMatchQuery("name", "running shoe"), NewKnnSearch("embedding", []float32{1, 2}).K(3).Boost(2)).
+			RankConstant(20).RankWindowSize(50)).
+		Size(10).
+		Build()
+	require.NoError(t, err)
+	got, err = json.Marshal(query.query)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"size":10,"retriever":{"rrf":{"retrievers":[
+		{"standard":{"query":{"match":{"name":{"query":"running shoe"}}}}},
+		{"knn":{"field":"embedding","query_vector":[1,2],"k":3,"num_candidates":30}}],
+		"rank_constant":20,"rank_window_size":50}}}`, string(got))
+
+	_, err = NewSearchBuilder("products").
+		Query(NewMatchAllQuery()).
+		Retriever(NewHybridRetriever(NewMatchAllQuery(), NewKnnSearch("embedding", []float32{1}))).
+		Build()
+	assert.ErrorContains(t, err, "cannot be combined")
+
+	_, err = NewSearchBuilder("products").Retriever(NewRRFRetriever(NewStandardRetriever(NewMatchAllQuery()))).Build()
+	assert.ErrorContains(t, err, "at least two retrievers")
+}
+
+func TestESQuery_AddKnn(t *testing.T) {
+	query := CreateESQueryStr("products", `{"size":5}`)
+	require.NoError(t, query.AddKnn(NewKnnSearch("embedding", []float32{1}).K(1)))
+	require.NoError(t, query.AddKnn(NewKnnSearch("image_embedding", []float32{2}).K(1)))
+	got, err := json.Marshal(query.query)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"size":5,"knn":[
+		{"field":"embedding","query_vector":[1],"k":1,"num_candidates":10},
+		{"field":"image_embedding","query_vector":[2],"k":1,"num_candidates":10}]}`, string(got))
+
+	assert.Error(t, query.AddKnn(nil))
+}
+
+func TestEmbedKnnSearch(t *testing.T) {
+	embedder := &fakeEmbedder{}
+	knn, err := EmbedKnnSearch(context.Background(), embedder, "embedding", "red shoe")
+	require.NoError(t, err)
+	source, err := knn.Source()
+	require.NoError(t, err)
+	assert.Equal(t, []float32{8, 2}, source["query_vector"])
+
+	_, err = EmbedKnnSearch(context.Background(), &fakeEmbedder{err: errors.New("quota exceeded")}, "embedding", "red shoe")
+	assert.ErrorContains(t, err, "quota exceeded")
+	_, err = EmbedKnnSearch(context.Background(), nil, "embedding", "red shoe")
+	assert.Error(t, err)
+}
+
+type embeddedProduct struct {
+	SKU       string    `json:"sku"`
+	Name      string    `json:"name"`
+	Embedding []float32 `json:"embedding"`
+}
+
+func TestEmbedDocuments(t *testing.T) {
+	docs := make([]embeddedProduct, defaultEmbedderBatch+1)
+	for i := range docs {
+		docs[i] = embeddedProduct{SKU: fmt.Sprint(i), Name: strings.Repeat("a", i+1)}
+	}
+	text := func(p *embeddedProduct) string { return p.Name }
+	set := func(p *embeddedProduct, v []float32) { p.Embedding = v }
+
+	embedder := &fakeEmbedder{}
+	require.NoError(t, EmbedDocuments(context.Background(), embedder, docs, text, set))
+	assert.Len(t, embedder.calls, 2)
+	assert.Len(t, embedder.calls[1], 1)
+	assert.Equal(t, []float32{1, 1}, docs[0].Embedding)
+	assert.Equal(t, []float32{float32(defaultEmbedderBatch + 1), 1}, docs[defaultEmbedderBatch].Embedding)
+
+	err := EmbedDocuments(context.Background(), &fakeEmbedder{short: true}, docs[:2], text, set)
+	assert.ErrorContains(t, err, "1 vectors for 2 texts")
+}
+
+func TestIndexWithEmbeddings(t *testing.T) {
+	var captured []string
+	client := newTestClient(t, func(req *http.Request) (int, string) {
+		data, _ := io.ReadAll(req.Body)
+		captured = strings.Split(strings.TrimSpace(string(data)), "\n")
+		return http.StatusOK, `{"items":[{"index":{"status":201}},{"index":{"status":201}}]}`
+	})
+
+	indexer, err := client.NewBulkIndexer(context.Background(), BulkIndexerConfig{Index: "products"})
+	require.NoError(t, err)
+	docs := []embeddedProduct{{SKU: "sku-1", Name: "red shoe"}, {SKU: "sku-2", Name: "blue"}}
+	err = IndexWithEmbeddings(context.Background(), indexer, &fakeEmbedder{}, docs,
+		func(p *embeddedProduct) string { return p.SKU },
+		func(p *embeddedProduct) string { return p.Name },
+		func(p *embeddedProduct, v []float32) { p.Embedding = v })
+	require.NoError(t, err)
+	require.NoError(t, indexer.Close(context.Background()))
+
+	require.Len(t, captured, 4)
+	assert.JSONEq(t, `{"index":{"_index":"products","_id":"sku-1"}}`, captured[0])
+	assert.JSONEq(t, `{"sku":"sku-1","name":"red shoe","embedding":[8,2]}`, captured[1])
+	assert.JSONEq(t, `{"sku":"sku-2","name":"blue","embedding":[4,1]}`, captured[3])
+	assert.Equal(t, uint64(2), indexer.Stats().NumIndexed)
+}
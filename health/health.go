@@ -0,0 +1,131 @@
+// Package health runs the readiness checks of a service's dependencies and serves their outcome over HTTP,
+// e.g. for a Kubernetes readiness probe.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kdjuwidja/aishoppercommon/logger"
+)
+
+const defaultCheckTimeout = 5 * time.Second
+
+// Checker reports whether a dependency is ready, returning nil if it is
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets a function be used as a Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Registry holds the named checks of a service
+type Registry struct {
+	mu      sync.RWMutex
+	checks  map[string]Checker
+	timeout time.Duration
+}
+
+// NewRegistry creates a registry whose checks time out after 5 seconds
+func NewRegistry() *Registry {
+	return &Registry{checks: map[string]Checker{}, timeout: defaultCheckTimeout}
+}
+
+// SetTimeout changes how long each check can run before it is reported as failed
+func (r *Registry) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = timeout
+}
+
+// Register adds a check, replacing any check with the same name
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = checker
+}
+
+// Report is the outcome of running all the checks. Checks maps every check name to "ok" or its error.
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Run runs all the checks concurrently
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]Checker, len(r.checks))
+	for name, checker := range r.checks {
+		checks[name] = checker
+	}
+	timeout := r.timeout
+	r.mu.RUnlock()
+
+	report := Report{Ready: true, Checks: make(map[string]string, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := runCheck(ctx, checker, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Ready = false
+				report.Checks[name] = err.Error()
+				return
+			}
+			report.Checks[name] = "ok"
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func runCheck(ctx context.Context, checker Checker, timeout time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check panicked: %v", r)
+		}
+	}()
+	return checker.Check(ctx)
+}
+
+// Handler serves the report of the checks as JSON, with status 200 when all of them passed and 503 otherwise
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+
+		status := http.StatusOK
+		if !report.Ready {
+			status = http.StatusServiceUnavailable
+			names := make([]string, 0, len(report.Checks))
+			for name, outcome := range report.Checks {
+				if outcome != "ok" {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			logger.Warnf("readiness check failed for %v", names)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]Checker
+		wantReady  bool
+		wantChecks map[string]string
+	}{
+		{
+			name:       "no checks",
+			checks:     map[string]Checker{},
+			wantReady:  true,
+			wantChecks: map[string]string{},
+		},
+		{
+			name: "all ready",
+			checks: map[string]Checker{
+				"elasticsearch": CheckerFunc(func(ctx context.Context) error { return nil }),
+				"mysql":         CheckerFunc(func(ctx context.Context) error { return nil }),
+			},
+			wantReady:  true,
+			wantChecks: map[string]string{"elasticsearch": "ok", "mysql": "ok"},
+		},
+		{
+			name: "one failing",
+			checks: map[string]Checker{
+				"elasticsearch": CheckerFunc(func(ctx context.Context) error { return errors.New("cluster is red") }),
+				"mysql":         CheckerFunc(func(ctx context.Context) error { return nil }),
+			},
+			wantReady:  false,
+			wantChecks: map[string]string{"elasticsearch": "cluster is red", "mysql": "ok"},
+		},
+		{
+			name: "panicking",
+			checks: map[string]Checker{
+				"kafka": CheckerFunc(func(ctx context.Context) error { panic("no broker") }),
+			},
+			wantReady:  false,
+			wantChecks: map[string]string{"kafka": "check panicked: no broker"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			for name, checker := range tt.checks {
+				registry.Register(name, checker)
+			}
+
+			report := registry.Run(context.Background())
+			assert.Equal(t, tt.wantReady, report.Ready)
+			assert.Equal(t, tt.wantChecks, report.Checks)
+		})
+	}
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	registry := NewRegistry()
+	registry.SetTimeout(10 * time.Millisecond)
+	registry.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	report := registry.Run(context.Background())
+	assert.False(t, report.Ready)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"])
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	ready := true
+	registry := NewRegistry()
+	registry.Register("elasticsearch", CheckerFunc(func(ctx context.Context) error {
+		if !ready {
+			return errors.New("cluster is red")
+		}
+		return nil
+	}))
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"ready": true, "checks": {"elasticsearch": "ok"}}`, rec.Body.String())
+
+	ready = false
+	rec = httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, Report{Ready: false, Checks: map[string]string{"elasticsearch": "cluster is red"}}, report)
+}
@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// ClaimCheckHeader is the message header holding the blob store reference of an offloaded payload
+const ClaimCheckHeader = "claimCheck"
+
+// defaultMaxMessageBytes matches the librdkafka default of message.max.bytes
+const defaultMaxMessageBytes = 1000000
+
+// ErrMessageTooLarge is returned when a message exceeds the producer's size limit and cannot be offloaded
+var ErrMessageTooLarge = errors.New("message too large")
+
+// BlobStore stores payloads too large to travel through Kafka (claim-check pattern).
+// Put returns a reference that Get resolves back to the payload.
+type BlobStore interface {
+	Put(ctx context.Context, data []byte) (string, error)
+	Get(ctx context.Context, ref string) ([]byte, error)
+}
+
+// messageSize approximates the size of a message as counted against message.max.bytes
+func messageSize(msg *kafka.Message) int {
+	size := len(msg.Key) + len(msg.Value)
+	for _, header := range msg.Headers {
+		size += len(header.Key) + len(header.Value)
+	}
+	return size
+}
+
+// hasClaimCheck reports whether the value of a message was offloaded to a blob store
+func hasClaimCheck(msg *kafka.Message) bool {
+	for _, header := range msg.Headers {
+		if header.Key == ClaimCheckHeader {
+			return true
+		}
+	}
+	return false
+}
+
+// prepare checks a message against the size limit, offloading its value to the blob store if it is too large.
+// Messages that already carry a claim check, e.g. when dead-lettered, are sent as they are.
+func (k *KafkaProducer) prepare(msg *kafka.Message) error {
+	size := messageSize(msg)
+	if k.blobStore != nil && size > k.claimCheckThreshold && !hasClaimCheck(msg) {
+		ref, err := k.blobStore.Put(context.Background(), msg.Value)
+		if err != nil {
+			return fmt.Errorf("failed to offload message to blob store: %v", err)
+		}
+		msg.Value = nil
+		msg.Headers = append(msg.Headers, kafka.Header{Key: ClaimCheckHeader, Value: []byte(ref)})
+		size = messageSize(msg)
+	}
+
+	if size > k.maxMessageBytes {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrMessageTooLarge, size, k.maxMessageBytes)
+	}
+	return nil
+}
+
+// SetBlobStore makes the consumer resolve claim-checked messages through the given store before handling them
+func (c *KafkaConsumer) SetBlobStore(store BlobStore) {
+	c.blobStore = store
+}
+
+// resolveClaimCheck returns the value of a message, fetching it from the blob store if the message is
+// claim-checked. The message itself is left unchanged, so that it is dead-lettered with its claim check.
+// A claim check that cannot be resolved without a blob store is a Permanent error.
+func (c *KafkaConsumer) resolveClaimCheck(msg *kafka.Message) ([]byte, error) {
+	for _, header := range msg.Headers {
+		if header.Key != ClaimCheckHeader {
+			continue
+		}
+
+		if c.blobStore == nil {
+			return nil, Permanent(fmt.Errorf("message carries a claim check but no blob store is configured"))
+		}
+		value, err := c.blobStore.Get(context.Background(), string(header.Value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch message from blob store: %v", err)
+		}
+		return value, nil
+	}
+
+	return msg.Value, nil
+}
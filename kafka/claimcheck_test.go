@@ -0,0 +1,212 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBlobStore is a BlobStore keeping payloads in memory
+type memoryBlobStore struct {
+	blobs map[string][]byte
+}
+
+func newMemoryBlobStore() *memoryBlobStore {
+	return &memoryBlobStore{blobs: make(map[string][]byte)}
+}
+
+func (s *memoryBlobStore) Put(ctx context.Context, data []byte) (string, error) {
+	ref := fmt.Sprintf("blob-%d", len(s.blobs))
+	s.blobs[ref] = data
+	return ref, nil
+}
+
+func (s *memoryBlobStore) Get(ctx context.Context, ref string) ([]byte, error) {
+	data, ok := s.blobs[ref]
+	if !ok {
+		return nil, fmt.Errorf("blob %s not found", ref)
+	}
+	return data, nil
+}
+
+func TestKafkaProducer_Prepare(t *testing.T) {
+	topic := "products"
+	newMessage := func(size int) *kafka.Message {
+		return &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          make([]byte, size),
+		}
+	}
+
+	t.Run("message within limit", func(t *testing.T) {
+		producer := &KafkaProducer{maxMessageBytes: 100, claimCheckThreshold: 100}
+		msg := newMessage(100)
+		assert.NoError(t, producer.prepare(msg))
+		assert.Len(t, msg.Value, 100)
+	})
+
+	t.Run("message too large without blob store", func(t *testing.T) {
+		producer := &KafkaProducer{maxMessageBytes: 100, claimCheckThreshold: 100}
+		err := producer.prepare(newMessage(101))
+		assert.ErrorIs(t, err, ErrMessageTooLarge)
+		assert.Contains(t, err.Error(), "101 bytes")
+	})
+
+	t.Run("message offloaded to blob store", func(t *testing.T) {
+		store := newMemoryBlobStore()
+		producer := &KafkaProducer{maxMessageBytes: 100, claimCheckThreshold: 50, blobStore: store}
+		msg := newMessage(80)
+		require.NoError(t, producer.prepare(msg))
+
+		assert.Nil(t, msg.Value)
+		require.Len(t, msg.Headers, 1)
+		assert.Equal(t, ClaimCheckHeader, msg.Headers[0].Key)
+		assert.Len(t, store.blobs[string(msg.Headers[0].Value)], 80)
+	})
+
+	t.Run("claim-checked message not offloaded again", func(t *testing.T) {
+		store := newMemoryBlobStore()
+		producer := &KafkaProducer{maxMessageBytes: 100, claimCheckThreshold: 10, blobStore: store}
+		msg := newMessage(0)
+		msg.Headers = []kafka.Header{{Key: ClaimCheckHeader, Value: []byte("blob-7")}, {Key: DeadLetterReasonHeader, Value: []byte("handler failed")}}
+		require.NoError(t, producer.prepare(msg))
+		assert.Len(t, msg.Headers, 2)
+		assert.Empty(t, store.blobs)
+	})
+
+	t.Run("message below claim check threshold", func(t *testing.T) {
+		store := newMemoryBlobStore()
+		producer := &KafkaProducer{maxMessageBytes: 100, claimCheckThreshold: 50, blobStore: store}
+		msg := newMessage(50)
+		require.NoError(t, producer.prepare(msg))
+		assert.Len(t, msg.Value, 50)
+		assert.Empty(t, store.blobs)
+	})
+}
+
+func TestKafkaConsumer_ResolveClaimCheck(t *testing.T) {
+	store := newMemoryBlobStore()
+	ref, err := store.Put(context.Background(), []byte(`{"requestId":"r-1","content":{}}`))
+	require.NoError(t, err)
+
+	t.Run("resolves claim check", func(t *testing.T) {
+		consumer := &KafkaConsumer{blobStore: store}
+		msg := &kafka.Message{Headers: []kafka.Header{{Key: ClaimCheckHeader, Value: []byte(ref)}}}
+		value, err := consumer.resolveClaimCheck(msg)
+		require.NoError(t, err)
+		assert.Equal(t, `{"requestId":"r-1","content":{}}`, string(value))
+		assert.Nil(t, msg.Value, "the message keeps its claim check")
+	})
+
+	t.Run("plain message untouched", func(t *testing.T) {
+		consumer := &KafkaConsumer{}
+		value, err := consumer.resolveClaimCheck(&kafka.Message{Value: []byte("plain")})
+		require.NoError(t, err)
+		assert.Equal(t, "plain", string(value))
+	})
+
+	t.Run("claim check without blob store", func(t *testing.T) {
+		consumer := &KafkaConsumer{}
+		msg := &kafka.Message{Headers: []kafka.Header{{Key: ClaimCheckHeader, Value: []byte(ref)}}}
+		_, err := consumer.resolveClaimCheck(msg)
+		assert.True(t, IsPermanent(err))
+	})
+
+	t.Run("unknown reference", func(t *testing.T) {
+		consumer := &KafkaConsumer{blobStore: store}
+		msg := &kafka.Message{Headers: []kafka.Header{{Key: ClaimCheckHeader, Value: []byte("missing")}}}
+		_, err := consumer.resolveClaimCheck(msg)
+		assert.Error(t, err)
+		assert.False(t, IsPermanent(err))
+	})
+}
+
+func TestKafkaConsumer_ProcessClaimCheck(t *testing.T) {
+	store := newMemoryBlobStore()
+	ref, err := store.Put(context.Background(), []byte(envelope("a")))
+	require.NoError(t, err)
+	claimCheck := kafka.Header{Key: ClaimCheckHeader, Value: []byte(ref)}
+
+	var handled []string
+	handler := func(content map[string]interface{}) error {
+		handled = append(handled, content["id"].(string))
+		return nil
+	}
+
+	t.Run("resolved message is handled", func(t *testing.T) {
+		handled = nil
+		client := newFakeConsumerClient()
+		consumer := newTestConsumer(client)
+		consumer.SetBlobStore(store)
+		client.append("orders", 0, "", claimCheck)
+
+		consumer.process(poll(t, client, consumer), handler)
+		assert.Equal(t, []string{"a"}, handled)
+		committed, _ := client.committedOffset("orders", 0)
+		assert.Equal(t, kafka.Offset(1), committed)
+	})
+
+	t.Run("unavailable payload is delivered again", func(t *testing.T) {
+		handled = nil
+		client := newFakeConsumerClient()
+		consumer := newTestConsumer(client)
+		consumer.SetRetryPolicy(0, time.Millisecond)
+		consumer.SetBlobStore(newMemoryBlobStore())
+		client.append("orders", 0, "", claimCheck)
+
+		consumer.process(poll(t, client, consumer), handler)
+		assert.Empty(t, handled)
+		_, ok := client.committedOffset("orders", 0)
+		assert.False(t, ok)
+		redelivered, ok := client.Poll(100).(*kafka.Message)
+		require.True(t, ok)
+		assert.Equal(t, kafka.Offset(0), redelivered.TopicPartition.Offset)
+	})
+
+	t.Run("claim check without blob store is dead-lettered", func(t *testing.T) {
+		handled = nil
+		client := newFakeConsumerClient()
+		consumer := newTestConsumer(client)
+		client.append("orders", 0, "", claimCheck)
+
+		consumer.process(poll(t, client, consumer), handler)
+		assert.Empty(t, handled)
+		committed, _ := client.committedOffset("orders", 0)
+		assert.Equal(t, kafka.Offset(1), committed)
+	})
+}
+
+func TestKafkaFactory_CreateProducerWithOptions_ClaimCheckThreshold(t *testing.T) {
+	f := &KafkaFactory{config: &kafka.ConfigMap{}}
+
+	_, err := f.CreateProducerWithOptions(ProducerOptions{MaxMessageBytes: 1000, ClaimCheckThreshold: 1001})
+	assert.EqualError(t, err, "claim check threshold of 1001 bytes exceeds the message size limit of 1000 bytes")
+
+	_, err = f.CreateProducerWithOptions(ProducerOptions{ClaimCheckThreshold: defaultMaxMessageBytes + 1})
+	assert.EqualError(t, err, "claim check threshold of 1000001 bytes exceeds the message size limit of 1000000 bytes")
+}
+
+func TestKafkaFactory_CreateProducerWithOptions_KeepsFactoryConfig(t *testing.T) {
+	f := &KafkaFactory{config: &kafka.ConfigMap{"bootstrap.servers": "localhost:9092", "compression.type": "snappy"}}
+
+	p, err := f.CreateProducerWithOptions(ProducerOptions{Compression: "zstd", MaxMessageBytes: 2000000})
+	require.NoError(t, err)
+	p.Close()
+
+	_, err = f.CreateProducerWithOptions(ProducerOptions{Compression: "lz4", MaxMessageBytes: 1000, ClaimCheckThreshold: 1001})
+	require.Error(t, err)
+
+	assert.Equal(t, kafka.ConfigMap{"bootstrap.servers": "localhost:9092", "compression.type": "snappy"}, *f.config)
+}
+
+func TestValidCompression(t *testing.T) {
+	for _, compression := range []string{"none", "gzip", "snappy", "lz4", "zstd"} {
+		assert.True(t, validCompression(compression), compression)
+	}
+	assert.False(t, validCompression("brotli"))
+}
@@ -0,0 +1,90 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// offsetTracker decides which handled messages may be committed while messages of a partition are
+// handled concurrently. Committing a message commits every offset before it, so a message is only
+// committed once no earlier message of its partition is still being handled or waiting to be
+// delivered again after a rewind.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	inFlight map[kafka.Offset]bool
+	// rewound is the offset the partition was rewound to, kafka.OffsetInvalid when it was not
+	rewound kafka.Offset
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[partitionKey]*partitionOffsets)}
+}
+
+func (t *offsetTracker) partition(tp kafka.TopicPartition) *partitionOffsets {
+	key := newPartitionKey(tp)
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{inFlight: make(map[kafka.Offset]bool), rewound: kafka.OffsetInvalid}
+		t.partitions[key] = p
+	}
+	return p
+}
+
+// start records that a message is being handled
+func (t *offsetTracker) start(tp kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partition(tp).inFlight[tp.Offset] = true
+}
+
+// done records that a message was handled and reports whether it may be committed
+func (t *offsetTracker) done(tp kafka.TopicPartition) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partition(tp)
+	delete(p.inFlight, tp.Offset)
+	if p.rewound != kafka.OffsetInvalid {
+		if tp.Offset > p.rewound {
+			// the message is delivered again after the failed one
+			return false
+		}
+		if tp.Offset == p.rewound {
+			p.rewound = kafka.OffsetInvalid
+		}
+	}
+	for offset := range p.inFlight {
+		if offset < tp.Offset {
+			return false
+		}
+	}
+	return true
+}
+
+// rewind records that a message failed and returns the offset to rewind its partition to,
+// which is that of an earlier failed message still waiting to be delivered again, if any
+func (t *offsetTracker) rewind(tp kafka.TopicPartition) kafka.Offset {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partition(tp)
+	delete(p.inFlight, tp.Offset)
+	if p.rewound == kafka.OffsetInvalid || tp.Offset < p.rewound {
+		p.rewound = tp.Offset
+	}
+	return p.rewound
+}
+
+// release forgets revoked partitions, their uncommitted messages are delivered to the new owner
+func (t *offsetTracker) release(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tp := range partitions {
+		delete(t.partitions, newPartitionKey(tp))
+	}
+}
@@ -0,0 +1,39 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	topic := "orders"
+	at := func(offset kafka.Offset) kafka.TopicPartition {
+		return kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: offset}
+	}
+
+	tracker := newOffsetTracker()
+	for offset := kafka.Offset(0); offset < 4; offset++ {
+		tracker.start(at(offset))
+	}
+
+	// a message is not committed while an earlier one is being handled
+	assert.False(t, tracker.done(at(1)))
+	assert.True(t, tracker.done(at(0)))
+
+	// messages after a failed one wait for it to be delivered again
+	assert.Equal(t, kafka.Offset(3), tracker.rewind(at(3)))
+	assert.Equal(t, kafka.Offset(2), tracker.rewind(at(2)))
+	tracker.start(at(2))
+	tracker.start(at(3))
+	assert.Equal(t, kafka.Offset(2), tracker.rewind(at(3)), "an earlier rewind is kept")
+	assert.True(t, tracker.done(at(2)))
+	assert.True(t, tracker.done(at(3)))
+
+	// revoked partitions are forgotten
+	tracker.start(at(4))
+	tracker.rewind(at(4))
+	tracker.release([]kafka.TopicPartition{at(0)})
+	assert.True(t, tracker.done(at(5)))
+}
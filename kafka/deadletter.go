@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Headers added to dead-lettered messages describing where they came from and why they failed
+const (
+	DeadLetterReasonHeader    = "dlqReason"
+	DeadLetterTopicHeader     = "dlqSourceTopic"
+	DeadLetterPartitionHeader = "dlqSourcePartition"
+	DeadLetterOffsetHeader    = "dlqSourceOffset"
+)
+
+// SetDeadLetterQueue makes the consumer publish messages it gives up on to the given topic.
+// Without a dead letter queue such messages are logged and dropped.
+func (c *KafkaConsumer) SetDeadLetterQueue(producer *KafkaProducer, topic string) {
+	c.deadLetterProducer = producer
+	c.deadLetterTopic = topic
+}
+
+// deadLetterAndCommit publishes a message to the dead letter queue and commits it on the source topic.
+// The partition is rewound to the message if publishing fails so it is not lost.
+func (c *KafkaConsumer) deadLetterAndCommit(msg *kafka.Message, reason error) {
+	if c.deadLetterProducer == nil {
+		fmt.Printf("No dead letter queue configured, dropping message: %v\n", reason)
+		c.commit(msg)
+		return
+	}
+
+	topic := c.deadLetterTopic
+	err := c.deadLetterProducer.produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        deadLetterHeaders(msg, reason),
+	})
+	if err != nil {
+		fmt.Printf("Error publishing message to dead letter queue, retrying: %v\n", err)
+		c.rewind(msg)
+		return
+	}
+
+	c.commit(msg)
+}
+
+// deadLetterHeaders returns the original headers of a message followed by the dead letter metadata
+func deadLetterHeaders(msg *kafka.Message, reason error) []kafka.Header {
+	sourceTopic := ""
+	if msg.TopicPartition.Topic != nil {
+		sourceTopic = *msg.TopicPartition.Topic
+	}
+
+	headers := make([]kafka.Header, 0, len(msg.Headers)+4)
+	headers = append(headers, msg.Headers...)
+	return append(headers,
+		kafka.Header{Key: DeadLetterReasonHeader, Value: []byte(reason.Error())},
+		kafka.Header{Key: DeadLetterTopicHeader, Value: []byte(sourceTopic)},
+		kafka.Header{Key: DeadLetterPartitionHeader, Value: []byte(strconv.Itoa(int(msg.TopicPartition.Partition)))},
+		kafka.Header{Key: DeadLetterOffsetHeader, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))},
+	)
+}
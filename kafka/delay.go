@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// DeliverAtHeader is the message header holding the earliest time a message may be handled.
+// The value is an RFC 3339 timestamp with nanosecond precision in UTC.
+const DeliverAtHeader = "deliverAt"
+
+// partitionKey identifies a topic partition in maps
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+func newPartitionKey(tp kafka.TopicPartition) partitionKey {
+	key := partitionKey{partition: tp.Partition}
+	if tp.Topic != nil {
+		key.topic = *tp.Topic
+	}
+	return key
+}
+
+func (p partitionKey) topicPartition() kafka.TopicPartition {
+	topic := p.topic
+	return kafka.TopicPartition{Topic: &topic, Partition: p.partition}
+}
+
+// deliverAtHeader builds the header announcing when a message should be delivered
+func deliverAtHeader(deliverAt time.Time) kafka.Header {
+	return kafka.Header{
+		Key:   DeliverAtHeader,
+		Value: []byte(deliverAt.UTC().Format(time.RFC3339Nano)),
+	}
+}
+
+// parseDeliverAt returns the deliver time of a message, if it has one
+func parseDeliverAt(headers []kafka.Header) (time.Time, bool, error) {
+	for _, header := range headers {
+		if header.Key != DeliverAtHeader {
+			continue
+		}
+
+		deliverAt, err := time.Parse(time.RFC3339Nano, string(header.Value))
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s header: %v", DeliverAtHeader, err)
+		}
+		return deliverAt, true, nil
+	}
+
+	return time.Time{}, false, nil
+}
+
+// shouldHold reports whether a message must wait for its deliver time, holding its partition if so
+func (c *KafkaConsumer) shouldHold(msg *kafka.Message) bool {
+	if _, held := c.held[newPartitionKey(msg.TopicPartition)]; held {
+		// The partition was rewound, the message will be fetched again once it is resumed
+		return true
+	}
+
+	deliverAt, ok, err := parseDeliverAt(msg.Headers)
+	if err != nil {
+		fmt.Printf("Error reading deliver time, delivering immediately: %v\n", err)
+		return false
+	}
+	if !ok || !time.Now().Before(deliverAt) {
+		return false
+	}
+
+	if err := c.holdUntil(msg, deliverAt); err != nil {
+		fmt.Printf("Error holding delayed message, delivering immediately: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// holdUntil pauses the message's partition and rewinds it to the message, so that it is
+// fetched again once the partition is resumed at deliverAt. The offset of a held message is not
+// committed, so it is delivered again if the consumer restarts or the partition is revoked meanwhile.
+func (c *KafkaConsumer) holdUntil(msg *kafka.Message, deliverAt time.Time) error {
+	tp := msg.TopicPartition
+	partition := []kafka.TopicPartition{{Topic: tp.Topic, Partition: tp.Partition}}
+	if err := c.client.Pause(partition); err != nil {
+		return fmt.Errorf("failed to pause partition: %v", err)
+	}
+
+	partition[0].Offset = tp.Offset
+	if _, err := c.client.SeekPartitions(partition); err != nil {
+		c.client.Resume(partition)
+		return fmt.Errorf("failed to seek partition: %v", err)
+	}
+
+	c.held[newPartitionKey(tp)] = deliverAt
+	return nil
+}
+
+// resumeDuePartitions resumes held partitions whose deliver time has passed
+func (c *KafkaConsumer) resumeDuePartitions(now time.Time) {
+	for key, deliverAt := range c.held {
+		if now.Before(deliverAt) {
+			continue
+		}
+
+		delete(c.held, key)
+		if err := c.client.Resume([]kafka.TopicPartition{key.topicPartition()}); err != nil {
+			fmt.Printf("Error resuming partition %s[%d]: %v\n", key.topic, key.partition, err)
+		}
+	}
+}
+
+// release forgets held partitions that were revoked, the new owner holds their messages again
+func (c *KafkaConsumer) release(partitions []kafka.TopicPartition) {
+	for _, tp := range partitions {
+		delete(c.held, newPartitionKey(tp))
+	}
+}
@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeliverAt(t *testing.T) {
+	deliverAt := time.Date(2025, 3, 14, 15, 9, 26, 535897932, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+		want    time.Time
+		wantOk  bool
+		wantErr bool
+	}{
+		{
+			name:    "header present",
+			headers: []kafka.Header{{Key: "other", Value: []byte("x")}, deliverAtHeader(deliverAt)},
+			want:    deliverAt,
+			wantOk:  true,
+		},
+		{
+			name:    "header absent",
+			headers: []kafka.Header{{Key: "other", Value: []byte("x")}},
+			wantOk:  false,
+		},
+		{
+			name:    "no headers",
+			headers: nil,
+			wantOk:  false,
+		},
+		{
+			name:    "invalid timestamp",
+			headers: []kafka.Header{{Key: DeliverAtHeader, Value: []byte("tomorrow")}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseDeliverAt(tt.headers)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.False(t, ok)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.True(t, tt.want.Equal(got))
+		})
+	}
+}
+
+func TestDeliverAtHeader_UsesUTC(t *testing.T) {
+	local := time.Date(2025, 1, 1, 12, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	header := deliverAtHeader(local)
+	assert.Equal(t, DeliverAtHeader, header.Key)
+	assert.Equal(t, "2025-01-01T10:00:00Z", string(header.Value))
+}
+
+func TestPartitionKey(t *testing.T) {
+	topic := "orders"
+	key := newPartitionKey(kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 42})
+	assert.Equal(t, partitionKey{topic: "orders", partition: 3}, key)
+
+	tp := key.topicPartition()
+	assert.Equal(t, "orders", *tp.Topic)
+	assert.Equal(t, int32(3), tp.Partition)
+}
+
+func TestHoldUntil(t *testing.T) {
+	client := newFakeConsumerClient()
+	consumer := newTestConsumer(client)
+	consumer.EnableDelayedDelivery()
+
+	deliverAt := time.Now().Add(time.Hour)
+	client.append("orders", 0, "early")
+	client.append("orders", 0, "delayed", deliverAtHeader(deliverAt))
+	client.append("orders", 0, "behind")
+
+	first := client.Poll(100).(*kafka.Message)
+	assert.False(t, consumer.shouldHold(first))
+	consumer.commit(first)
+
+	// the delayed message holds its partition, messages behind it wait as well
+	delayed := client.Poll(100).(*kafka.Message)
+	assert.True(t, consumer.shouldHold(delayed))
+	assert.Nil(t, client.Poll(100))
+	assert.Contains(t, consumer.held, partitionKey{topic: "orders", partition: 0})
+
+	// the held message is not committed, so a restart delivers it again
+	committed, ok := client.committedOffset("orders", 0)
+	assert.True(t, ok)
+	assert.Equal(t, kafka.Offset(1), committed)
+}
+
+func TestResumeDuePartitions(t *testing.T) {
+	client := newFakeConsumerClient()
+	consumer := newTestConsumer(client)
+	consumer.EnableDelayedDelivery()
+
+	deliverAt := time.Now().Add(time.Hour)
+	client.append("orders", 0, "delayed", deliverAtHeader(deliverAt))
+	assert.True(t, consumer.shouldHold(client.Poll(100).(*kafka.Message)))
+
+	consumer.resumeDuePartitions(deliverAt.Add(-time.Second))
+	assert.Nil(t, client.Poll(100))
+	assert.Equal(t, 0, client.resumes)
+
+	// once resumed, the partition is fetched again from the held message
+	consumer.resumeDuePartitions(deliverAt)
+	assert.Empty(t, consumer.held)
+	redelivered, ok := client.Poll(100).(*kafka.Message)
+	assert.True(t, ok)
+	assert.Equal(t, "delayed", string(redelivered.Value))
+	assert.Equal(t, kafka.Offset(0), redelivered.TopicPartition.Offset)
+}
+
+func TestRevokeWhileHolding(t *testing.T) {
+	client := newFakeConsumerClient()
+	consumer := newTestConsumer(client)
+	consumer.EnableDelayedDelivery()
+
+	deliverAt := time.Now().Add(time.Hour)
+	held := client.append("orders", 0, "delayed", deliverAtHeader(deliverAt))
+	client.append("orders", 1, "other")
+	assert.True(t, consumer.shouldHold(client.Poll(100).(*kafka.Message)))
+
+	var forwarded kafka.Event
+	rebalance := consumer.rebalance(func(c *kafka.Consumer, ev kafka.Event) error {
+		forwarded = ev
+		return nil
+	})
+	revoked := kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{held.TopicPartition}}
+	assert.NoError(t, rebalance(nil, revoked))
+
+	// the partition is forgotten, it is not resumed later and its message is left to the new owner
+	assert.Equal(t, revoked, forwarded)
+	assert.Empty(t, consumer.held)
+	consumer.resumeDuePartitions(deliverAt)
+	assert.Equal(t, 0, client.resumes)
+	_, ok := client.committedOffset("orders", 0)
+	assert.False(t, ok)
+
+	// without a callback the event is only used to forget the partitions
+	assert.NoError(t, consumer.rebalance(nil)(nil, revoked))
+}
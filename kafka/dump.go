@@ -0,0 +1,153 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// dumpGroupId is the group id used by dump consumers, they never join it or commit offsets
+const dumpGroupId = "aishoppercommon-dump"
+
+// DumpedMessage is a single line of a topic dump
+type DumpedMessage struct {
+	Topic     string            `json:"topic"`
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	// Value holds the message as JSON, or as a JSON string if it is not valid JSON, or null for tombstones
+	Value json.RawMessage `json:"value"`
+}
+
+// DumpMessages writes the messages of a topic between from (inclusive) and to (exclusive) to w as JSON lines
+// and returns the number of messages written. Offsets of consumer groups are not affected.
+func (f *KafkaFactory) DumpMessages(ctx context.Context, topic string, from OffsetSpec, to OffsetSpec, w io.Writer) (int, error) {
+	if topic == "" {
+		return 0, fmt.Errorf("topic cannot be empty")
+	}
+
+	consumer, err := f.newToolConsumer(dumpGroupId)
+	if err != nil {
+		return 0, err
+	}
+	defer consumer.Close()
+
+	start, err := resolveOffsets(consumer, topic, from)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve start offsets: %v", err)
+	}
+	end, err := resolveOffsets(consumer, topic, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve end offsets: %v", err)
+	}
+
+	endOffsets := make(map[int32]kafka.Offset, len(end))
+	for _, tp := range end {
+		endOffsets[tp.Partition] = tp.Offset
+	}
+
+	remaining := make(map[int32]kafka.Offset)
+	var assignment []kafka.TopicPartition
+	for _, tp := range start {
+		endOffset, ok := endOffsets[tp.Partition]
+		if !ok {
+			_, high, err := consumer.QueryWatermarkOffsets(topic, tp.Partition, offsetQueryTimeoutMs)
+			if err != nil {
+				return 0, fmt.Errorf("failed to query watermarks of partition %d: %v", tp.Partition, err)
+			}
+			endOffset = kafka.Offset(high)
+		}
+		if tp.Offset < endOffset {
+			remaining[tp.Partition] = endOffset
+			assignment = append(assignment, tp)
+		}
+	}
+	if len(assignment) == 0 {
+		return 0, nil
+	}
+
+	if err := consumer.Assign(assignment); err != nil {
+		return 0, fmt.Errorf("failed to assign partitions: %v", err)
+	}
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	for len(remaining) > 0 {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		ev := consumer.Poll(100)
+		if ev == nil {
+			continue
+		}
+
+		switch e := ev.(type) {
+		case *kafka.Message:
+			partition := e.TopicPartition.Partition
+			endOffset, ok := remaining[partition]
+			if !ok {
+				continue
+			}
+			if e.TopicPartition.Offset >= endOffset {
+				delete(remaining, partition)
+				continue
+			}
+
+			if err := encoder.Encode(toDumpedMessage(e)); err != nil {
+				return count, fmt.Errorf("failed to write message: %v", err)
+			}
+			count++
+
+			if e.TopicPartition.Offset+1 >= endOffset {
+				delete(remaining, partition)
+			}
+		case kafka.PartitionEOF:
+			delete(remaining, e.Partition)
+		case kafka.Error:
+			if e.IsFatal() || e.Code() == kafka.ErrAllBrokersDown {
+				return count, fmt.Errorf("consumer error: %v", e)
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// toDumpedMessage converts a consumed message into its dump representation
+func toDumpedMessage(msg *kafka.Message) DumpedMessage {
+	dumped := DumpedMessage{
+		Partition: msg.TopicPartition.Partition,
+		Offset:    int64(msg.TopicPartition.Offset),
+		Timestamp: msg.Timestamp,
+		Key:       string(msg.Key),
+	}
+	if msg.TopicPartition.Topic != nil {
+		dumped.Topic = *msg.TopicPartition.Topic
+	}
+
+	if len(msg.Headers) > 0 {
+		dumped.Headers = make(map[string]string, len(msg.Headers))
+		for _, header := range msg.Headers {
+			dumped.Headers[header.Key] = string(header.Value)
+		}
+	}
+
+	switch {
+	case msg.Value == nil:
+		dumped.Value = json.RawMessage("null")
+	case json.Valid(msg.Value):
+		dumped.Value = json.RawMessage(msg.Value)
+	default:
+		value, _ := json.Marshal(string(msg.Value))
+		dumped.Value = value
+	}
+
+	return dumped
+}
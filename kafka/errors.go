@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"errors"
+)
+
+// Skip can be returned by a handler to acknowledge a message and drop it without further processing
+var Skip = errors.New("skip message")
+
+// ErrPoisonMessage is reported for messages that cannot be decoded into the expected envelope
+var ErrPoisonMessage = errors.New("poison message")
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Retryable marks a handler error as transient, the message is handled again after a backoff
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// Permanent marks a handler error as unrecoverable, the message is sent to the dead letter queue right away
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable reports whether any error in err's chain was marked with Retryable
+func IsRetryable(err error) bool {
+	var target *retryableError
+	return errors.As(err, &target)
+}
+
+// IsPermanent reports whether any error in err's chain was marked with Permanent
+func IsPermanent(err error) bool {
+	var target *permanentError
+	return errors.As(err, &target)
+}
@@ -0,0 +1,123 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClassification(t *testing.T) {
+	base := errors.New("boom")
+
+	tests := []struct {
+		name          string
+		err           error
+		wantRetryable bool
+		wantPermanent bool
+		wantSkip      bool
+	}{
+		{name: "plain error", err: base},
+		{name: "retryable", err: Retryable(base), wantRetryable: true},
+		{name: "wrapped retryable", err: fmt.Errorf("handler: %w", Retryable(base)), wantRetryable: true},
+		{name: "permanent", err: Permanent(base), wantPermanent: true},
+		{name: "wrapped permanent", err: fmt.Errorf("handler: %w", Permanent(base)), wantPermanent: true},
+		{name: "skip", err: Skip, wantSkip: true},
+		{name: "wrapped skip", err: fmt.Errorf("duplicate: %w", Skip), wantSkip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantRetryable, IsRetryable(tt.err))
+			assert.Equal(t, tt.wantPermanent, IsPermanent(tt.err))
+			assert.Equal(t, tt.wantSkip, errors.Is(tt.err, Skip))
+		})
+	}
+}
+
+func TestRetryablePermanent_KeepCause(t *testing.T) {
+	base := errors.New("boom")
+
+	assert.Nil(t, Retryable(nil))
+	assert.Nil(t, Permanent(nil))
+	assert.ErrorIs(t, Retryable(base), base)
+	assert.ErrorIs(t, Permanent(base), base)
+	assert.Equal(t, "boom", Retryable(base).Error())
+	assert.Equal(t, "boom", Permanent(base).Error())
+}
+
+func TestParseEnvelope(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		wantRequestId string
+		wantContent   map[string]interface{}
+		wantErr       bool
+	}{
+		{
+			name:          "valid envelope",
+			value:         `{"requestId":"req-1","content":{"cartId":"c-1"}}`,
+			wantRequestId: "req-1",
+			wantContent:   map[string]interface{}{"cartId": "c-1"},
+		},
+		{
+			name:    "invalid JSON",
+			value:   `{"requestId":`,
+			wantErr: true,
+		},
+		{
+			name:    "missing requestId",
+			value:   `{"content":{"cartId":"c-1"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "requestId not a string",
+			value:   `{"requestId":1,"content":{"cartId":"c-1"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing content",
+			value:   `{"requestId":"req-1"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestId, content, err := parseEnvelope([]byte(tt.value))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrPoisonMessage)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRequestId, requestId)
+			assert.Equal(t, tt.wantContent, content)
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Second, retryDelay(time.Second, 0))
+	assert.Equal(t, 2*time.Second, retryDelay(time.Second, 1))
+	assert.Equal(t, 8*time.Second, retryDelay(time.Second, 3))
+}
+
+func TestDeadLetterHeaders(t *testing.T) {
+	topic := "carts"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 2, Offset: 17},
+		Headers:        []kafka.Header{{Key: "traceId", Value: []byte("t-1")}},
+	}
+
+	headers := deadLetterHeaders(msg, errors.New("boom"))
+	assert.Equal(t, []kafka.Header{
+		{Key: "traceId", Value: []byte("t-1")},
+		{Key: DeadLetterReasonHeader, Value: []byte("boom")},
+		{Key: DeadLetterTopicHeader, Value: []byte("carts")},
+		{Key: DeadLetterPartitionHeader, Value: []byte("2")},
+		{Key: DeadLetterOffsetHeader, Value: []byte("17")},
+	}, headers)
+}
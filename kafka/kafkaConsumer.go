@@ -2,15 +2,46 @@ package kafka
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 )
 
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = time.Second
+)
+
+// consumerClient is the part of *kafka.Consumer used by KafkaConsumer, faked in tests
+type consumerClient interface {
+	SubscribeTopics(topics []string, rebalanceCb kafka.RebalanceCb) error
+	Poll(timeoutMs int) kafka.Event
+	CommitMessage(m *kafka.Message) ([]kafka.TopicPartition, error)
+	SeekPartitions(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	Pause(partitions []kafka.TopicPartition) error
+	Resume(partitions []kafka.TopicPartition) error
+	Close() error
+}
+
 type KafkaConsumer struct {
 	running bool
-	client  *kafka.Consumer
+	client  consumerClient
+
+	delayedDelivery bool
+	held            map[partitionKey]time.Time
+
+	offsets *offsetTracker
+
+	maxRetries   int
+	retryBackoff time.Duration
+
+	deadLetterProducer *KafkaProducer
+	deadLetterTopic    string
+
+	blobStore BlobStore
 }
 
 type RedisConfig struct {
@@ -23,19 +54,68 @@ func NewKafkaConsumer(kafkaConfig *kafka.ConfigMap) (*KafkaConsumer, error) {
 		return nil, fmt.Errorf("consumer config cannot be nil")
 	}
 
-	client, err := kafka.NewConsumer(kafkaConfig)
+	client, err := kafka.NewConsumer(consumerConfig(kafkaConfig))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create consumer: %v", err)
 	}
 
 	return &KafkaConsumer{
-		running: false,
-		client:  client,
+		running:      false,
+		client:       client,
+		held:         make(map[partitionKey]time.Time),
+		offsets:      newOffsetTracker(),
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
 	}, nil
 }
 
+// consumerConfig copies a consumer config, turning off the automatic storage of polled offsets.
+// Offsets are only committed once their message has been handled, so that a message that is held,
+// retried or still being handled is delivered again after a restart or a rebalance.
+func consumerConfig(kafkaConfig *kafka.ConfigMap) *kafka.ConfigMap {
+	config := make(kafka.ConfigMap, len(*kafkaConfig)+1)
+	for key, value := range *kafkaConfig {
+		config[key] = value
+	}
+	config["enable.auto.offset.store"] = false
+	return &config
+}
+
+// EnableDelayedDelivery makes the consumer hold messages carrying a DeliverAtHeader until
+// their deliver time. The partition of a held message is paused and rewound to it, so
+// messages behind it on the same partition wait as well. Must be called before Start.
+func (c *KafkaConsumer) EnableDelayedDelivery() {
+	c.delayedDelivery = true
+}
+
+// SetRetryPolicy configures how often a message whose handler returned a Retryable error is
+// handled again before it is dead-lettered. The backoff doubles after every attempt.
+func (c *KafkaConsumer) SetRetryPolicy(maxRetries int, backoff time.Duration) {
+	c.maxRetries = maxRetries
+	c.retryBackoff = backoff
+}
+
 func (c *KafkaConsumer) Start(topic string, handler func(map[string]interface{}) error) {
-	if err := c.client.SubscribeTopics([]string{topic}, nil); err != nil {
+	c.run(topic, nil, func(msg *kafka.Message) {
+		c.offsets.start(msg.TopicPartition)
+		go c.process(msg, handler)
+	})
+}
+
+// StartWithMessageHandler consumes a topic and hands raw messages, including tombstones, to the handler
+// one at a time in partition order. A message whose handler fails is handled again after the retry backoff
+// before any later message of its partition, unless the error is Permanent or an ErrPoisonMessage, in which
+// case the message is dead-lettered. rebalanceCb may be nil, or take over partition assignment,
+// e.g. to start from offsets stored outside Kafka.
+func (c *KafkaConsumer) StartWithMessageHandler(topic string, rebalanceCb kafka.RebalanceCb, handler func(*kafka.Message) error) {
+	c.run(topic, rebalanceCb, func(msg *kafka.Message) {
+		c.processInOrder(msg, handler)
+	})
+}
+
+// run subscribes to a topic and polls it until the consumer is stopped, passing messages to onMessage
+func (c *KafkaConsumer) run(topic string, rebalanceCb kafka.RebalanceCb, onMessage func(*kafka.Message)) {
+	if err := c.client.SubscribeTopics([]string{topic}, c.rebalance(rebalanceCb)); err != nil {
 		fmt.Printf("Error subscribing to topics: %v\n", err)
 		os.Exit(1)
 	}
@@ -43,6 +123,10 @@ func (c *KafkaConsumer) Start(topic string, handler func(map[string]interface{})
 
 	c.running = true
 	for c.running {
+		if c.delayedDelivery {
+			c.resumeDuePartitions(time.Now())
+		}
+
 		ev := c.client.Poll(100)
 		if ev == nil {
 			continue
@@ -50,37 +134,11 @@ func (c *KafkaConsumer) Start(topic string, handler func(map[string]interface{})
 
 		switch e := ev.(type) {
 		case *kafka.Message:
-			go func(msg *kafka.Message) {
-				var jsonMsg map[string]interface{}
-				if err := json.Unmarshal(msg.Value, &jsonMsg); err != nil {
-					fmt.Printf("Error parsing message as JSON: %v\n", err)
-					return
-				}
-				requestId, ok := jsonMsg["requestId"].(string)
-				if !ok {
-					fmt.Printf("Error: requestId not found or not a string in message\n")
-					return
-				}
-
-				content, ok := jsonMsg["content"].(map[string]interface{})
-				if !ok {
-					fmt.Printf("Error: content not found or invalid in message\n")
-					return
-				}
-
-				fmt.Printf("Processing message with requestId: %s\n", requestId)
-
-				if err := handler(content); err != nil {
-					fmt.Printf("Error processing message: %v\n", err)
-					// TODO: Need to resubmit the message to the same topic
-				} else {
-					fmt.Printf("Successfully processed message with requestId: %s\n", requestId)
-					if _, err := c.client.CommitMessage(e); err != nil {
-						fmt.Printf("Error committing message: %v\n", err)
-					}
-				}
-
-			}(e)
+			if c.delayedDelivery && c.shouldHold(e) {
+				continue
+			}
+
+			onMessage(e)
 		case kafka.Error:
 			fmt.Printf("Error: %v\n", e)
 			if e.Code() == kafka.ErrAllBrokersDown {
@@ -92,6 +150,140 @@ func (c *KafkaConsumer) Start(topic string, handler func(map[string]interface{})
 	}
 }
 
+// rebalance forgets the state kept for revoked partitions before passing the event to rebalanceCb, if any.
+// Without a callback that assigns partitions itself, the client applies the default assignment.
+func (c *KafkaConsumer) rebalance(rebalanceCb kafka.RebalanceCb) kafka.RebalanceCb {
+	return func(client *kafka.Consumer, ev kafka.Event) error {
+		if revoked, ok := ev.(kafka.RevokedPartitions); ok {
+			c.release(revoked.Partitions)
+			c.offsets.release(revoked.Partitions)
+		}
+		if rebalanceCb != nil {
+			return rebalanceCb(client, ev)
+		}
+		return nil
+	}
+}
+
 func (c *KafkaConsumer) Stop() {
 	c.running = false
 }
+
+// process decodes a message envelope, hands its content to the handler and acts on the outcome:
+//   - nil or Skip: the message is committed
+//   - Retryable: the handler is called again with backoff, then the message is dead-lettered
+//   - Permanent: the message is dead-lettered and committed
+//   - any other error: the partition is rewound to the message after the retry backoff, so that
+//     it is delivered again. Later messages of the partition are delivered again as well.
+//
+// Messages that are not a valid envelope are treated as poison and dead-lettered. Claim-checked messages
+// are rewound when their payload cannot be fetched, and dead-lettered when no blob store is configured.
+func (c *KafkaConsumer) process(msg *kafka.Message, handler func(map[string]interface{}) error) {
+	value, err := c.resolveClaimCheck(msg)
+	if err != nil {
+		fmt.Printf("Error resolving claim check: %v\n", err)
+		if IsPermanent(err) {
+			c.deadLetterAndCommit(msg, err)
+		} else {
+			c.rewind(msg)
+		}
+		return
+	}
+
+	requestId, content, err := parseEnvelope(value)
+	if err != nil {
+		fmt.Printf("Error decoding message: %v\n", err)
+		c.deadLetterAndCommit(msg, err)
+		return
+	}
+
+	fmt.Printf("Processing message with requestId: %s\n", requestId)
+
+	err = handler(content)
+	for attempt := 0; IsRetryable(err) && attempt < c.maxRetries; attempt++ {
+		fmt.Printf("Retrying message with requestId: %s after error: %v\n", requestId, err)
+		time.Sleep(retryDelay(c.retryBackoff, attempt))
+		err = handler(content)
+	}
+
+	switch {
+	case err == nil:
+		fmt.Printf("Successfully processed message with requestId: %s\n", requestId)
+		c.commit(msg)
+	case errors.Is(err, Skip):
+		fmt.Printf("Skipped message with requestId: %s\n", requestId)
+		c.commit(msg)
+	case IsRetryable(err), IsPermanent(err):
+		fmt.Printf("Error processing message with requestId: %s: %v\n", requestId, err)
+		c.deadLetterAndCommit(msg, err)
+	default:
+		fmt.Printf("Error processing message, retrying: %v\n", err)
+		c.rewind(msg)
+	}
+}
+
+// processInOrder hands a raw message to the handler, rewinding its partition to the message on failure.
+// Messages that can never be handled are dead-lettered instead, so they don't block their partition.
+func (c *KafkaConsumer) processInOrder(msg *kafka.Message, handler func(*kafka.Message) error) {
+	if err := handler(msg); err != nil {
+		if IsPermanent(err) || errors.Is(err, ErrPoisonMessage) {
+			fmt.Printf("Error processing message at %v: %v\n", msg.TopicPartition, err)
+			c.deadLetterAndCommit(msg, err)
+			return
+		}
+
+		fmt.Printf("Error processing message at %v, retrying: %v\n", msg.TopicPartition, err)
+		c.rewind(msg)
+		return
+	}
+
+	c.commit(msg)
+}
+
+// rewind waits for the retry backoff and seeks the partition of a failed message back to it,
+// so that the message and the ones after it are delivered again
+func (c *KafkaConsumer) rewind(msg *kafka.Message) {
+	time.Sleep(c.retryBackoff)
+
+	tp := msg.TopicPartition
+	offset := c.offsets.rewind(tp)
+	if _, err := c.client.SeekPartitions([]kafka.TopicPartition{{Topic: tp.Topic, Partition: tp.Partition, Offset: offset}}); err != nil {
+		fmt.Printf("Error rewinding partition: %v\n", err)
+	}
+}
+
+// commit commits a handled message, unless an earlier message of its partition is still being
+// handled or is delivered again, in which case a later commit covers it
+func (c *KafkaConsumer) commit(msg *kafka.Message) {
+	if !c.offsets.done(msg.TopicPartition) {
+		return
+	}
+	if _, err := c.client.CommitMessage(msg); err != nil {
+		fmt.Printf("Error committing message: %v\n", err)
+	}
+}
+
+// parseEnvelope extracts the requestId and content from a message value
+func parseEnvelope(value []byte) (string, map[string]interface{}, error) {
+	var jsonMsg map[string]interface{}
+	if err := json.Unmarshal(value, &jsonMsg); err != nil {
+		return "", nil, fmt.Errorf("%w: message is not valid JSON: %v", ErrPoisonMessage, err)
+	}
+
+	requestId, ok := jsonMsg["requestId"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: requestId not found or not a string in message", ErrPoisonMessage)
+	}
+
+	content, ok := jsonMsg["content"].(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("%w: content not found or invalid in message", ErrPoisonMessage)
+	}
+
+	return requestId, content, nil
+}
+
+// retryDelay returns the backoff before the given retry attempt, starting at 0
+func retryDelay(backoff time.Duration, attempt int) time.Duration {
+	return backoff << attempt
+}
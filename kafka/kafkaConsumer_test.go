@@ -0,0 +1,257 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsumerClient serves in-memory partitions like a broker would: from the position of each
+// partition, which seeking moves, skipping paused partitions
+type fakeConsumerClient struct {
+	mu        sync.Mutex
+	keys      []partitionKey
+	logs      map[partitionKey][]*kafka.Message
+	positions map[partitionKey]kafka.Offset
+	paused    map[partitionKey]bool
+	committed map[partitionKey]kafka.Offset
+	resumes   int
+}
+
+func newFakeConsumerClient() *fakeConsumerClient {
+	return &fakeConsumerClient{
+		logs:      make(map[partitionKey][]*kafka.Message),
+		positions: make(map[partitionKey]kafka.Offset),
+		paused:    make(map[partitionKey]bool),
+		committed: make(map[partitionKey]kafka.Offset),
+	}
+}
+
+// append adds a message at the end of a partition and returns it with its offset
+func (f *fakeConsumerClient) append(topic string, partition int32, value string, headers ...kafka.Header) *kafka.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := partitionKey{topic: topic, partition: partition}
+	if _, ok := f.logs[key]; !ok {
+		f.keys = append(f.keys, key)
+	}
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(len(f.logs[key]))},
+		Value:          []byte(value),
+		Headers:        headers,
+	}
+	f.logs[key] = append(f.logs[key], msg)
+	return msg
+}
+
+func (f *fakeConsumerClient) SubscribeTopics(topics []string, rebalanceCb kafka.RebalanceCb) error {
+	return nil
+}
+
+func (f *fakeConsumerClient) Poll(timeoutMs int) kafka.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range f.keys {
+		position := f.positions[key]
+		if f.paused[key] || int(position) >= len(f.logs[key]) {
+			continue
+		}
+		f.positions[key] = position + 1
+		msg := *f.logs[key][position]
+		return &msg
+	}
+	return nil
+}
+
+func (f *fakeConsumerClient) CommitMessage(m *kafka.Message) ([]kafka.TopicPartition, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed[newPartitionKey(m.TopicPartition)] = m.TopicPartition.Offset + 1
+	return nil, nil
+}
+
+func (f *fakeConsumerClient) SeekPartitions(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tp := range partitions {
+		f.positions[newPartitionKey(tp)] = tp.Offset
+	}
+	return partitions, nil
+}
+
+func (f *fakeConsumerClient) Pause(partitions []kafka.TopicPartition) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tp := range partitions {
+		f.paused[newPartitionKey(tp)] = true
+	}
+	return nil
+}
+
+func (f *fakeConsumerClient) Resume(partitions []kafka.TopicPartition) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tp := range partitions {
+		f.paused[newPartitionKey(tp)] = false
+	}
+	f.resumes++
+	return nil
+}
+
+func (f *fakeConsumerClient) Close() error {
+	return nil
+}
+
+// committedOffset returns the next offset to consume committed for a partition, if any
+func (f *fakeConsumerClient) committedOffset(topic string, partition int32) (kafka.Offset, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	offset, ok := f.committed[partitionKey{topic: topic, partition: partition}]
+	return offset, ok
+}
+
+func newTestConsumer(client consumerClient) *KafkaConsumer {
+	return &KafkaConsumer{
+		client:       client,
+		held:         make(map[partitionKey]time.Time),
+		offsets:      newOffsetTracker(),
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+}
+
+func TestConsumerConfig(t *testing.T) {
+	original := &kafka.ConfigMap{"group.id": "carts", "enable.auto.offset.store": true}
+
+	config := consumerConfig(original)
+	assert.Equal(t, &kafka.ConfigMap{"group.id": "carts", "enable.auto.offset.store": false}, config)
+	assert.Equal(t, true, (*original)["enable.auto.offset.store"], "the caller's config is left unchanged")
+}
+
+func envelope(id string) string {
+	return `{"requestId": "req-` + id + `", "content": {"id": "` + id + `"}}`
+}
+
+// poll returns the next message, recording it as being handled like Start does
+func poll(t *testing.T, client *fakeConsumerClient, consumer *KafkaConsumer) *kafka.Message {
+	msg, ok := client.Poll(100).(*kafka.Message)
+	require.True(t, ok)
+	consumer.offsets.start(msg.TopicPartition)
+	return msg
+}
+
+func TestProcess_RedeliversFailedMessage(t *testing.T) {
+	client := newFakeConsumerClient()
+	consumer := newTestConsumer(client)
+	consumer.SetRetryPolicy(0, time.Millisecond)
+
+	client.append("orders", 0, envelope("a"))
+	client.append("orders", 0, envelope("b"))
+	client.append("orders", 0, envelope("c"))
+
+	var handled []string
+	failures := map[string]int{"b": 1}
+	handler := func(content map[string]interface{}) error {
+		id := content["id"].(string)
+		handled = append(handled, id)
+		if failures[id] > 0 {
+			failures[id]--
+			return errors.New("database unavailable")
+		}
+		return nil
+	}
+
+	// the three messages are handled concurrently, b fails after c was polled
+	a, b, c := poll(t, client, consumer), poll(t, client, consumer), poll(t, client, consumer)
+	consumer.process(a, handler)
+	consumer.process(b, handler)
+	consumer.process(c, handler)
+
+	// c is not committed, it would commit b as well
+	committed, ok := client.committedOffset("orders", 0)
+	require.True(t, ok)
+	assert.Equal(t, kafka.Offset(1), committed)
+
+	// the partition was rewound to b, which is delivered again followed by c
+	consumer.process(poll(t, client, consumer), handler)
+	consumer.process(poll(t, client, consumer), handler)
+	assert.Nil(t, client.Poll(100))
+
+	assert.Equal(t, []string{"a", "b", "c", "b", "c"}, handled)
+	committed, _ = client.committedOffset("orders", 0)
+	assert.Equal(t, kafka.Offset(3), committed)
+}
+
+func TestProcessInOrder_RedeliversFailedMessage(t *testing.T) {
+	client := newFakeConsumerClient()
+	consumer := newTestConsumer(client)
+	consumer.SetRetryPolicy(0, time.Millisecond)
+
+	client.append("orders", 0, "a")
+	client.append("orders", 0, "b")
+
+	var handled []string
+	failed := false
+	handler := func(msg *kafka.Message) error {
+		handled = append(handled, string(msg.Value))
+		if string(msg.Value) == "a" && !failed {
+			failed = true
+			return errors.New("database unavailable")
+		}
+		return nil
+	}
+
+	for msg, ok := client.Poll(100).(*kafka.Message); ok; msg, ok = client.Poll(100).(*kafka.Message) {
+		consumer.processInOrder(msg, handler)
+	}
+
+	assert.Equal(t, []string{"a", "a", "b"}, handled)
+	committed, _ := client.committedOffset("orders", 0)
+	assert.Equal(t, kafka.Offset(2), committed)
+}
+
+func TestProcessInOrder_DeadLettersPoisonMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "permanent", err: Permanent(errors.New("unknown schema version"))},
+		{name: "poison", err: fmt.Errorf("%w: value is not valid JSON", ErrPoisonMessage)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeConsumerClient()
+			consumer := newTestConsumer(client)
+			consumer.SetRetryPolicy(0, time.Millisecond)
+			client.append("orders", 0, "poison")
+			client.append("orders", 0, "b")
+
+			var handled []string
+			handler := func(msg *kafka.Message) error {
+				handled = append(handled, string(msg.Value))
+				if string(msg.Value) == "poison" {
+					return tt.err
+				}
+				return nil
+			}
+
+			for msg, ok := client.Poll(100).(*kafka.Message); ok; msg, ok = client.Poll(100).(*kafka.Message) {
+				consumer.processInOrder(msg, handler)
+			}
+
+			// without a dead letter queue the poison message is dropped, it is not handled again
+			assert.Equal(t, []string{"poison", "b"}, handled)
+			committed, _ := client.committedOffset("orders", 0)
+			assert.Equal(t, kafka.Offset(2), committed)
+		})
+	}
+}
@@ -53,10 +53,11 @@ func initFactory() (*KafkaFactory, error) {
 
 // CreateConsumer creates a new KafkaConsumer instance
 func (f *KafkaFactory) CreateConsumer(groupId string) (*KafkaConsumer, error) {
-	consumerConfig := *f.config
+	consumerConfig := f.copyConfig()
 	consumerConfig["group.id"] = groupId
 	consumerConfig["auto.offset.reset"] = "earliest"
-	consumerConfig["enable.auto.commit"] = true
+	// Messages are committed by the consumer once handled
+	consumerConfig["enable.auto.commit"] = false
 
 	consumer, err := NewKafkaConsumer(&consumerConfig)
 	if err != nil {
@@ -76,6 +77,64 @@ func (f *KafkaFactory) CreateProducer() (*KafkaProducer, error) {
 	return NewKafkaProducer(producer), nil
 }
 
+// CreateProducerWithOptions creates a new KafkaProducer with its own compression, size limit and claim-check settings
+func (f *KafkaFactory) CreateProducerWithOptions(opts ProducerOptions) (*KafkaProducer, error) {
+	producerConfig := f.copyConfig()
+	if opts.Compression != "" {
+		if !validCompression(opts.Compression) {
+			return nil, fmt.Errorf("unsupported compression type: %s", opts.Compression)
+		}
+		producerConfig["compression.type"] = opts.Compression
+	}
+	if opts.MaxMessageBytes < 0 || opts.ClaimCheckThreshold < 0 {
+		return nil, fmt.Errorf("message size limits cannot be negative")
+	}
+	maxMessageBytes := defaultMaxMessageBytes
+	if opts.MaxMessageBytes > 0 {
+		producerConfig["message.max.bytes"] = opts.MaxMessageBytes
+		maxMessageBytes = opts.MaxMessageBytes
+	}
+	if opts.ClaimCheckThreshold > maxMessageBytes {
+		return nil, fmt.Errorf("claim check threshold of %d bytes exceeds the message size limit of %d bytes", opts.ClaimCheckThreshold, maxMessageBytes)
+	}
+
+	producer, err := kafka.NewProducer(&producerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer: %s", err)
+	}
+
+	p := NewKafkaProducer(producer)
+	if opts.MaxMessageBytes > 0 {
+		p.maxMessageBytes = opts.MaxMessageBytes
+		p.claimCheckThreshold = opts.MaxMessageBytes
+	}
+	if opts.ClaimCheckThreshold > 0 {
+		p.claimCheckThreshold = opts.ClaimCheckThreshold
+	}
+	p.blobStore = opts.BlobStore
+
+	return p, nil
+}
+
+// copyConfig returns a copy of the factory config that can be changed without affecting other clients
+func (f *KafkaFactory) copyConfig() kafka.ConfigMap {
+	config := make(kafka.ConfigMap, len(*f.config))
+	for key, value := range *f.config {
+		config[key] = value
+	}
+	return config
+}
+
+// validCompression reports whether the compression type is supported by librdkafka
+func validCompression(compression string) bool {
+	switch compression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+		return true
+	default:
+		return false
+	}
+}
+
 // CreateProducerWithDeliveryChannel creates a new producer with a delivery channel
 func (f *KafkaFactory) CreateProducerWithDeliveryChannel() (*kafka.Producer, chan kafka.Event, error) {
 	producer, err := kafka.NewProducer(f.config)
@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 )
@@ -10,13 +11,32 @@ import (
 type KafkaProducer struct {
 	producer     *kafka.Producer
 	deliveryChan chan kafka.Event
+
+	maxMessageBytes     int
+	blobStore           BlobStore
+	claimCheckThreshold int
+}
+
+// ProducerOptions configures a producer created with KafkaFactory.CreateProducerWithOptions
+type ProducerOptions struct {
+	// Compression is the compression.type of the producer: none, gzip, snappy, lz4 or zstd. Defaults to snappy.
+	Compression string
+	// MaxMessageBytes is the largest message the producer sends, it should match message.max.bytes
+	// of the producer and the topic. Defaults to 1000000.
+	MaxMessageBytes int
+	// BlobStore enables claim-check offloading of messages larger than ClaimCheckThreshold
+	BlobStore BlobStore
+	// ClaimCheckThreshold is the size above which messages are offloaded. Defaults to MaxMessageBytes.
+	ClaimCheckThreshold int
 }
 
 // NewKafkaProducer creates a new KafkaProducer with a producer and delivery channel
 func NewKafkaProducer(producer *kafka.Producer) *KafkaProducer {
 	return &KafkaProducer{
-		producer:     producer,
-		deliveryChan: make(chan kafka.Event, 100),
+		producer:            producer,
+		deliveryChan:        make(chan kafka.Event, 100),
+		maxMessageBytes:     defaultMaxMessageBytes,
+		claimCheckThreshold: defaultMaxMessageBytes,
 	}
 }
 
@@ -28,11 +48,35 @@ func (k *KafkaProducer) Close() {
 
 // ProduceMessage sends a message to the specified Kafka topic synchronously
 func (k *KafkaProducer) ProduceMessage(topic string, message []byte) error {
-	// Send the message
-	err := k.producer.Produce(&kafka.Message{
+	return k.produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 		Value:          message,
-	}, k.deliveryChan)
+	})
+}
+
+// ProduceMessageWithDelay sends a message that consumers should only handle once the delay has elapsed
+func (k *KafkaProducer) ProduceMessageWithDelay(topic string, message []byte, delay time.Duration) error {
+	return k.ProduceMessageAt(topic, message, time.Now().Add(delay))
+}
+
+// ProduceMessageAt sends a message that consumers should only handle at or after deliverAt.
+// The deliver time is carried in the DeliverAtHeader and honoured by consumers with delayed delivery enabled.
+func (k *KafkaProducer) ProduceMessageAt(topic string, message []byte, deliverAt time.Time) error {
+	return k.produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          message,
+		Headers:        []kafka.Header{deliverAtHeader(deliverAt)},
+	})
+}
+
+// produce sends a message and waits for its delivery report
+func (k *KafkaProducer) produce(msg *kafka.Message) error {
+	if err := k.prepare(msg); err != nil {
+		return err
+	}
+
+	// Send the message
+	err := k.producer.Produce(msg, k.deliveryChan)
 
 	if err != nil {
 		return fmt.Errorf("failed to produce message: %s", err)
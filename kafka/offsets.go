@@ -0,0 +1,236 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// offsetQueryTimeoutMs bounds metadata and watermark lookups against the brokers
+const offsetQueryTimeoutMs = 10000
+
+// OffsetPosition selects a position in a topic
+type OffsetPosition int
+
+const (
+	// OffsetEarliest is the oldest message still retained in each partition
+	OffsetEarliest OffsetPosition = iota
+	// OffsetLatest is the end of each partition, after the newest message
+	OffsetLatest
+	// OffsetTimestamp is the first message at or after a point in time
+	OffsetTimestamp
+	// OffsetExplicit is an offset given per partition
+	OffsetExplicit
+)
+
+// ParseOffsetPosition parses earliest, latest, timestamp or offset
+func ParseOffsetPosition(s string) (OffsetPosition, error) {
+	switch strings.ToLower(s) {
+	case "earliest":
+		return OffsetEarliest, nil
+	case "latest":
+		return OffsetLatest, nil
+	case "timestamp":
+		return OffsetTimestamp, nil
+	case "offset":
+		return OffsetExplicit, nil
+	default:
+		return 0, fmt.Errorf("unknown offset position %q, expected earliest, latest, timestamp or offset", s)
+	}
+}
+
+// ParsePartitionOffsets parses a comma separated list of partition=offset pairs, e.g. "0=120,1=98"
+func ParsePartitionOffsets(s string) (map[int32]int64, error) {
+	offsets := make(map[int32]int64)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		partitionStr, offsetStr, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid partition offset %q, expected partition=offset", pair)
+		}
+		partition, err := strconv.ParseInt(strings.TrimSpace(partitionStr), 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition in %q", pair)
+		}
+		offset, err := strconv.ParseInt(strings.TrimSpace(offsetStr), 10, 64)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset in %q", pair)
+		}
+		offsets[int32(partition)] = offset
+	}
+
+	if len(offsets) == 0 {
+		return nil, fmt.Errorf("no partition offsets given")
+	}
+	return offsets, nil
+}
+
+// OffsetSpec describes where in a topic to position a consumer group or a dump
+type OffsetSpec struct {
+	Position OffsetPosition
+	// Timestamp is used with OffsetTimestamp
+	Timestamp time.Time
+	// Offsets maps partition to offset and is used with OffsetExplicit.
+	// Partitions that are not listed are left untouched.
+	Offsets map[int32]int64
+}
+
+// ResetConsumerGroupOffsets moves the committed offsets of a consumer group on a topic and returns
+// the offsets that were committed. The group must have no active members on the topic.
+func (f *KafkaFactory) ResetConsumerGroupOffsets(ctx context.Context, groupId string, topic string, spec OffsetSpec) ([]kafka.TopicPartition, error) {
+	if groupId == "" || topic == "" {
+		return nil, fmt.Errorf("group id and topic cannot be empty")
+	}
+
+	consumer, err := f.newToolConsumer(groupId)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+
+	offsets, err := resolveOffsets(consumer, topic, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := kafka.NewAdminClientFromConsumer(consumer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin client: %v", err)
+	}
+	defer admin.Close()
+
+	result, err := admin.AlterConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{
+		{Group: groupId, Partitions: offsets},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to alter consumer group offsets: %v", err)
+	}
+
+	var committed []kafka.TopicPartition
+	for _, group := range result.ConsumerGroupsTopicPartitions {
+		for _, tp := range group.Partitions {
+			if tp.Error != nil {
+				return nil, fmt.Errorf("failed to alter offset of partition %d: %v", tp.Partition, tp.Error)
+			}
+			committed = append(committed, tp)
+		}
+	}
+
+	return committed, nil
+}
+
+// newToolConsumer creates a consumer that is only used for lookups and manual assignment, it never joins the group
+func (f *KafkaFactory) newToolConsumer(groupId string) (*kafka.Consumer, error) {
+	consumerConfig := f.copyConfig()
+	consumerConfig["group.id"] = groupId
+	consumerConfig["enable.auto.commit"] = false
+	consumerConfig["enable.partition.eof"] = true
+
+	consumer, err := kafka.NewConsumer(&consumerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %v", err)
+	}
+	return consumer, nil
+}
+
+// topicPartitions returns the partition ids of a topic in ascending order
+func topicPartitions(consumer *kafka.Consumer, topic string) ([]int32, error) {
+	metadata, err := consumer.GetMetadata(&topic, false, offsetQueryTimeoutMs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata for topic %s: %v", topic, err)
+	}
+
+	topicMetadata, ok := metadata.Topics[topic]
+	if !ok || topicMetadata.Error.Code() != kafka.ErrNoError {
+		return nil, fmt.Errorf("topic %s not found: %v", topic, topicMetadata.Error)
+	}
+
+	partitions := make([]int32, 0, len(topicMetadata.Partitions))
+	for _, p := range topicMetadata.Partitions {
+		partitions = append(partitions, p.ID)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	return partitions, nil
+}
+
+// resolveOffsets turns an OffsetSpec into absolute offsets for each partition of a topic
+func resolveOffsets(consumer *kafka.Consumer, topic string, spec OffsetSpec) ([]kafka.TopicPartition, error) {
+	partitions, err := topicPartitions(consumer, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([]kafka.TopicPartition, 0, len(partitions))
+	switch spec.Position {
+	case OffsetEarliest, OffsetLatest:
+		for _, p := range partitions {
+			low, high, err := consumer.QueryWatermarkOffsets(topic, p, offsetQueryTimeoutMs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query watermarks of partition %d: %v", p, err)
+			}
+			offset := low
+			if spec.Position == OffsetLatest {
+				offset = high
+			}
+			offsets = append(offsets, kafka.TopicPartition{Topic: &topic, Partition: p, Offset: kafka.Offset(offset)})
+		}
+	case OffsetTimestamp:
+		if spec.Timestamp.IsZero() {
+			return nil, fmt.Errorf("timestamp cannot be empty")
+		}
+		times := make([]kafka.TopicPartition, 0, len(partitions))
+		for _, p := range partitions {
+			times = append(times, kafka.TopicPartition{Topic: &topic, Partition: p, Offset: kafka.Offset(spec.Timestamp.UnixMilli())})
+		}
+		found, err := consumer.OffsetsForTimes(times, offsetQueryTimeoutMs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up offsets for timestamp: %v", err)
+		}
+		for _, tp := range found {
+			if tp.Error != nil {
+				return nil, fmt.Errorf("failed to look up offset of partition %d: %v", tp.Partition, tp.Error)
+			}
+			if tp.Offset < 0 {
+				// No message at or after the timestamp, position at the end of the partition
+				_, high, err := consumer.QueryWatermarkOffsets(topic, tp.Partition, offsetQueryTimeoutMs)
+				if err != nil {
+					return nil, fmt.Errorf("failed to query watermarks of partition %d: %v", tp.Partition, err)
+				}
+				tp.Offset = kafka.Offset(high)
+			}
+			offsets = append(offsets, kafka.TopicPartition{Topic: &topic, Partition: tp.Partition, Offset: tp.Offset})
+		}
+	case OffsetExplicit:
+		if len(spec.Offsets) == 0 {
+			return nil, fmt.Errorf("no partition offsets given")
+		}
+		known := make(map[int32]bool, len(partitions))
+		for _, p := range partitions {
+			known[p] = true
+		}
+		for _, p := range partitions {
+			if offset, ok := spec.Offsets[p]; ok {
+				offsets = append(offsets, kafka.TopicPartition{Topic: &topic, Partition: p, Offset: kafka.Offset(offset)})
+			}
+		}
+		for p := range spec.Offsets {
+			if !known[p] {
+				return nil, fmt.Errorf("partition %d does not exist in topic %s", p, topic)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown offset position %d", spec.Position)
+	}
+
+	return offsets, nil
+}
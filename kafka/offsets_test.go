@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOffsetPosition(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    OffsetPosition
+		wantErr bool
+	}{
+		{input: "earliest", want: OffsetEarliest},
+		{input: "LATEST", want: OffsetLatest},
+		{input: "timestamp", want: OffsetTimestamp},
+		{input: "offset", want: OffsetExplicit},
+		{input: "beginning", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseOffsetPosition(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePartitionOffsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[int32]int64
+		wantErr bool
+	}{
+		{name: "single pair", input: "0=120", want: map[int32]int64{0: 120}},
+		{name: "multiple pairs with spaces", input: "0=120, 1 = 98,", want: map[int32]int64{0: 120, 1: 98}},
+		{name: "missing separator", input: "0:120", wantErr: true},
+		{name: "invalid partition", input: "a=120", wantErr: true},
+		{name: "negative offset", input: "0=-1", wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePartitionOffsets(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestToDumpedMessage(t *testing.T) {
+	topic := "products"
+	ts := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	base := kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: 42},
+		Timestamp:      ts,
+		Key:            []byte("sku-1"),
+		Headers:        []kafka.Header{{Key: "traceId", Value: []byte("t-1")}},
+	}
+
+	tests := []struct {
+		name      string
+		value     []byte
+		wantValue string
+	}{
+		{name: "JSON value", value: []byte(`{"price":10}`), wantValue: `{"price":10}`},
+		{name: "non JSON value", value: []byte("plain text"), wantValue: `"plain text"`},
+		{name: "tombstone", value: nil, wantValue: `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := base
+			msg.Value = tt.value
+
+			dumped := toDumpedMessage(&msg)
+			assert.Equal(t, "products", dumped.Topic)
+			assert.Equal(t, int32(1), dumped.Partition)
+			assert.Equal(t, int64(42), dumped.Offset)
+			assert.Equal(t, ts, dumped.Timestamp)
+			assert.Equal(t, "sku-1", dumped.Key)
+			assert.Equal(t, map[string]string{"traceId": "t-1"}, dumped.Headers)
+			assert.Equal(t, tt.wantValue, string(dumped.Value))
+
+			_, err := json.Marshal(dumped)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestKafkaFactory_ConsumersKeepFactoryConfig(t *testing.T) {
+	f := &KafkaFactory{config: &kafka.ConfigMap{"bootstrap.servers": "localhost:9092"}}
+
+	toolConsumer, err := f.newToolConsumer("carts-tool")
+	require.NoError(t, err)
+	toolConsumer.Close()
+
+	consumer, err := f.CreateConsumer("carts")
+	require.NoError(t, err)
+	consumer.client.Close()
+
+	assert.Equal(t, kafka.ConfigMap{"bootstrap.servers": "localhost:9092"}, *f.config)
+}
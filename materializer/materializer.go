@@ -0,0 +1,192 @@
+package materializer
+
+import (
+	"fmt"
+	"reflect"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/kdjuwidja/aishoppercommon/db"
+	"github.com/kdjuwidja/aishoppercommon/kafka"
+	"github.com/kdjuwidja/aishoppercommon/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MapFunc fills row, a pointer to a new instance of the materializer's model, from a message.
+// For tombstones value is nil and only the primary key of row has to be set.
+type MapFunc func(key []byte, value []byte, row interface{}) error
+
+// MaterializerOffset records the last offset a materializer applied on a partition.
+// It is written in the same transaction as the rows, so every message is applied exactly once.
+type MaterializerOffset struct {
+	Materializer string `gorm:"type:varchar(255);primaryKey"`
+	Topic        string `gorm:"type:varchar(255);primaryKey"`
+	PartitionID  int32  `gorm:"primaryKey;autoIncrement:false"`
+	LastOffset   int64  `gorm:"not null"`
+}
+
+// Materializer consumes a compacted topic and mirrors it into a MySQL table.
+// Messages are upserted with ON DUPLICATE KEY UPDATE and tombstones delete their row.
+type Materializer struct {
+	name      string
+	topic     string
+	modelType reflect.Type
+	mapFn     MapFunc
+
+	consumer *kafka.KafkaConsumer
+	db       *gorm.DB
+}
+
+// NewMaterializer creates a materializer named name, which identifies its offsets, that applies topic to the table of model.
+// model is a pointer to a GORM model, e.g. &Product{}.
+func NewMaterializer(name string, topic string, model interface{}, mapFn MapFunc, consumer *kafka.KafkaConsumer, pool *db.MySQLConnectionPool) (*Materializer, error) {
+	if name == "" || topic == "" {
+		return nil, fmt.Errorf("materializer name and topic cannot be empty")
+	}
+	if mapFn == nil {
+		return nil, fmt.Errorf("map function cannot be nil")
+	}
+	if consumer == nil || pool == nil || pool.GetDB() == nil {
+		return nil, fmt.Errorf("consumer and connection pool cannot be nil")
+	}
+
+	modelType := reflect.TypeOf(model)
+	if modelType == nil || modelType.Kind() != reflect.Ptr || modelType.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model must be a pointer to a struct, got %T", model)
+	}
+
+	return &Materializer{
+		name:      name,
+		topic:     topic,
+		modelType: modelType.Elem(),
+		mapFn:     mapFn,
+		consumer:  consumer,
+		db:        pool.GetDB(),
+	}, nil
+}
+
+// Start creates the offset table if needed and applies the topic until Stop is called
+func (m *Materializer) Start() error {
+	if err := m.db.AutoMigrate(&MaterializerOffset{}); err != nil {
+		return fmt.Errorf("error migrating materializer offsets: %w", err)
+	}
+
+	logger.Infof("materializer %s: applying topic %s", m.name, m.topic)
+	m.consumer.StartWithMessageHandler(m.topic, m.rebalance, m.apply)
+	return nil
+}
+
+// Stop stops consuming the topic
+func (m *Materializer) Stop() {
+	m.consumer.Stop()
+}
+
+// rebalance assigns partitions at the offsets stored in MySQL rather than those committed to Kafka
+func (m *Materializer) rebalance(c *confluent.Consumer, ev confluent.Event) error {
+	switch e := ev.(type) {
+	case confluent.AssignedPartitions:
+		stored, err := m.storedOffsets()
+		if err != nil {
+			logger.Errorf("materializer %s: %v", m.name, err)
+			return err
+		}
+		return c.Assign(assignmentFrom(e.Partitions, stored))
+	case confluent.RevokedPartitions:
+		return c.Unassign()
+	}
+	return nil
+}
+
+// storedOffsets returns the last applied offset of every partition of the topic
+func (m *Materializer) storedOffsets() (map[int32]int64, error) {
+	var rows []MaterializerOffset
+	if err := m.db.Where("materializer = ? AND topic = ?", m.name, m.topic).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error loading offsets: %w", err)
+	}
+
+	offsets := make(map[int32]int64, len(rows))
+	for _, row := range rows {
+		offsets[row.PartitionID] = row.LastOffset
+	}
+	return offsets, nil
+}
+
+// assignmentFrom positions each partition after its last applied offset, or at the beginning if none was applied
+func assignmentFrom(partitions []confluent.TopicPartition, stored map[int32]int64) []confluent.TopicPartition {
+	assignment := make([]confluent.TopicPartition, len(partitions))
+	for i, tp := range partitions {
+		assignment[i] = confluent.TopicPartition{Topic: tp.Topic, Partition: tp.Partition, Offset: confluent.OffsetBeginning}
+		if last, ok := stored[tp.Partition]; ok {
+			assignment[i].Offset = confluent.Offset(last + 1)
+		}
+	}
+	return assignment
+}
+
+// apply writes a message and its offset to MySQL in one transaction.
+// Messages that cannot be mapped are returned as poison, so the consumer dead-letters them rather than
+// retrying them forever, unless the map function marked the error kafka.Retryable. Only their offset is
+// written, so a restart does not dead-letter them again.
+// Messages at or before the last applied offset of their partition are skipped, so replaying a partition
+// after a rewind or a rebalance does not apply a message twice.
+func (m *Materializer) apply(msg *confluent.Message) error {
+	row := reflect.New(m.modelType).Interface()
+	mapErr := m.mapFn(msg.Key, msg.Value, row)
+	if mapErr != nil && kafka.IsRetryable(mapErr) {
+		return fmt.Errorf("error mapping message: %w", mapErr)
+	}
+
+	offset := &MaterializerOffset{
+		Materializer: m.name,
+		Topic:        m.topic,
+		PartitionID:  msg.TopicPartition.Partition,
+		LastOffset:   int64(msg.TopicPartition.Offset),
+	}
+	var poison error
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		applied, err := alreadyApplied(tx, offset)
+		if err != nil {
+			return err
+		}
+		if applied {
+			logger.Infof("materializer %s: skipping offset %d of partition %d, already applied", m.name, offset.LastOffset, offset.PartitionID)
+			return nil
+		}
+
+		switch {
+		case mapErr != nil:
+			poison = kafka.Permanent(fmt.Errorf("%w: error mapping message: %v", kafka.ErrPoisonMessage, mapErr))
+		case msg.Value == nil:
+			if err := tx.Unscoped().Delete(row).Error; err != nil {
+				return fmt.Errorf("error deleting row: %w", err)
+			}
+		default:
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(row).Error; err != nil {
+				return fmt.Errorf("error upserting row: %w", err)
+			}
+		}
+
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(offset).Error; err != nil {
+			return fmt.Errorf("error storing offset: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return poison
+}
+
+// alreadyApplied reports whether the partition of offset was applied up to or past it.
+// The stored offset is locked until the transaction ends, so concurrent materializers cannot both apply a message.
+func alreadyApplied(tx *gorm.DB, offset *MaterializerOffset) (bool, error) {
+	var stored MaterializerOffset
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("materializer = ? AND topic = ? AND partition_id = ?", offset.Materializer, offset.Topic, offset.PartitionID).
+		Limit(1).
+		Find(&stored)
+	if result.Error != nil {
+		return false, fmt.Errorf("error loading offset: %w", result.Error)
+	}
+	return result.RowsAffected > 0 && stored.LastOffset >= offset.LastOffset, nil
+}
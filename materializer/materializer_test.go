@@ -0,0 +1,321 @@
+package materializer
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/kdjuwidja/aishoppercommon/db"
+	"github.com/kdjuwidja/aishoppercommon/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type testProduct struct {
+	SKU  string `gorm:"type:varchar(32);primaryKey"`
+	Name string `gorm:"type:varchar(255)"`
+}
+
+func TestNewMaterializer_Validation(t *testing.T) {
+	mapFn := func(key []byte, value []byte, row interface{}) error { return nil }
+	consumer := &kafka.KafkaConsumer{}
+
+	tests := []struct {
+		name     string
+		matName  string
+		topic    string
+		model    interface{}
+		mapFn    MapFunc
+		consumer *kafka.KafkaConsumer
+		pool     *db.MySQLConnectionPool
+	}{
+		{name: "empty name", matName: "", topic: "products", model: &testProduct{}, mapFn: mapFn, consumer: consumer},
+		{name: "empty topic", matName: "products", topic: "", model: &testProduct{}, mapFn: mapFn, consumer: consumer},
+		{name: "nil map function", matName: "products", topic: "products", model: &testProduct{}, consumer: consumer},
+		{name: "nil consumer", matName: "products", topic: "products", model: &testProduct{}, mapFn: mapFn},
+		{name: "uninitialized pool", matName: "products", topic: "products", model: &testProduct{}, mapFn: mapFn, consumer: consumer, pool: &db.MySQLConnectionPool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMaterializer(tt.matName, tt.topic, tt.model, tt.mapFn, tt.consumer, tt.pool)
+			assert.Error(t, err)
+			assert.Nil(t, m)
+		})
+	}
+}
+
+func TestAssignmentFrom(t *testing.T) {
+	topic := "products"
+	partitions := []confluent.TopicPartition{
+		{Topic: &topic, Partition: 0},
+		{Topic: &topic, Partition: 1},
+	}
+
+	assignment := assignmentFrom(partitions, map[int32]int64{1: 41})
+	assert.Len(t, assignment, 2)
+	assert.Equal(t, confluent.OffsetBeginning, assignment[0].Offset)
+	assert.Equal(t, confluent.Offset(42), assignment[1].Offset)
+	assert.Equal(t, "products", *assignment[1].Topic)
+}
+
+// fakeDB is a database/sql driver keeping materializer offsets in memory and recording the statements
+// run against it. Writes done in a transaction only become visible once it commits.
+type fakeDB struct {
+	mu         sync.Mutex
+	offsets    map[int32]int64
+	statements []string
+}
+
+type fakeConn struct {
+	db      *fakeDB
+	pending []func()
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+var fakeDBs sync.Map
+
+func init() {
+	sql.Register("materializer-fake", fakeDriver{})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, ok := fakeDBs.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown fake database %s", name)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.pending = nil
+	return &fakeTx{conn: c}, nil
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.conn.db.mu.Lock()
+	defer tx.conn.db.mu.Unlock()
+	for _, write := range tx.conn.pending {
+		write()
+	}
+	tx.conn.pending = nil
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.conn.pending = nil
+	return nil
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	db.statements = append(db.statements, s.query)
+	db.mu.Unlock()
+
+	if strings.HasPrefix(s.query, "INSERT INTO `materializer_offsets`") {
+		partition, offset := int32(args[2].(int64)), args[3].(int64)
+		s.conn.pending = append(s.conn.pending, func() { db.offsets[partition] = offset })
+	}
+	return fakeResult{}, nil
+}
+
+// fakeResult reports a single affected row
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (fakeResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.statements = append(db.statements, s.query)
+
+	rows := &fakeRows{columns: []string{"materializer", "topic", "partition_id", "last_offset"}}
+	if !strings.HasPrefix(s.query, "SELECT * FROM `materializer_offsets`") {
+		return rows, nil
+	}
+	for partition, offset := range db.offsets {
+		// the offset of a single partition is selected by its id, all of them otherwise
+		if len(args) > 2 && args[2].(int64) != int64(partition) {
+			continue
+		}
+		rows.values = append(rows.values, []driver.Value{args[0], args[1], int64(partition), offset})
+	}
+	return rows, nil
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// newFakeDBMaterializer returns a materializer of testProduct writing to a fake database
+func newFakeDBMaterializer(t *testing.T, mapFn MapFunc) (*Materializer, *fakeDB) {
+	fake := &fakeDB{offsets: make(map[int32]int64)}
+	fakeDBs.Store(t.Name(), fake)
+	t.Cleanup(func() { fakeDBs.Delete(t.Name()) })
+
+	sqlDB, err := sql.Open("materializer-fake", t.Name())
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	return &Materializer{
+		name:      "products",
+		topic:     "products-compacted",
+		modelType: reflect.TypeOf(testProduct{}),
+		mapFn:     mapFn,
+		db:        gormDB,
+	}, fake
+}
+
+// productStatements returns the statements that wrote to the products table
+func (db *fakeDB) productStatements() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var statements []string
+	for _, statement := range db.statements {
+		if strings.Contains(statement, "`test_products`") {
+			statements = append(statements, strings.Fields(statement)[0])
+		}
+	}
+	return statements
+}
+
+func mapProduct(key []byte, value []byte, row interface{}) error {
+	product := row.(*testProduct)
+	product.SKU = string(key)
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(value, product)
+}
+
+func productMessage(offset int64, sku string, value []byte) *confluent.Message {
+	topic := "products-compacted"
+	return &confluent.Message{
+		TopicPartition: confluent.TopicPartition{Topic: &topic, Partition: 0, Offset: confluent.Offset(offset)},
+		Key:            []byte(sku),
+		Value:          value,
+	}
+}
+
+func TestApply_PoisonRecord(t *testing.T) {
+	m, fake := newFakeDBMaterializer(t, mapProduct)
+
+	err := m.apply(productMessage(0, "sku-1", []byte("not json")))
+	assert.True(t, kafka.IsPermanent(err))
+	assert.ErrorIs(t, err, kafka.ErrPoisonMessage)
+	assert.Empty(t, fake.productStatements(), "no row is written for a poison record")
+	assert.Equal(t, map[int32]int64{0: 0}, fake.offsets, "its offset is stored")
+
+	// the map function can ask for the message to be retried instead
+	m.mapFn = func(key []byte, value []byte, row interface{}) error {
+		return kafka.Retryable(errors.New("schema registry unavailable"))
+	}
+	err = m.apply(productMessage(0, "sku-1", []byte(`{"Name": "Oat milk"}`)))
+	assert.True(t, kafka.IsRetryable(err))
+	assert.False(t, kafka.IsPermanent(err))
+	assert.NotErrorIs(t, err, kafka.ErrPoisonMessage)
+}
+
+func TestApply_Replay(t *testing.T) {
+	m, fake := newFakeDBMaterializer(t, mapProduct)
+
+	require.NoError(t, m.apply(productMessage(5, "sku-1", []byte(`{"Name": "Oat milk"}`))))
+	require.NoError(t, m.apply(productMessage(6, "sku-1", nil)))
+	assert.Equal(t, []string{"INSERT", "DELETE"}, fake.productStatements())
+	assert.Equal(t, map[int32]int64{0: 6}, fake.offsets)
+
+	// after a rewind the partition is delivered again from offset 5, which must not recreate the deleted row
+	require.NoError(t, m.apply(productMessage(5, "sku-1", []byte(`{"Name": "Oat milk"}`))))
+	require.NoError(t, m.apply(productMessage(6, "sku-1", nil)))
+	assert.Equal(t, []string{"INSERT", "DELETE"}, fake.productStatements())
+
+	require.NoError(t, m.apply(productMessage(7, "sku-2", []byte(`{"Name": "Rye bread"}`))))
+	assert.Equal(t, []string{"INSERT", "DELETE", "INSERT"}, fake.productStatements())
+	assert.Equal(t, map[int32]int64{0: 7}, fake.offsets)
+}
+
+func TestApply_RestartAfterPoisonRecord(t *testing.T) {
+	m, fake := newFakeDBMaterializer(t, mapProduct)
+
+	require.NoError(t, m.apply(productMessage(2, "sku-1", []byte(`{"Name": "Oat milk"}`))))
+	err := m.apply(productMessage(3, "sku-2", []byte("not json")))
+	require.ErrorIs(t, err, kafka.ErrPoisonMessage)
+
+	// after a restart the partition is assigned after the poison record, it is not dead-lettered again
+	stored, err := m.storedOffsets()
+	require.NoError(t, err)
+	topic := "products-compacted"
+	assignment := assignmentFrom([]confluent.TopicPartition{{Topic: &topic, Partition: 0}}, stored)
+	assert.Equal(t, confluent.Offset(4), assignment[0].Offset)
+
+	// and if it is delivered again anyway, e.g. after a rewind, it is skipped rather than returned as poison
+	assert.NoError(t, m.apply(productMessage(3, "sku-2", []byte("not json"))))
+	assert.Equal(t, []string{"INSERT"}, fake.productStatements())
+}
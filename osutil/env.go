@@ -26,3 +26,17 @@ func GetEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// GetEnvStringSlice splits a comma separated variable into its trimmed, non-empty values
+func GetEnvStringSlice(key string, defaultValue []string) []string {
+	var values []string
+	for _, val := range strings.Split(os.Getenv(key), ",") {
+		if val = strings.TrimSpace(val); val != "" {
+			values = append(values, val)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
@@ -216,3 +216,46 @@ func TestGetEnvBool(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEnvStringSlice(t *testing.T) {
+	tests := []struct {
+		name         string
+		envValue     string
+		defaultValue []string
+		expected     []string
+	}{
+		{
+			name:         "single value",
+			envValue:     "http://es1:9200",
+			defaultValue: nil,
+			expected:     []string{"http://es1:9200"},
+		},
+		{
+			name:         "trimmed values",
+			envValue:     " http://es1:9200, http://es2:9200 ,,",
+			defaultValue: nil,
+			expected:     []string{"http://es1:9200", "http://es2:9200"},
+		},
+		{
+			name:         "empty value",
+			envValue:     "",
+			defaultValue: []string{"default"},
+			expected:     []string{"default"},
+		},
+		{
+			name:         "only separators",
+			envValue:     " , ",
+			defaultValue: []string{"default"},
+			expected:     []string{"default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_SLICE", tt.envValue)
+
+			result := GetEnvStringSlice("TEST_SLICE", tt.defaultValue)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}